@@ -0,0 +1,240 @@
+// Command admin carries out support operations on the wallet database:
+// inspecting users, wallets and transactions, adjusting and freezing wallets,
+// retrying stuck transactions and running migrations. Adjustments are held
+// until someone else approves them through the API, and are made by the
+// operator whose token is in OPERATOR_TOKEN. It reads the same configuration
+// as the server.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"p-system/config"
+	DB "p-system/db"
+	"p-system/logging"
+	"p-system/repositories/approval"
+	"p-system/repositories/transaction"
+	"p-system/repositories/unitofwork"
+	userrepo "p-system/repositories/user"
+	"p-system/repositories/wallet"
+	"p-system/services/adminservice"
+	"p-system/services/approvalsservice"
+	"p-system/services/thirdparty"
+	"p-system/services/transactionsservice"
+	"p-system/utils"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+const usage = `usage: admin <command> [flags] [args]
+
+commands:
+  inspect user|wallet|transaction <id>
+  credit -wallet <id> -amount <cents> -reason <text>
+  debit -wallet <id> -amount <cents> -reason <text>
+  freeze <wallet-id>
+  unfreeze <wallet-id>
+  retry-pending [-older-than 15m] [-dry-run]
+  resume-approvals [-older-than 15m]
+  migrate up|down|redo|status
+
+credit and debit are made by the operator whose token is in OPERATOR_TOKEN,
+and held until approved with POST /approvals/{id}/approve`
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Print("No .env file found")
+	}
+
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	logger, err := logging.New(os.Stderr, cfg.Log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	db, err := sqlx.Connect("postgres", cfg.Database.URL())
+	if err != nil {
+		log.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	if err := run(db, cfg, logger, os.Args[1], os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run runs the command given on the command line.
+func run(db *sqlx.DB, cfg config.Config, logger *slog.Logger, name string, args []string) error {
+	if name == "migrate" {
+		return migrate(db, args)
+	}
+
+	// Like the server, nothing runs against a schema it was not built for
+	if err := DB.CheckSchema(db.DB); err != nil {
+		return fmt.Errorf("run admin migrate up first: %w", err)
+	}
+
+	policies, err := DB.NewPolicies(cfg.Database.Isolation.ByOperation(), DB.RetryPolicy(cfg.Database.Retry))
+	if err != nil {
+		return err
+	}
+
+	userRepo := userrepo.NewRepository(db)
+	walletRepo := wallet.NewRepository(db, policies, logger)
+	transactionRepo := transaction.NewRepository(db)
+	uow := unitofwork.New(db, policies, logger)
+	approvalRepo := approval.NewRepository(db)
+	transactions := transactionsservice.NewService(userRepo, transactionRepo, walletRepo, uow, thirdparty.NewService(), nil, nil, 0, logger)
+	svc := adminservice.NewService(userRepo, walletRepo, transactionRepo, uow, transactions, approvalRepo, logger)
+	approvals := approvalsservice.NewService(approvalRepo, transactionRepo, transactions, svc, logger)
+
+	ctx := context.Background()
+
+	switch name {
+	case "inspect":
+		return inspect(ctx, svc, args)
+	case "credit", "debit":
+		operator := utils.Authenticate(cfg.Approvals.Tokens(), os.Getenv("OPERATOR_TOKEN"))
+		if operator == "" {
+			return errors.New("OPERATOR_TOKEN must be the token of an approval operator")
+		}
+		return adjust(utils.WithOperator(ctx, operator), svc, name, args)
+	case "freeze", "unfreeze":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: admin %s <wallet-id>", name)
+		}
+		w, err := svc.SetFrozen(ctx, args[0], name == "freeze")
+		if err != nil {
+			return err
+		}
+		return printJSON(w)
+	case "retry-pending":
+		return retryPending(ctx, svc, args)
+	case "resume-approvals":
+		return resumeApprovals(ctx, approvals, args)
+	default:
+		return fmt.Errorf("unknown command %q\n%s", name, usage)
+	}
+}
+
+// inspect prints a user, wallet or transaction with what ops need to know
+// about it.
+func inspect(ctx context.Context, svc adminservice.Service, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: admin inspect user|wallet|transaction <id>")
+	}
+
+	var report interface{}
+	var err error
+	switch args[0] {
+	case "user":
+		report, err = svc.InspectUser(ctx, args[1])
+	case "wallet":
+		report, err = svc.InspectWallet(ctx, args[1])
+	case "transaction":
+		report, err = svc.InspectTransaction(ctx, args[1])
+	default:
+		return fmt.Errorf("cannot inspect %q, expected one of user, wallet or transaction", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	return printJSON(report)
+}
+
+// adjust requests a credit or debit of a wallet by hand, made by the operator
+// carried by ctx and held until someone else approves it.
+func adjust(ctx context.Context, svc adminservice.Service, kind string, args []string) error {
+	flags := flag.NewFlagSet(kind, flag.ContinueOnError)
+	walletID := flags.String("wallet", "", "id of the wallet to "+kind)
+	amount := flags.Int64("amount", 0, "amount in cents")
+	reason := flags.String("reason", "", "why the wallet is adjusted, kept on the adjustment (required)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *walletID == "" {
+		return errors.New("-wallet is required")
+	}
+
+	requested, err := svc.RequestAdjustment(ctx, adminservice.Adjustment{
+		WalletID: *walletID,
+		Type:     kind,
+		Amount:   *amount,
+		Reason:   *reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(requested)
+}
+
+// retryPending completes transactions stuck in pending.
+func retryPending(ctx context.Context, svc adminservice.Service, args []string) error {
+	flags := flag.NewFlagSet("retry-pending", flag.ContinueOnError)
+	olderThan := flags.Duration("older-than", 15*time.Minute, "only retry transactions pending for longer than this")
+	dryRun := flags.Bool("dry-run", false, "list the transactions without retrying them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	results, err := svc.RetryPending(ctx, time.Now().Add(-*olderThan), *dryRun)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(results)
+}
+
+// resumeApprovals runs approved requests that never got to their outcome.
+func resumeApprovals(ctx context.Context, approvals approvalsservice.Service, args []string) error {
+	flags := flag.NewFlagSet("resume-approvals", flag.ContinueOnError)
+	olderThan := flags.Duration("older-than", 15*time.Minute, "only resume approvals approved longer ago than this")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	resumed, err := approvals.Resume(ctx, time.Now().Add(-*olderThan))
+	if err != nil {
+		return err
+	}
+
+	return printJSON(resumed)
+}
+
+// migrate runs migrations up, rolls back or redoes the latest one, or shows
+// their status.
+func migrate(db *sqlx.DB, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: admin migrate up|down|redo|status")
+	}
+
+	return DB.RunMigrations(db.DB, args[0])
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
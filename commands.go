@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"p-system/config"
+	DB "p-system/db"
+	"p-system/fixtures"
+	"p-system/repositories/wallet"
+	"p-system/services/walletsservice"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// errDiscrepancies makes check-balances exit non-zero when balances are off.
+var errDiscrepancies = errors.New("balance discrepancies found")
+
+// runCommand runs one of the one-off commands given on the command line.
+func runCommand(db *sqlx.DB, cfg config.Config, logger *slog.Logger, name string, args []string) error {
+	// Migrations are managed by the migrate command itself, every other
+	// command needs an up to date schema
+	if name == "migrate" {
+		return migrate(db, args)
+	}
+	if err := prepareSchema(db, cfg, logger); err != nil {
+		return err
+	}
+
+	switch name {
+	case "serve":
+		serve(db, cfg, logger)
+		return nil
+	case "check-balances":
+		return checkBalances(db, logger, args)
+	case "seed":
+		return seed(db, logger, args)
+	default:
+		return fmt.Errorf("unknown command %q, expected one of serve, migrate, check-balances or seed", name)
+	}
+}
+
+// migrate runs migrations up, rolls back or redoes the latest one, or shows
+// their status, without starting the server.
+func migrate(db *sqlx.DB, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: migrate up|down|redo|status")
+	}
+
+	return DB.RunMigrations(db.DB, args[0])
+}
+
+// checkBalances recomputes every wallet balance from its completed
+// transactions and prints the report as JSON.
+func checkBalances(db *sqlx.DB, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("check-balances", flag.ContinueOnError)
+	freeze := flags.Bool("freeze", false, "freeze every wallet with a discrepancy")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := walletsservice.NewChecker(wallet.NewRepository(db, nil, logger), *freeze, logger).Check(context.Background())
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+
+	if len(report.Discrepancies) > 0 {
+		return errDiscrepancies
+	}
+
+	return nil
+}
+
+// seed fills the database with generated users, wallets and transaction
+// histories for local development and load testing. The same flags generate
+// the same rows, so seeding twice with one seed fails on duplicate ids.
+func seed(db *sqlx.DB, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	users := flags.Int("users", 100, "number of users to generate, each with a wallet")
+	transactions := flags.Int("transactions", 20, "average number of transactions per user")
+	days := flags.Int("days", 90, "how many days of history to generate")
+	randomSeed := flags.Int64("seed", 1, "random seed, for reproducible data")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *users < 0 || *transactions < 0 || *days < 0 {
+		return errors.New("users, transactions and days must not be negative")
+	}
+
+	dataset := fixtures.Generate(fixtures.Options{
+		Users:        *users,
+		Transactions: *transactions,
+		Days:         *days,
+		Seed:         *randomSeed,
+		Now:          time.Now(),
+	})
+	if err := fixtures.Insert(context.Background(), db, dataset); err != nil {
+		return fmt.Errorf("inserting seed data: %w", err)
+	}
+
+	logger.Info("seeded database", "users", len(dataset.Users), "transactions", len(dataset.Transactions), "seed", *randomSeed)
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]int{
+		"users":        len(dataset.Users),
+		"wallets":      len(dataset.Wallets),
+		"transactions": len(dataset.Transactions),
+	})
+}
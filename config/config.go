@@ -0,0 +1,461 @@
+// Package config loads the settings of the service from an optional YAML
+// file and the environment, which takes precedence.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Secret is a setting that must never be logged. It prints as [redacted].
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+// LogValue keeps secrets out of structured logs.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// MarshalYAML keeps secrets out of dumped configuration too.
+func (s Secret) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+// Config is the configuration of the service.
+type Config struct {
+	Server       Server       `yaml:"server"`
+	Database     Database     `yaml:"database"`
+	Transactions Transactions `yaml:"transactions"`
+	Approvals    Approvals    `yaml:"approvals"`
+	Jobs         Jobs         `yaml:"jobs"`
+	Tracing      Tracing      `yaml:"tracing"`
+	Log          Log          `yaml:"log"`
+}
+
+// Server configures the HTTP server.
+type Server struct {
+	Addr         string        `yaml:"addr"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout bounds how long in-flight requests and queued
+	// transactions are given to finish on SIGTERM.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// Database configures the Postgres connection and its pool.
+type Database struct {
+	User     string `yaml:"user"`
+	Password Secret `yaml:"password"`
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"ssl_mode"`
+	// MaxOpenConns must be at least 2, as migrations need two connections.
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	// Retry bounds how transactions that deadlock or fail to serialize are
+	// run again.
+	Retry Retry `yaml:"retry"`
+	// Isolation sets the isolation level of the transactions of each
+	// operation.
+	Isolation Isolation `yaml:"isolation"`
+	// MigrateOnStart runs pending migrations before serving. Without it the
+	// migrate command must be run first, or the server refuses to start.
+	MigrateOnStart bool `yaml:"migrate_on_start"`
+}
+
+// Retry configures the retries of transactions that deadlock or fail to
+// serialize, with a random backoff doubling from BaseDelay up to MaxDelay. It
+// converts to a db.RetryPolicy.
+type Retry struct {
+	// MaxAttempts counts the first run too, so 1 disables retries.
+	MaxAttempts int           `yaml:"max_attempts"`
+	BaseDelay   time.Duration `yaml:"base_delay"`
+	MaxDelay    time.Duration `yaml:"max_delay"`
+}
+
+// Isolation is the isolation level of the transactions of each operation, one
+// of read_committed, repeatable_read or serializable.
+type Isolation struct {
+	CreditWallet string `yaml:"credit_wallet"`
+	DebitWallet  string `yaml:"debit_wallet"`
+	// UnitOfWork covers transactions spanning several repositories, such as
+	// completing a transaction together with its wallet update.
+	UnitOfWork string `yaml:"unit_of_work"`
+}
+
+// OperationLevel is the isolation level of one operation.
+type OperationLevel struct {
+	Operation string
+	Level     string
+}
+
+// Levels lists the isolation level of every operation, named as in the
+// database metrics.
+func (i Isolation) Levels() []OperationLevel {
+	return []OperationLevel{
+		{"credit_wallet", i.CreditWallet},
+		{"debit_wallet", i.DebitWallet},
+		{"unit_of_work", i.UnitOfWork},
+	}
+}
+
+// ByOperation returns the isolation level of every operation keyed by its
+// name.
+func (i Isolation) ByOperation() map[string]string {
+	levels := map[string]string{}
+	for _, level := range i.Levels() {
+		levels[level.Operation] = level.Level
+	}
+	return levels
+}
+
+// Transactions configures the pool that completes async transactions, and
+// which transactions wait for approval.
+type Transactions struct {
+	Workers   int `yaml:"workers"`
+	QueueSize int `yaml:"queue_size"`
+	// ApprovalThreshold is the amount in cents above which a transaction is
+	// held until a second person approves it. 0 holds none.
+	ApprovalThreshold int64 `yaml:"approval_threshold"`
+}
+
+// Approvals configures the operators who make and decide approvals.
+type Approvals struct {
+	// Operators maps the name of every operator to the bearer token they
+	// authenticate with. Approvals are only decided by an operator.
+	Operators map[string]Secret `yaml:"operators"`
+}
+
+// Tokens returns the operator each token authenticates.
+func (a Approvals) Tokens() map[string]string {
+	tokens := map[string]string{}
+	for name, token := range a.Operators {
+		tokens[string(token)] = name
+	}
+	return tokens
+}
+
+// Jobs configures the background jobs.
+type Jobs struct {
+	// SettlementDir is where the provider drops settlement files. The
+	// reconciliation job only runs when it is set.
+	SettlementDir string `yaml:"settlement_dir"`
+	// BalanceCheckFreeze freezes wallets the balance check finds off.
+	BalanceCheckFreeze bool `yaml:"balance_check_freeze"`
+}
+
+// Tracing configures where spans are exported.
+type Tracing struct {
+	// Exporter is one of none, stdout or otlp.
+	Exporter    string `yaml:"exporter"`
+	ServiceName string `yaml:"service_name"`
+	// OTLPEndpoint is the host:port of the OTLP/HTTP collector.
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+	OTLPInsecure bool   `yaml:"otlp_insecure"`
+}
+
+// Log configures the structured logger.
+type Log struct {
+	// Level is one of debug, info, warn or error.
+	Level string `yaml:"level"`
+	// Format is one of json or text.
+	Format string `yaml:"format"`
+}
+
+// URL returns the connection URL of the database, including the password.
+func (d Database) URL() string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.User, string(d.Password)),
+		Host:     d.Host + ":" + d.Port,
+		Path:     d.Name,
+		RawQuery: url.Values{"sslmode": {d.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+// Default returns the configuration used for anything not set in the file or
+// the environment.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:            ":8080",
+			ReadTimeout:     5 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Database: Database{
+			Host:            "localhost",
+			Port:            "5432",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			Retry: Retry{
+				MaxAttempts: 3,
+				BaseDelay:   10 * time.Millisecond,
+				MaxDelay:    200 * time.Millisecond,
+			},
+			Isolation: Isolation{
+				CreditWallet: "read_committed",
+				DebitWallet:  "read_committed",
+				UnitOfWork:   "read_committed",
+			},
+			MigrateOnStart: true,
+		},
+		Transactions: Transactions{
+			Workers:   4,
+			QueueSize: 100,
+		},
+		Tracing: Tracing{
+			Exporter:     "none",
+			ServiceName:  "p-system",
+			OTLPEndpoint: "localhost:4318",
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "json",
+		},
+	}
+}
+
+// Load returns the default configuration overlaid with the YAML file at path,
+// if path is not empty, and then with the environment. The result is
+// validated.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("parsing config file: %w", err)
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// loadEnv overrides the configuration with every variable that is set.
+func (c *Config) loadEnv() error {
+	e := env{}
+
+	e.string("HTTP_ADDR", &c.Server.Addr)
+	e.duration("HTTP_READ_TIMEOUT", &c.Server.ReadTimeout)
+	e.duration("HTTP_WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	e.duration("HTTP_IDLE_TIMEOUT", &c.Server.IdleTimeout)
+	e.duration("SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+
+	e.string("POSTGRES_USER", &c.Database.User)
+	e.secret("POSTGRES_PASSWORD", &c.Database.Password)
+	e.string("POSTGRES_HOST", &c.Database.Host)
+	e.string("POSTGRES_PORT", &c.Database.Port)
+	e.string("POSTGRES_DB", &c.Database.Name)
+	e.string("POSTGRES_SSLMODE", &c.Database.SSLMode)
+	e.int("DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns)
+	e.int("DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns)
+	e.duration("DB_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime)
+	e.duration("DB_CONN_MAX_IDLE_TIME", &c.Database.ConnMaxIdleTime)
+	e.int("DB_RETRY_MAX_ATTEMPTS", &c.Database.Retry.MaxAttempts)
+	e.duration("DB_RETRY_BASE_DELAY", &c.Database.Retry.BaseDelay)
+	e.duration("DB_RETRY_MAX_DELAY", &c.Database.Retry.MaxDelay)
+	e.string("DB_ISOLATION_CREDIT_WALLET", &c.Database.Isolation.CreditWallet)
+	e.string("DB_ISOLATION_DEBIT_WALLET", &c.Database.Isolation.DebitWallet)
+	e.string("DB_ISOLATION_UNIT_OF_WORK", &c.Database.Isolation.UnitOfWork)
+	e.bool("DB_MIGRATE_ON_START", &c.Database.MigrateOnStart)
+
+	e.int("TRANSACTION_WORKERS", &c.Transactions.Workers)
+	e.int("TRANSACTION_QUEUE_SIZE", &c.Transactions.QueueSize)
+	e.int64("TRANSACTION_APPROVAL_THRESHOLD", &c.Transactions.ApprovalThreshold)
+
+	e.secrets("APPROVAL_OPERATORS", &c.Approvals.Operators)
+
+	e.string("SETTLEMENT_DIR", &c.Jobs.SettlementDir)
+	e.bool("BALANCE_CHECK_FREEZE", &c.Jobs.BalanceCheckFreeze)
+
+	e.string("TRACING_EXPORTER", &c.Tracing.Exporter)
+	e.string("TRACING_SERVICE_NAME", &c.Tracing.ServiceName)
+	e.string("TRACING_OTLP_ENDPOINT", &c.Tracing.OTLPEndpoint)
+	e.bool("TRACING_OTLP_INSECURE", &c.Tracing.OTLPInsecure)
+
+	e.string("LOG_LEVEL", &c.Log.Level)
+	e.string("LOG_FORMAT", &c.Log.Format)
+
+	return errors.Join(e...)
+}
+
+// Validate reports every setting that is missing or out of range.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server address is required")
+	check(c.Server.ReadTimeout > 0, "server read timeout must be positive")
+	check(c.Server.WriteTimeout > 0, "server write timeout must be positive")
+	check(c.Server.IdleTimeout > 0, "server idle timeout must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server shutdown timeout must be positive")
+
+	check(c.Database.User != "", "database user is required")
+	check(c.Database.Host != "", "database host is required")
+	check(c.Database.Port != "", "database port is required")
+	check(c.Database.Name != "", "database name is required")
+	// Migrations run on one connection while another holds the migration lock
+	check(c.Database.MaxOpenConns >= 2, "database max open connections must be at least 2")
+	check(c.Database.MaxIdleConns >= 0 && c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database max idle connections must be between 0 and %d", c.Database.MaxOpenConns)
+	check(c.Database.ConnMaxLifetime >= 0, "database connection max lifetime must not be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "database connection max idle time must not be negative")
+	check(c.Database.Retry.MaxAttempts > 0, "database retry max attempts must be positive")
+	check(c.Database.Retry.BaseDelay >= 0, "database retry base delay must not be negative")
+	check(c.Database.Retry.MaxDelay >= c.Database.Retry.BaseDelay, "database retry max delay must not be less than the base delay")
+	for _, level := range c.Database.Isolation.Levels() {
+		switch level.Level {
+		case "read_committed", "repeatable_read", "serializable":
+		default:
+			check(false, "database isolation of %s must be one of read_committed, repeatable_read or serializable", level.Operation)
+		}
+	}
+
+	check(c.Transactions.Workers > 0, "transaction workers must be positive")
+	check(c.Transactions.QueueSize > 0, "transaction queue size must be positive")
+	check(c.Transactions.ApprovalThreshold >= 0, "transaction approval threshold must not be negative")
+
+	tokens := map[Secret]bool{}
+	for name, token := range c.Approvals.Operators {
+		// Approvals held before makers had to be operators are made by
+		// user:<id>
+		check(name != "" && !strings.HasPrefix(name, "user:"), "approval operator name %q is invalid", name)
+		check(len(token) >= 16, "approval operator token of %s must be at least 16 characters", name)
+		check(!tokens[token], "approval operator token of %s is not unique", name)
+		tokens[token] = true
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		check(c.Tracing.OTLPEndpoint != "", "tracing OTLP endpoint is required")
+	default:
+		check(false, "tracing exporter must be one of none, stdout or otlp")
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "log level must be one of debug, info, warn or error")
+	}
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log format must be json or text")
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
+
+// env collects the errors of environment variables that fail to parse.
+type env []error
+
+func (e *env) string(name string, dst *string) {
+	if value, ok := os.LookupEnv(name); ok {
+		*dst = value
+	}
+}
+
+func (e *env) secret(name string, dst *Secret) {
+	if value, ok := os.LookupEnv(name); ok {
+		*dst = Secret(value)
+	}
+}
+
+// secrets parses name=secret pairs separated by commas.
+func (e *env) secrets(name string, dst *map[string]Secret) {
+	if value, ok := os.LookupEnv(name); ok {
+		secrets := map[string]Secret{}
+		for _, pair := range strings.Split(value, ",") {
+			key, secret, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				*e = append(*e, fmt.Errorf("%s must be a comma separated list of name=secret", name))
+				return
+			}
+			secrets[key] = Secret(secret)
+		}
+		*dst = secrets
+	}
+}
+
+func (e *env) int(name string, dst *int) {
+	if value, ok := os.LookupEnv(name); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			*e = append(*e, fmt.Errorf("%s must be an integer", name))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *env) int64(name string, dst *int64) {
+	if value, ok := os.LookupEnv(name); ok {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			*e = append(*e, fmt.Errorf("%s must be an integer", name))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *env) duration(name string, dst *time.Duration) {
+	if value, ok := os.LookupEnv(name); ok {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			*e = append(*e, fmt.Errorf("%s must be a duration such as 30s", name))
+			return
+		}
+		*dst = d
+	}
+}
+
+func (e *env) bool(name string, dst *bool) {
+	if value, ok := os.LookupEnv(name); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			*e = append(*e, fmt.Errorf("%s must be true or false", name))
+			return
+		}
+		*dst = b
+	}
+}
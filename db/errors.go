@@ -0,0 +1,29 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Violations maps the name of a constraint to the error its violation is
+// reported as. Errors not tied to a constraint, such as a value that is not
+// one of an enum's labels, are looked up by the name of their code instead,
+// e.g. invalid_text_representation.
+type Violations map[string]error
+
+// Map returns the error v gives for err if it is a Postgres error for one of
+// its constraints or codes, and err itself otherwise.
+func (v Violations) Map(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	if mapped, ok := v[pqErr.Constraint]; ok && pqErr.Constraint != "" {
+		return mapped
+	}
+	if mapped, ok := v[pqErr.Code.Name()]; ok {
+		return mapped
+	}
+	return err
+}
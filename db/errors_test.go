@@ -0,0 +1,26 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViolationsMap(t *testing.T) {
+	errDuplicate := errors.New("user already has a wallet")
+	errInvalid := errors.New("invalid wallet status")
+	violations := Violations{
+		"wallets_user_id_key":         errDuplicate,
+		"invalid_text_representation": errInvalid,
+	}
+
+	assert.Equal(t, errDuplicate, violations.Map(&pq.Error{Code: "23505", Constraint: "wallets_user_id_key"}))
+	assert.Equal(t, errInvalid, violations.Map(&pq.Error{Code: "22P02", Message: "invalid input value for enum wallet_status"}))
+
+	unmapped := &pq.Error{Code: "23505", Constraint: "transactions_reference_key"}
+	assert.Equal(t, error(unmapped), violations.Map(unmapped))
+	assert.Equal(t, assert.AnError, violations.Map(assert.AnError))
+	assert.Nil(t, violations.Map(nil))
+}
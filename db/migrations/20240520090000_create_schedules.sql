@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE schedules (
+                           id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                           user_id UUID NOT NULL,
+                           counterparty_user_id UUID,
+                           type VARCHAR NOT NULL,
+                           amount BIGINT NOT NULL,
+                           reference VARCHAR(32) NOT NULL UNIQUE,
+                           frequency VARCHAR NOT NULL,
+                           status VARCHAR NOT NULL,
+                           start_at TIMESTAMP NOT NULL,
+                           end_at TIMESTAMP,
+                           next_run_at TIMESTAMP,
+                           occurrence INT NOT NULL DEFAULT 0,
+                           attempts INT NOT NULL DEFAULT 0,
+                           max_attempts INT NOT NULL DEFAULT 3,
+                           last_error VARCHAR,
+                           created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                           updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                           FOREIGN KEY (user_id) REFERENCES users(id),
+                           FOREIGN KEY (counterparty_user_id) REFERENCES users(id)
+);
+
+CREATE INDEX schedules_due_idx ON schedules (next_run_at) WHERE status = 'active';
+
+CREATE TABLE schedule_runs (
+                               id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                               schedule_id UUID NOT NULL,
+                               occurrence INT NOT NULL,
+                               attempt INT NOT NULL,
+                               status VARCHAR NOT NULL,
+                               reference VARCHAR(50) NOT NULL,
+                               error VARCHAR,
+                               ran_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                               FOREIGN KEY (schedule_id) REFERENCES schedules(id)
+);
+
+CREATE INDEX schedule_runs_schedule_id_idx ON schedule_runs (schedule_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE schedule_runs;
+DROP TABLE schedules;
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE batches (
+                         id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                         status VARCHAR NOT NULL,
+                         source VARCHAR NOT NULL,
+                         created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                         updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                         completed_at TIMESTAMP
+);
+
+CREATE TABLE batch_items (
+                             id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                             batch_id UUID NOT NULL,
+                             row_number INT NOT NULL,
+                             user_id UUID NOT NULL,
+                             amount BIGINT NOT NULL,
+                             type VARCHAR NOT NULL,
+                             reference VARCHAR(50) NOT NULL,
+                             status VARCHAR NOT NULL,
+                             error VARCHAR,
+                             updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                             FOREIGN KEY (batch_id) REFERENCES batches(id),
+                             FOREIGN KEY (user_id) REFERENCES users(id),
+                             UNIQUE (batch_id, row_number)
+);
+
+CREATE INDEX batch_items_pending_idx ON batch_items (batch_id, row_number) WHERE status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE batch_items;
+DROP TABLE batches;
+-- +goose StatementEnd
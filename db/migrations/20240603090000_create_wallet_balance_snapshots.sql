@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE wallet_balance_snapshots (
+                                          id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                          wallet_id UUID NOT NULL,
+                                          balance BIGINT NOT NULL,
+                                          taken_at TIMESTAMP NOT NULL,
+                                          created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                                          FOREIGN KEY (wallet_id) REFERENCES wallets(id),
+                                          UNIQUE (wallet_id, taken_at)
+);
+
+CREATE INDEX transactions_user_id_created_at_idx ON transactions (user_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX transactions_user_id_created_at_idx;
+DROP TABLE wallet_balance_snapshots;
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE reconciliations (
+                                 id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                 file_name VARCHAR NOT NULL UNIQUE,
+                                 period_start TIMESTAMP NOT NULL,
+                                 period_end TIMESTAMP NOT NULL,
+                                 matched INT NOT NULL DEFAULT 0,
+                                 missing_on_our_side INT NOT NULL DEFAULT 0,
+                                 missing_on_provider_side INT NOT NULL DEFAULT 0,
+                                 amount_mismatch INT NOT NULL DEFAULT 0,
+                                 created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE reconciliation_items (
+                                      id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                      reconciliation_id UUID NOT NULL,
+                                      category VARCHAR NOT NULL,
+                                      reference VARCHAR NOT NULL,
+                                      transaction_id UUID,
+                                      our_amount BIGINT,
+                                      provider_amount BIGINT,
+                                      note VARCHAR,
+                                      FOREIGN KEY (reconciliation_id) REFERENCES reconciliations(id),
+                                      FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+);
+
+CREATE INDEX reconciliation_items_reconciliation_id_idx ON reconciliation_items (reconciliation_id, category);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE reconciliation_items;
+DROP TABLE reconciliations;
+-- +goose StatementEnd
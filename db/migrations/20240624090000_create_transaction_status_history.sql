@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE transaction_status_history (
+                                            id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                            transaction_id UUID NOT NULL,
+                                            status VARCHAR NOT NULL,
+                                            changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                            FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+);
+
+CREATE INDEX transaction_status_history_transaction_id_idx ON transaction_status_history (transaction_id, changed_at);
+
+-- record every status a transaction passes through, whoever writes it
+CREATE FUNCTION record_transaction_status() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD.status IS NOT DISTINCT FROM NEW.status THEN
+        RETURN NEW;
+    END IF;
+
+    INSERT INTO transaction_status_history (transaction_id, status, changed_at)
+    VALUES (NEW.id, NEW.status, clock_timestamp());
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER transactions_status_history
+    AFTER INSERT OR UPDATE OF status ON transactions
+    FOR EACH ROW
+    EXECUTE FUNCTION record_transaction_status();
+
+-- existing transactions start their history at their current status
+INSERT INTO transaction_status_history (transaction_id, status, changed_at)
+SELECT id, status, COALESCE(updated_at, created_at, CURRENT_TIMESTAMP) FROM transactions;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER transactions_status_history ON transactions;
+DROP FUNCTION record_transaction_status();
+DROP TABLE transaction_status_history;
+-- +goose StatementEnd
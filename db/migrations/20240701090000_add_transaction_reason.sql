@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- adjustments made by hand record why
+ALTER TABLE transactions ADD COLUMN reason VARCHAR;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE transactions DROP COLUMN reason;
+-- +goose StatementEnd
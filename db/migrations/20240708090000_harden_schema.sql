@@ -0,0 +1,63 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE transaction_type AS ENUM ('credit', 'debit');
+CREATE TYPE transaction_status AS ENUM ('pending', 'completed', 'failed');
+CREATE TYPE wallet_status AS ENUM ('active', 'frozen');
+
+-- the status history trigger depends on the column it watches, so it is
+-- recreated around the change of type
+DROP TRIGGER transactions_status_history ON transactions;
+
+ALTER TABLE transactions
+    ALTER COLUMN type TYPE transaction_type USING type::transaction_type,
+    ALTER COLUMN status TYPE transaction_status USING status::transaction_status,
+    ADD CONSTRAINT transactions_amount_positive CHECK (amount > 0);
+
+CREATE TRIGGER transactions_status_history
+    AFTER INSERT OR UPDATE OF status ON transactions
+    FOR EACH ROW
+    EXECUTE FUNCTION record_transaction_status();
+
+-- lookups by user_id are already covered by transactions_user_id_created_at_idx;
+-- stuck transactions are looked up by age
+CREATE INDEX transactions_pending_created_at_idx ON transactions (created_at) WHERE status = 'pending';
+
+-- the old default cannot be cast to the enum, so it is set again afterwards
+ALTER TABLE wallets ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE wallets ALTER COLUMN status TYPE wallet_status USING status::wallet_status;
+ALTER TABLE wallets ALTER COLUMN status SET DEFAULT 'active';
+
+-- the unique constraint doubles as the index on user_id
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_user_id_key UNIQUE (user_id),
+    ADD CONSTRAINT wallets_balance_non_negative CHECK (balance >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE wallets
+    DROP CONSTRAINT wallets_balance_non_negative,
+    DROP CONSTRAINT wallets_user_id_key;
+
+ALTER TABLE wallets ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE wallets ALTER COLUMN status TYPE VARCHAR;
+ALTER TABLE wallets ALTER COLUMN status SET DEFAULT 'active';
+
+DROP INDEX transactions_pending_created_at_idx;
+
+DROP TRIGGER transactions_status_history ON transactions;
+
+ALTER TABLE transactions
+    DROP CONSTRAINT transactions_amount_positive,
+    ALTER COLUMN type TYPE VARCHAR,
+    ALTER COLUMN status TYPE VARCHAR;
+
+CREATE TRIGGER transactions_status_history
+    AFTER INSERT OR UPDATE OF status ON transactions
+    FOR EACH ROW
+    EXECUTE FUNCTION record_transaction_status();
+
+DROP TYPE wallet_status;
+DROP TYPE transaction_status;
+DROP TYPE transaction_type;
+-- +goose StatementEnd
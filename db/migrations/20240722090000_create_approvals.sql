@@ -0,0 +1,77 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE approval_kind AS ENUM ('transaction', 'adjustment');
+CREATE TYPE approval_status AS ENUM ('pending', 'approved', 'rejected', 'executed', 'failed');
+
+CREATE TABLE approvals (
+                           id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                           kind approval_kind NOT NULL,
+                           status approval_status NOT NULL DEFAULT 'pending',
+                           maker VARCHAR NOT NULL,
+                           checker VARCHAR,
+                           amount BIGINT NOT NULL,
+                           payload JSONB NOT NULL,
+                           note VARCHAR,
+                           created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                           decided_at TIMESTAMP,
+                           updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                           CONSTRAINT approvals_checker_not_maker CHECK (checker <> maker)
+);
+
+CREATE INDEX approvals_status_created_at_idx ON approvals (status, created_at);
+
+CREATE TABLE approval_events (
+                                 id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+                                 approval_id UUID NOT NULL,
+                                 status approval_status NOT NULL,
+                                 actor VARCHAR NOT NULL,
+                                 note VARCHAR,
+                                 changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                 FOREIGN KEY (approval_id) REFERENCES approvals(id)
+);
+
+CREATE INDEX approval_events_approval_id_idx ON approval_events (approval_id, changed_at);
+
+-- record every status an approval passes through and who moved it there:
+-- the maker requests it, the checker decides it and their decision runs it
+CREATE FUNCTION record_approval_event() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD.status IS NOT DISTINCT FROM NEW.status THEN
+        RETURN NEW;
+    END IF;
+
+    INSERT INTO approval_events (approval_id, status, actor, note, changed_at)
+    VALUES (NEW.id, NEW.status, COALESCE(NEW.checker, NEW.maker), NEW.note, clock_timestamp());
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER approvals_events
+    AFTER INSERT OR UPDATE OF status ON approvals
+    FOR EACH ROW
+    EXECUTE FUNCTION record_approval_event();
+
+-- the trail is only ever added to
+CREATE FUNCTION forbid_approval_event_changes() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'approval events cannot be changed';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER approval_events_append_only
+    BEFORE UPDATE OR DELETE ON approval_events
+    FOR EACH ROW
+    EXECUTE FUNCTION forbid_approval_event_changes();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER approval_events_append_only ON approval_events;
+DROP FUNCTION forbid_approval_event_changes();
+DROP TRIGGER approvals_events ON approvals;
+DROP FUNCTION record_approval_event();
+DROP TABLE approval_events;
+DROP TABLE approvals;
+DROP TYPE approval_status;
+DROP TYPE approval_kind;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Workers claim batch items and due schedules until claimed_until instead of
+-- locking them while the provider is called
+ALTER TABLE batch_items ADD COLUMN claimed_until TIMESTAMP;
+ALTER TABLE schedules ADD COLUMN claimed_until TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE schedules DROP COLUMN claimed_until;
+ALTER TABLE batch_items DROP COLUMN claimed_until;
+-- +goose StatementEnd
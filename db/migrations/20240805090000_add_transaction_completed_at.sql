@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Historical balances are keyed on when money moved, not when it was asked for
+ALTER TABLE transactions ADD COLUMN completed_at TIMESTAMP;
+
+UPDATE transactions t SET completed_at = h.changed_at
+FROM (SELECT transaction_id, MIN(changed_at) AS changed_at FROM transaction_status_history
+      WHERE status = 'completed' GROUP BY transaction_id) h
+WHERE t.id = h.transaction_id AND t.status = 'completed';
+
+UPDATE transactions SET completed_at = COALESCE(updated_at, created_at)
+WHERE status = 'completed' AND completed_at IS NULL;
+
+-- stamp completed_at whenever a transaction becomes completed, whoever writes it
+CREATE FUNCTION stamp_transaction_completed_at() RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.status = 'completed' AND (TG_OP = 'INSERT' OR OLD.status IS DISTINCT FROM NEW.status) THEN
+        NEW.completed_at = clock_timestamp();
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER transactions_completed_at
+    BEFORE INSERT OR UPDATE OF status ON transactions
+    FOR EACH ROW
+    EXECUTE FUNCTION stamp_transaction_completed_at();
+
+CREATE INDEX transactions_user_id_completed_at_idx ON transactions (user_id, completed_at) WHERE status = 'completed';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX transactions_user_id_completed_at_idx;
+DROP TRIGGER transactions_completed_at ON transactions;
+DROP FUNCTION stamp_transaction_completed_at();
+ALTER TABLE transactions DROP COLUMN completed_at;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- transfers are held as a whole, so both legs are approved or rejected as one
+ALTER TYPE approval_kind ADD VALUE 'transfer';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- enum values cannot be dropped, so the type is rebuilt without it; this fails
+-- while transfer approvals remain
+ALTER TABLE approvals ALTER COLUMN kind TYPE VARCHAR;
+DROP TYPE approval_kind;
+CREATE TYPE approval_kind AS ENUM ('transaction', 'adjustment');
+ALTER TABLE approvals ALTER COLUMN kind TYPE approval_kind USING kind::approval_kind;
+-- +goose StatementEnd
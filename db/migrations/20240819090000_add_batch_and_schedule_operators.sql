@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- batches and schedules made by an operator are made in their name, so items
+-- and runs held for approval are not approved by the same operator
+ALTER TABLE batches ADD COLUMN operator VARCHAR;
+ALTER TABLE schedules ADD COLUMN operator VARCHAR;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE schedules DROP COLUMN operator;
+ALTER TABLE batches DROP COLUMN operator;
+-- +goose StatementEnd
@@ -1,8 +1,11 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"embed"
+	"errors"
+	"fmt"
 
 	"github.com/pressly/goose/v3"
 )
@@ -12,19 +15,120 @@ import (
 //go:embed migrations/*.sql *.go
 var schemaFS embed.FS
 
-func Migrate(db *sql.DB) error {
+// migrationLock is the advisory lock held while migrations run, so replicas
+// starting together take turns and the later ones find nothing left to do.
+// Goose runs on another connection of the pool while the lock is held, so the
+// pool must allow at least two, which config.Validate makes sure of.
+const migrationLock = 7_201_139
+
+// ErrSchemaBehind is returned by CheckSchema when migrations are pending.
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+// setup points goose at the embedded migrations.
+func setup() error {
 	driver := "postgres"
 
 	goose.SetBaseFS(schemaFS)
 
-	if err := goose.SetDialect(driver); err != nil {
+	return goose.SetDialect(driver)
+}
+
+// Migrate runs every pending migration.
+func Migrate(db *sql.DB) error {
+	return withLock(db, func() error {
+		return goose.Up(db, "migrations")
+	})
+}
+
+// MigrateDown rolls back the latest migration.
+func MigrateDown(db *sql.DB) error {
+	return withLock(db, func() error {
+		return goose.Down(db, "migrations")
+	})
+}
+
+// MigrateRedo rolls back the latest migration and runs it again.
+func MigrateRedo(db *sql.DB) error {
+	return withLock(db, func() error {
+		return goose.Redo(db, "migrations")
+	})
+}
+
+// MigrationStatus logs whether each migration has been applied.
+func MigrationStatus(db *sql.DB) error {
+	if err := setup(); err != nil {
 		return err
 	}
 
-	if err := goose.Up(db, "migrations"); err != nil {
+	return goose.Status(db, "migrations")
+}
+
+// RunMigrations runs the migrate command named up, down, redo or status.
+func RunMigrations(db *sql.DB, command string) error {
+	switch command {
+	case "up":
+		return Migrate(db)
+	case "down":
+		return MigrateDown(db)
+	case "redo":
+		return MigrateRedo(db)
+	case "status":
+		return MigrationStatus(db)
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected one of up, down, redo or status", command)
+	}
+}
+
+// CheckSchema returns ErrSchemaBehind if the database has not been migrated
+// up to the latest embedded migration.
+func CheckSchema(db *sql.DB) error {
+	if err := setup(); err != nil {
 		return err
 	}
 
+	current, err := goose.GetDBVersion(db)
+	if err != nil {
+		return err
+	}
+
+	migrations, err := goose.CollectMigrations("migrations", 0, goose.MaxVersion)
+	if err != nil {
+		return err
+	}
+	latest, err := migrations.Last()
+	if err != nil {
+		return err
+	}
+
+	if current < latest.Version {
+		return fmt.Errorf("%w: database is at version %d, migrations go up to %d", ErrSchemaBehind, current, latest.Version)
+	}
 	return nil
+}
+
+// withLock runs fn holding the migration lock.
+func withLock(db *sql.DB, fn func() error) (err error) {
+	if err := setup(); err != nil {
+		return err
+	}
+
+	// The lock belongs to the session, so it is taken and released on one
+	// connection
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLock); err != nil {
+		return fmt.Errorf("taking migration lock: %w", err)
+	}
+	defer func() {
+		if _, unlockErr := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLock); unlockErr != nil && err == nil {
+			err = fmt.Errorf("releasing migration lock: %w", unlockErr)
+		}
+	}()
 
+	return fn()
 }
@@ -0,0 +1,55 @@
+package db_test
+
+import (
+	DB "p-system/db"
+	"p-system/tests"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	db := tests.StartDB(t)
+
+	t.Run("TestCheckSchema_UpToDate", func(t *testing.T) {
+		require.NoError(t, DB.CheckSchema(db.DB))
+	})
+
+	t.Run("TestCheckSchema_Behind", func(t *testing.T) {
+		require.NoError(t, DB.MigrateDown(db.DB))
+
+		require.ErrorIs(t, DB.CheckSchema(db.DB), DB.ErrSchemaBehind)
+	})
+
+	t.Run("TestMigrate_Concurrently", func(t *testing.T) {
+		// Replicas starting together take turns instead of racing
+		var wg sync.WaitGroup
+		errs := make(chan error, 3)
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- DB.Migrate(db.DB)
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			require.NoError(t, err)
+		}
+		require.NoError(t, DB.CheckSchema(db.DB))
+	})
+
+	t.Run("TestMigrateRedo", func(t *testing.T) {
+		require.NoError(t, DB.MigrateRedo(db.DB))
+		require.NoError(t, DB.CheckSchema(db.DB))
+	})
+
+	t.Run("TestRunMigrations_UnknownCommand", func(t *testing.T) {
+		err := DB.RunMigrations(db.DB, "sideways")
+
+		require.EqualError(t, err, `unknown migrate command "sideways", expected one of up, down, redo or status`)
+	})
+}
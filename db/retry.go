@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"p-system/metrics"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Postgres error codes after which the whole transaction can be run again.
+const (
+	codeSerializationFailure = "40001"
+	codeDeadlockDetected     = "40P01"
+)
+
+// RetryPolicy bounds how often, and how far apart, a transaction that
+// deadlocked or failed to serialize is run again.
+type RetryPolicy struct {
+	// MaxAttempts counts the first run too, so 1 never retries.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy is used for operations without a policy of their own.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    200 * time.Millisecond,
+}
+
+// TxPolicy is how the transactions of one operation are run.
+type TxPolicy struct {
+	Isolation sql.IsolationLevel
+	Retry     RetryPolicy
+}
+
+// Policies holds the transaction policy of each operation, keyed by the same
+// names as the database metrics.
+type Policies map[string]TxPolicy
+
+// For returns the policy of operation, or the default isolation level and
+// retry policy if it has none.
+func (p Policies) For(operation string) TxPolicy {
+	if policy, ok := p[operation]; ok {
+		return policy
+	}
+	return TxPolicy{Isolation: sql.LevelDefault, Retry: DefaultRetryPolicy}
+}
+
+// NewPolicies returns the policies of operations run at the isolation levels
+// named in levels, keyed by operation, all retried as retry says.
+func NewPolicies(levels map[string]string, retry RetryPolicy) (Policies, error) {
+	policies := Policies{}
+	for operation, level := range levels {
+		isolation, err := ParseIsolation(level)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", operation, err)
+		}
+		policies[operation] = TxPolicy{Isolation: isolation, Retry: retry}
+	}
+
+	return policies, nil
+}
+
+// ParseIsolation returns the isolation level named read_committed,
+// repeatable_read or serializable.
+func ParseIsolation(level string) (sql.IsolationLevel, error) {
+	switch level {
+	case "read_committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable_read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	}
+	return sql.LevelDefault, fmt.Errorf("unknown isolation level %q", level)
+}
+
+// IsRetryable reports whether err is a Postgres serialization failure or
+// deadlock, after which the whole transaction can be run again.
+func IsRetryable(err error) bool {
+	return retryCode(err) != ""
+}
+
+// retryCode returns the Postgres error code of err if it is retryable.
+func retryCode(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case codeSerializationFailure, codeDeadlockDetected:
+			return string(pqErr.Code)
+		}
+	}
+	return ""
+}
+
+// Retry runs fn until it succeeds, fails with an error that is not
+// retryable, or has run MaxAttempts times. In between it waits a random
+// delay of up to BaseDelay, doubled on every attempt and capped at MaxDelay,
+// so conflicting callers do not collide again in lockstep. It gives up early
+// if ctx is done while waiting. Retries are logged to logger.
+func (p RetryPolicy) Retry(ctx context.Context, operation string, logger *slog.Logger, fn func() error) error {
+	delay := p.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		code := retryCode(err)
+		if code == "" || attempt >= p.MaxAttempts {
+			return err
+		}
+
+		metrics.DBRetries.WithLabelValues(operation, code).Inc()
+		logger.WarnContext(ctx, "retrying transaction", "operation", operation, "attempt", attempt, "error", err)
+
+		var wait time.Duration
+		if delay > 0 {
+			wait = time.Duration(rand.Int63n(int64(delay) + 1))
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		delay *= 2
+		if delay > p.MaxDelay {
+			delay = p.MaxDelay
+		}
+	}
+}
+
+// RetryTx runs fn in a transaction on q like InTx, with the isolation level
+// of policy, and runs the whole transaction again as policy allows when it
+// deadlocks or fails to serialize. When q is already a transaction fn joins
+// it and is not retried here: the failed statement aborted the transaction,
+// so only whoever began it can run it again.
+func RetryTx(ctx context.Context, q Queryer, operation string, policy TxPolicy, logger *slog.Logger, fn func(Queryer) error) error {
+	if _, ok := q.(beginner); !ok {
+		return fn(q)
+	}
+
+	opts := &sql.TxOptions{Isolation: policy.Isolation}
+	return policy.Retry.Retry(ctx, operation, logger, func() error {
+		return InTx(ctx, q, opts, fn)
+	})
+}
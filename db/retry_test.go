@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"p-system/logging"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	deadlock             = &pq.Error{Code: "40P01", Message: "deadlock detected"}
+	serializationFailure = &pq.Error{Code: "40001", Message: "could not serialize access"}
+)
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(deadlock))
+	assert.True(t, IsRetryable(serializationFailure))
+	assert.True(t, IsRetryable(errors.Join(errors.New("debiting wallet"), deadlock)))
+	assert.False(t, IsRetryable(&pq.Error{Code: "23505"}))
+	assert.False(t, IsRetryable(sql.ErrNoRows))
+	assert.False(t, IsRetryable(nil))
+}
+
+func TestRetry_RetriesUntilSuccess(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	attempts := 0
+	err := policy.Retry(context.Background(), "debit_wallet", logging.Discard(), func() error {
+		attempts++
+		if attempts == 1 {
+			return deadlock
+		}
+		if attempts == 2 {
+			return serializationFailure
+		}
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestRetry_GivesUpAfterMaxAttempts(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	attempts := 0
+	err := policy.Retry(context.Background(), "debit_wallet", logging.Discard(), func() error {
+		attempts++
+		return deadlock
+	})
+
+	require.ErrorIs(t, err, deadlock)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestRetry_DoesNotRetryOtherErrors(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+	errBoom := errors.New("boom")
+
+	attempts := 0
+	err := policy.Retry(context.Background(), "debit_wallet", logging.Discard(), func() error {
+		attempts++
+		return errBoom
+	})
+
+	require.ErrorIs(t, err, errBoom)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetry_StopsWaitingWhenContextIsDone(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	err := policy.Retry(ctx, "debit_wallet", logging.Discard(), func() error {
+		attempts++
+		return deadlock
+	})
+
+	require.ErrorIs(t, err, deadlock)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestNewPolicies(t *testing.T) {
+	retry := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	policies, err := NewPolicies(map[string]string{
+		"credit_wallet": "read_committed",
+		"debit_wallet":  "serializable",
+		"unit_of_work":  "read_committed",
+	}, retry)
+
+	require.NoError(t, err)
+	assert.Equal(t, sql.LevelSerializable, policies.For("debit_wallet").Isolation)
+	assert.Equal(t, sql.LevelReadCommitted, policies.For("credit_wallet").Isolation)
+	assert.Equal(t, 5, policies.For("unit_of_work").Retry.MaxAttempts)
+	assert.Equal(t, TxPolicy{Isolation: sql.LevelDefault, Retry: DefaultRetryPolicy}, policies.For("balance_at"))
+	assert.Equal(t, DefaultRetryPolicy, Policies(nil).For("debit_wallet").Retry)
+
+	_, err = NewPolicies(map[string]string{"debit_wallet": "snapshot"}, retry)
+	require.EqualError(t, err, `debit_wallet: unknown isolation level "snapshot"`)
+}
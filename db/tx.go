@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Queryer is what repositories run statements on: either the database itself
+// or the transaction of a unit of work. Both *sqlx.DB and *sqlx.Tx satisfy it.
+type Queryer interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+}
+
+// beginner is a Queryer that can begin a transaction, i.e. not one already.
+type beginner interface {
+	BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
+}
+
+// InTx runs fn in a transaction on q, committing if fn succeeds and rolling
+// back if it returns an error or panics. When q is already a transaction fn
+// joins it, and whoever began it decides whether to commit; opts are then
+// ignored.
+func InTx(ctx context.Context, q Queryer, opts *sql.TxOptions, fn func(Queryer) error) (err error) {
+	b, ok := q.(beginner)
+	if !ok {
+		return fn(q)
+	}
+
+	tx, err := b.BeginTxx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+			}
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,193 @@
+// Package fixtures builds users, wallets and transactions for tests, and
+// generates whole datasets of them for local development and load testing.
+package fixtures
+
+import (
+	"p-system/repositories/transaction"
+	"p-system/repositories/user"
+	"p-system/repositories/wallet"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserBuilder builds a user, with defaults for anything not set.
+type UserBuilder struct {
+	u user.User
+}
+
+// User starts building a user with a random id.
+func User() *UserBuilder {
+	id := uuid.NewString()
+	return &UserBuilder{u: user.User{
+		ID:        id,
+		Username:  "user_" + id[:8],
+		Email:     "user_" + id[:8] + "@example.com",
+		Password:  "hashed_password_here",
+		CreatedAt: time.Now(),
+	}}
+}
+
+// WithID sets the id.
+func (b *UserBuilder) WithID(id string) *UserBuilder {
+	b.u.ID = id
+	return b
+}
+
+// WithUsername sets the username.
+func (b *UserBuilder) WithUsername(username string) *UserBuilder {
+	b.u.Username = username
+	return b
+}
+
+// WithEmail sets the email address.
+func (b *UserBuilder) WithEmail(email string) *UserBuilder {
+	b.u.Email = email
+	return b
+}
+
+// CreatedAt sets when the user signed up.
+func (b *UserBuilder) CreatedAt(at time.Time) *UserBuilder {
+	b.u.CreatedAt = at
+	return b
+}
+
+// Build returns the user.
+func (b *UserBuilder) Build() user.User {
+	return b.u
+}
+
+// WalletBuilder builds a wallet, with defaults for anything not set.
+type WalletBuilder struct {
+	w wallet.Wallet
+}
+
+// Wallet starts building an active, empty wallet of the given user.
+func Wallet(userID string) *WalletBuilder {
+	now := time.Now()
+	return &WalletBuilder{w: wallet.Wallet{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Status:    wallet.StatusActive,
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}}
+}
+
+// WithID sets the id.
+func (b *WalletBuilder) WithID(id string) *WalletBuilder {
+	b.w.ID = id
+	return b
+}
+
+// WithBalance sets the balance, in cents.
+func (b *WalletBuilder) WithBalance(balance int64) *WalletBuilder {
+	b.w.Balance = balance
+	return b
+}
+
+// Frozen freezes the wallet.
+func (b *WalletBuilder) Frozen() *WalletBuilder {
+	b.w.Status = wallet.StatusFrozen
+	return b
+}
+
+// LastTransaction records the transaction that last changed the balance.
+func (b *WalletBuilder) LastTransaction(t transaction.Transaction) *WalletBuilder {
+	b.w.TransactionID = &t.ID
+	b.w.UpdatedAt = t.UpdatedAt
+	return b
+}
+
+// CreatedAt sets when the wallet was opened.
+func (b *WalletBuilder) CreatedAt(at time.Time) *WalletBuilder {
+	b.w.CreatedAt = at
+	b.w.UpdatedAt = at
+	return b
+}
+
+// Build returns the wallet.
+func (b *WalletBuilder) Build() wallet.Wallet {
+	return b.w
+}
+
+// TransactionBuilder builds a transaction, with defaults for anything not
+// set.
+type TransactionBuilder struct {
+	t transaction.Transaction
+}
+
+// Transaction starts building a pending credit of 1000 cents for the given
+// user, with a random reference.
+func Transaction(userID string) *TransactionBuilder {
+	id := uuid.NewString()
+	now := time.Now()
+	return &TransactionBuilder{t: transaction.Transaction{
+		ID:        id,
+		UserID:    userID,
+		RequestID: uuid.NewString(),
+		Amount:    1000,
+		Status:    "pending",
+		Type:      "credit",
+		Reference: "fixture-" + id[:8],
+		CreatedAt: now,
+		UpdatedAt: now,
+	}}
+}
+
+// WithID sets the id.
+func (b *TransactionBuilder) WithID(id string) *TransactionBuilder {
+	b.t.ID = id
+	return b
+}
+
+// WithRequestID sets the id of the request that made the transaction.
+func (b *TransactionBuilder) WithRequestID(requestID string) *TransactionBuilder {
+	b.t.RequestID = requestID
+	return b
+}
+
+// WithReference sets the reference, which must be unique.
+func (b *TransactionBuilder) WithReference(reference string) *TransactionBuilder {
+	b.t.Reference = reference
+	return b
+}
+
+// Credit makes the transaction a credit of amount cents.
+func (b *TransactionBuilder) Credit(amount int64) *TransactionBuilder {
+	b.t.Type = "credit"
+	b.t.Amount = amount
+	return b
+}
+
+// Debit makes the transaction a debit of amount cents.
+func (b *TransactionBuilder) Debit(amount int64) *TransactionBuilder {
+	b.t.Type = "debit"
+	b.t.Amount = amount
+	return b
+}
+
+// Completed marks the transaction completed.
+func (b *TransactionBuilder) Completed() *TransactionBuilder {
+	b.t.Status = "completed"
+	return b
+}
+
+// Failed marks the transaction failed.
+func (b *TransactionBuilder) Failed() *TransactionBuilder {
+	b.t.Status = "failed"
+	return b
+}
+
+// At sets when the transaction was created and last updated.
+func (b *TransactionBuilder) At(at time.Time) *TransactionBuilder {
+	b.t.CreatedAt = at
+	b.t.UpdatedAt = at
+	return b
+}
+
+// Build returns the transaction.
+func (b *TransactionBuilder) Build() transaction.Transaction {
+	return b.t
+}
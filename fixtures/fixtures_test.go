@@ -0,0 +1,73 @@
+package fixtures
+
+import (
+	"context"
+	"p-system/repositories/memory"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+func TestGenerate_IsDeterministic(t *testing.T) {
+	opts := Options{Users: 5, Transactions: 10, Days: 30, Seed: 42, Now: now}
+
+	first := Generate(opts)
+	second := Generate(opts)
+
+	assert.Equal(t, first, second)
+	assert.Len(t, first.Users, 5)
+	assert.Len(t, first.Wallets, 5)
+
+	opts.Seed = 43
+	assert.NotEqual(t, first, Generate(opts))
+}
+
+func TestGenerate_BalancesAddUp(t *testing.T) {
+	d := Generate(Options{Users: 20, Transactions: 30, Days: 90, Seed: 7, Now: now})
+
+	balances := map[string]int64{}
+	for _, tr := range d.Transactions {
+		require.False(t, tr.CreatedAt.After(now))
+		if tr.Status != "completed" {
+			continue
+		}
+
+		if tr.Type == "debit" {
+			balances[tr.UserID] -= tr.Amount
+		} else {
+			balances[tr.UserID] += tr.Amount
+		}
+		require.GreaterOrEqual(t, balances[tr.UserID], int64(0), "debit %s overdraws the wallet", tr.ID)
+	}
+
+	for _, w := range d.Wallets {
+		assert.Equal(t, balances[w.UserID], w.Balance)
+	}
+
+	// The balance check agrees
+	store := memory.NewStore()
+	Put(store, d)
+
+	discrepancies, err := store.Wallets().CheckBalances(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, discrepancies)
+}
+
+func TestBuilders(t *testing.T) {
+	u := User().WithID("user123").Build()
+	w := Wallet(u.ID).WithBalance(500).Frozen().Build()
+	tr := Transaction(u.ID).Debit(200).Completed().At(now).Build()
+
+	assert.Equal(t, "user123", w.UserID)
+	assert.Equal(t, int64(500), w.Balance)
+	assert.Equal(t, "frozen", w.Status)
+	assert.Equal(t, "debit", tr.Type)
+	assert.Equal(t, int64(200), tr.Amount)
+	assert.Equal(t, "completed", tr.Status)
+	assert.Equal(t, now, tr.CreatedAt)
+	assert.NotEqual(t, Transaction(u.ID).Build().Reference, Transaction(u.ID).Build().Reference)
+}
@@ -0,0 +1,126 @@
+package fixtures
+
+import (
+	"fmt"
+	"math/rand"
+	"p-system/repositories/transaction"
+	"p-system/repositories/user"
+	"p-system/repositories/wallet"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	firstNames = []string{"Ada", "Amara", "Chidi", "Emeka", "Fatima", "Grace", "Ibrahim", "Kofi", "Lina", "Musa", "Ngozi", "Tunde", "Yusuf", "Zainab"}
+	lastNames  = []string{"Adeyemi", "Bello", "Eze", "Mensah", "Nwosu", "Okafor", "Okoro", "Owusu", "Salami", "Usman"}
+)
+
+// Options sizes a generated dataset.
+type Options struct {
+	// Users is how many users to generate, each with one wallet.
+	Users int
+	// Transactions is the average number of transactions per user.
+	Transactions int
+	// Days is how far back the history of every user goes.
+	Days int
+	// Seed makes the dataset reproducible: the same options give the same
+	// dataset.
+	Seed int64
+	// Now is when the history ends.
+	Now time.Time
+}
+
+// Dataset is a set of generated rows, in the order they must be inserted.
+type Dataset struct {
+	Users        []user.User
+	Transactions []transaction.Transaction
+	Wallets      []wallet.Wallet
+}
+
+// Generate returns users with a wallet each and a history of credits and
+// debits. Most transactions are completed, some failed and a few still
+// pending. Debits never overdraw a wallet, and every balance adds up to the
+// completed transactions of its user, so the balance check passes on them.
+func Generate(opts Options) Dataset {
+	rng := rand.New(rand.NewSource(opts.Seed))
+	newID := func() string {
+		return uuid.Must(uuid.NewRandomFromReader(rng)).String()
+	}
+
+	start := opts.Now.AddDate(0, 0, -opts.Days)
+	span := opts.Now.Sub(start)
+
+	var d Dataset
+	for i := 0; i < opts.Users; i++ {
+		first := firstNames[rng.Intn(len(firstNames))]
+		last := lastNames[rng.Intn(len(lastNames))]
+		username := fmt.Sprintf("%s_%s%d", strings.ToLower(first), strings.ToLower(last), i)
+		signedUp := start
+		if span >= 10 {
+			signedUp = start.Add(time.Duration(rng.Int63n(int64(span) / 10)))
+		}
+
+		u := User().
+			WithID(newID()).
+			WithUsername(username).
+			WithEmail(username + "@example.com").
+			CreatedAt(signedUp).
+			Build()
+		d.Users = append(d.Users, u)
+
+		w := Wallet(u.ID).WithID(newID()).CreatedAt(signedUp)
+
+		// Spread the transactions of the user evenly at random over the
+		// rest of the period
+		count := 0
+		if opts.Transactions > 0 {
+			count = rng.Intn(2*opts.Transactions + 1)
+		}
+		times := make([]time.Time, count)
+		for j := range times {
+			times[j] = signedUp.Add(time.Duration(rng.Int63n(int64(opts.Now.Sub(signedUp)) + 1)))
+		}
+		sort.Slice(times, func(a, b int) bool { return times[a].Before(times[b]) })
+
+		var balance int64
+		for _, at := range times {
+			b := Transaction(u.ID).
+				WithID(newID()).
+				WithRequestID(newID()).
+				WithReference(fmt.Sprintf("seed-%016x", rng.Uint64())).
+				At(at)
+
+			// Debit up to half the balance, otherwise credit 5 to 500
+			if balance > 0 && rng.Intn(3) == 0 {
+				b.Debit(1 + rng.Int63n(balance/2+1))
+			} else {
+				b.Credit(500 + 100*rng.Int63n(496))
+			}
+
+			switch roll := rng.Intn(100); {
+			case roll < 90:
+				b.Completed()
+			case roll < 97:
+				b.Failed()
+			}
+
+			t := b.Build()
+			if t.Status == "completed" {
+				if t.Type == "debit" {
+					balance -= t.Amount
+				} else {
+					balance += t.Amount
+				}
+				w.LastTransaction(t)
+			}
+			d.Transactions = append(d.Transactions, t)
+		}
+
+		d.Wallets = append(d.Wallets, w.WithBalance(balance).Build())
+	}
+
+	return d
+}
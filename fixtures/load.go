@@ -0,0 +1,90 @@
+package fixtures
+
+import (
+	"context"
+	DB "p-system/db"
+	"p-system/repositories/memory"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// batchSize is how many rows are inserted per statement, well within the
+// 65535 parameters Postgres allows.
+const batchSize = 1000
+
+// Insert writes d to the database in one transaction, keeping the ids and
+// timestamps it was generated with.
+func Insert(ctx context.Context, db DB.Queryer, d Dataset) error {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	return DB.InTx(ctx, db, nil, func(tx DB.Queryer) error {
+		exec := func(query sq.InsertBuilder) error {
+			q, args, err := query.ToSql()
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, q, args...)
+			return err
+		}
+
+		for _, users := range chunks(len(d.Users)) {
+			query := psql.Insert("users").Columns("id", "username", "email", "password", "created_at")
+			for _, u := range d.Users[users.from:users.to] {
+				query = query.Values(u.ID, u.Username, u.Email, u.Password, u.CreatedAt)
+			}
+			if err := exec(query); err != nil {
+				return err
+			}
+		}
+
+		// Transactions go before wallets, which reference the last of them
+		for _, transactions := range chunks(len(d.Transactions)) {
+			query := psql.Insert("transactions").Columns("id", "user_id", "request_id", "amount", "type", "status", "reference", "created_at", "updated_at")
+			for _, t := range d.Transactions[transactions.from:transactions.to] {
+				query = query.Values(t.ID, t.UserID, t.RequestID, t.Amount, t.Type, t.Status, t.Reference, t.CreatedAt, t.UpdatedAt)
+			}
+			if err := exec(query); err != nil {
+				return err
+			}
+		}
+
+		for _, wallets := range chunks(len(d.Wallets)) {
+			query := psql.Insert("wallets").Columns("id", "user_id", "balance", "status", "created_at", "updated_at", "transaction_id")
+			for _, w := range d.Wallets[wallets.from:wallets.to] {
+				query = query.Values(w.ID, w.UserID, w.Balance, w.Status, w.CreatedAt, w.UpdatedAt, w.TransactionID)
+			}
+			if err := exec(query); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Put adds d to an in-memory store.
+func Put(store *memory.Store, d Dataset) {
+	for _, u := range d.Users {
+		store.PutUser(u)
+	}
+	for _, t := range d.Transactions {
+		store.PutTransaction(t)
+	}
+	for _, w := range d.Wallets {
+		store.PutWallet(w)
+	}
+}
+
+// batch is a range [from, to) of rows.
+type batch struct {
+	from, to int
+}
+
+// chunks splits n rows into batches.
+func chunks(n int) []batch {
+	var spans []batch
+	for from := 0; from < n; from += batchSize {
+		spans = append(spans, batch{from: from, to: min(from+batchSize, n)})
+	}
+	return spans
+}
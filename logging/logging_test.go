@@ -0,0 +1,104 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"p-system/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// decode returns the single JSON record written to buf.
+func decode(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	return record
+}
+
+func TestNew_RedactsAmountsAndPersonalData(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, config.Log{Level: "info", Format: "json"})
+	require.NoError(t, err)
+
+	logger.Info("payment failed",
+		"transaction_id", "tx1",
+		"amount", 10000,
+		slog.Group("wallet", "Balance", 500),
+		"email", "jane@example.com",
+		"password", config.Secret("s3cret"),
+		"username", "jane",
+		"reason", "refund for Jane Doe",
+	)
+
+	record := decode(t, &buf)
+	assert.Equal(t, "tx1", record["transaction_id"])
+	assert.Equal(t, "[redacted]", record["amount"])
+	assert.Equal(t, map[string]interface{}{"Balance": "[redacted]"}, record["wallet"])
+	assert.Equal(t, "[redacted]", record["email"])
+	assert.Equal(t, "[redacted]", record["username"])
+	assert.Equal(t, "[redacted]", record["reason"])
+	assert.NotContains(t, buf.String(), "s3cret")
+}
+
+func TestNew_AddsRequestIDAndTrace(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, config.Log{Level: "info", Format: "json"})
+	require.NoError(t, err)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	ctx = WithRequestID(ctx, "req-1")
+
+	logger.With("job", "test").InfoContext(ctx, "hello")
+
+	record := decode(t, &buf)
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", record["trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", record["span_id"])
+	assert.Equal(t, "test", record["job"])
+}
+
+func TestNew_RejectsUnknownSettings(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, config.Log{Level: "loud", Format: "json"})
+	assert.Error(t, err)
+
+	_, err = New(&bytes.Buffer{}, config.Log{Level: "info", Format: "xml"})
+	assert.Error(t, err)
+}
+
+func TestMiddleware_PropagatesRequestID(t *testing.T) {
+	var seen string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+	}))
+
+	// A valid ID sent by the client is kept
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "client-id.1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, "client-id.1", seen)
+	assert.Equal(t, "client-id.1", rec.Header().Get(RequestIDHeader))
+
+	// Anything else is replaced with a generated one
+	for _, id := range []string{"", "has spaces", "<script>"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, id)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.NotEqual(t, id, seen)
+		assert.Len(t, seen, 36)
+		assert.Equal(t, seen, rec.Header().Get(RequestIDHeader))
+	}
+}
@@ -4,12 +4,35 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
+	"math"
 	"net/http"
 	"os"
+	"os/signal"
+	"p-system/config"
+	"p-system/logging"
+	"p-system/metrics"
+	"p-system/repositories/approval"
+	"p-system/repositories/batch"
+	"p-system/repositories/reconciliation"
+	"p-system/repositories/schedule"
 	"p-system/repositories/transaction"
+	"p-system/repositories/unitofwork"
 	"p-system/repositories/user"
 	"p-system/repositories/wallet"
+	"p-system/services/adminservice"
+	"p-system/services/approvalsservice"
+	"p-system/services/batchesservice"
+	"p-system/services/reconciliationsservice"
+	"p-system/services/schedulesservice"
+	"p-system/services/statementsservice"
+	"p-system/services/thirdparty"
 	"p-system/services/transactionsservice"
+	"p-system/services/walletsservice"
+	"p-system/tracing"
+	"p-system/utils"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -25,23 +48,33 @@ func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Print("No .env file found")
 	}
-	userName := os.Getenv("POSTGRES_USER")
-	password := os.Getenv("POSTGRES_PASSWORD")
-	host := os.Getenv("POSTGRES_HOST")
-	port := os.Getenv("POSTGRES_PORT")
-	dbName := os.Getenv("POSTGRES_DB")
 
-	// Set up database connection
-	dbUrl := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", userName, password, host, port, dbName)
+	// Load configuration from the optional file and the environment
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	fmt.Println(dbUrl)
+	// Log structured records; the standard logger is routed through it too
+	logger, err := logging.New(os.Stderr, cfg.Log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+	logger.Info("loaded config", "config", fmt.Sprintf("%+v", cfg))
 
-	db, err := sqlx.Connect("postgres", dbUrl)
+	// Set up database connection
+	db, err := sqlx.Connect("postgres", cfg.Database.URL())
 	if err != nil {
 		log.Fatalf("Error connecting to database: %v", err)
 	}
 	defer db.Close()
 
+	db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.Database.ConnMaxIdleTime)
+
 	// Ping database to verify connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -49,30 +82,205 @@ func main() {
 		log.Fatalf("Error pinging database: %v", err)
 	}
 
-	// Run Goose migrations
-	if err := DB.Migrate(db.DB); err != nil {
-		log.Fatalf("Error running migrations: %v", err)
+	// Run a one-off command instead of the server if one is given
+	name, args := "serve", []string{}
+	if len(os.Args) > 1 {
+		name, args = os.Args[1], os.Args[2:]
+	}
+	if err := runCommand(db, cfg, logger, name, args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// prepareSchema runs pending migrations if configured to, and refuses to go
+// on with a schema older than the code expects.
+func prepareSchema(db *sqlx.DB, cfg config.Config, logger *slog.Logger) error {
+	if cfg.Database.MigrateOnStart {
+		if err := DB.Migrate(db.DB); err != nil {
+			return fmt.Errorf("running migrations: %w", err)
+		}
+		logger.Info("migrations ran successfully")
+	}
+
+	if err := DB.CheckSchema(db.DB); err != nil {
+		return fmt.Errorf("refusing to start, run the migrate command first: %w", err)
+	}
+	return nil
+}
+
+// serve wires up the services and background jobs and runs the HTTP server.
+func serve(db *sqlx.DB, cfg config.Config, logger *slog.Logger) {
+	// Trace requests, repository calls and provider calls
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Error setting up tracing: %v", err)
+	}
+
+	// Isolation levels and retries of the transactions of each operation
+	policies, err := DB.NewPolicies(cfg.Database.Isolation.ByOperation(), DB.RetryPolicy(cfg.Database.Retry))
+	if err != nil {
+		log.Fatalf("Error reading transaction policies: %v", err)
 	}
-	log.Println("Migrations ran successfully")
 
 	// Initialize service with repositories and other dependencies
 	userRepo := user.NewRepository(db)
-	walletRepo := wallet.NewRepository(db)
+	walletRepo := wallet.NewRepository(db, policies, logger)
 	transactionRepo := transaction.NewRepository(db)
-	svc := transactionsservice.NewService(userRepo, transactionRepo, walletRepo)
+	scheduleRepo := schedule.NewRepository(db)
+	batchRepo := batch.NewRepository(db)
+	reconciliationRepo := reconciliation.NewRepository(db)
+	approvalRepo := approval.NewRepository(db)
+	pool := transactionsservice.NewPool(cfg.Transactions.Workers, cfg.Transactions.QueueSize, logger)
+	uow := unitofwork.New(db, policies, logger)
+	svc := transactionsservice.NewService(userRepo, transactionRepo, walletRepo, uow, thirdparty.NewService(), pool, approvalRepo, cfg.Transactions.ApprovalThreshold, logger)
+	scheduleSvc := schedulesservice.NewService(scheduleRepo, userRepo)
+	batchSvc := batchesservice.NewService(batchRepo, userRepo, transactionRepo, logger)
+	statementSvc := statementsservice.NewService(uow)
+	walletSvc := walletsservice.NewService(walletRepo)
+	reconciliationSvc := reconciliationsservice.NewService(reconciliationRepo, transactionRepo)
+	adminSvc := adminservice.NewService(userRepo, walletRepo, transactionRepo, uow, svc, approvalRepo, logger)
+	approvalSvc := approvalsservice.NewService(approvalRepo, transactionRepo, svc, adminSvc, logger)
+
+	// Stop background jobs and drain the server on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Complete transactions submitted in async mode in the background
+	pool.Start(svc)
+
+	// Background jobs are waited for on shutdown so none is cut off halfway
+	// through a transaction
+	var jobs jobGroup
+
+	// Run due schedules in the background
+	worker := schedulesservice.NewWorker(scheduleRepo, transactionRepo, svc, logger)
+	jobs.run(ctx, worker.Start)
+
+	// Process submitted batches in the background
+	processor := batchesservice.NewProcessor(batchRepo, transactionRepo, svc, logger)
+	jobs.run(ctx, processor.Start)
+
+	// Snapshot wallet balances in the background
+	snapshotter := walletsservice.NewSnapshotter(walletRepo, logger)
+	jobs.run(ctx, snapshotter.Start)
+
+	// Reconcile settlement files dropped by the provider, if configured
+	if cfg.Jobs.SettlementDir != "" {
+		job := reconciliationsservice.NewJob(reconciliationSvc, cfg.Jobs.SettlementDir, logger)
+		jobs.run(ctx, job.Start)
+	}
+
+	// Check wallet balances against their transactions once a day
+	checker := walletsservice.NewChecker(walletRepo, cfg.Jobs.BalanceCheckFreeze, logger)
+	jobs.run(ctx, checker.Start)
+
+	// Report the transactions not yet completed on every scrape
+	metrics.RegisterGauge("pending_transactions", "Transactions recorded but not yet completed or failed.", func() float64 {
+		count, err := transactionRepo.CountByStatus(context.Background(), "pending")
+		if err != nil {
+			logger.Error("counting pending transactions", "error", err)
+			return math.NaN()
+		}
+		return float64(count)
+	})
+	metrics.RegisterGauge("transaction_queue_length", "Async transactions waiting for a worker.", func() float64 {
+		return float64(pool.Len())
+	})
 
 	// Create a new router
 	r := mux.NewRouter()
+	r.Use(logging.Middleware, tracing.Middleware, metrics.Middleware, utils.Operators(cfg.Approvals.Tokens()))
 
 	// Define routes
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
 	r.HandleFunc("/transactions", svc.HandleTransaction).Methods("POST")
+	r.HandleFunc("/transactions", svc.FindTransaction).Methods("GET")
+	r.HandleFunc("/transactions/{id}", svc.GetTransaction).Methods("GET")
+	r.HandleFunc("/schedules", scheduleSvc.CreateSchedule).Methods("POST")
+	r.HandleFunc("/schedules", scheduleSvc.ListSchedules).Methods("GET")
+	r.HandleFunc("/schedules/{id}", scheduleSvc.GetSchedule).Methods("GET")
+	r.HandleFunc("/schedules/{id}", scheduleSvc.UpdateSchedule).Methods("PATCH")
+	r.HandleFunc("/schedules/{id}", scheduleSvc.CancelSchedule).Methods("DELETE")
+	r.HandleFunc("/schedules/{id}/runs", scheduleSvc.GetScheduleRuns).Methods("GET")
+	r.HandleFunc("/batches", batchSvc.CreateBatch).Methods("POST")
+	r.HandleFunc("/batches/{id}", batchSvc.GetBatch).Methods("GET")
+	r.HandleFunc("/batches/{id}/items", batchSvc.GetBatchItems).Methods("GET")
+	r.HandleFunc("/batches/{id}/report", batchSvc.GetBatchReport).Methods("GET")
+	r.HandleFunc("/wallets/{id}/statement", statementSvc.GetStatement).Methods("GET")
+	r.HandleFunc("/wallets/{id}", walletSvc.GetWallet).Methods("GET")
+	r.HandleFunc("/wallets/{id}/balance", walletSvc.GetBalance).Methods("GET")
+	r.HandleFunc("/reconciliations", reconciliationSvc.CreateReconciliation).Methods("POST")
+	r.HandleFunc("/reconciliations", reconciliationSvc.ListReconciliations).Methods("GET")
+	r.HandleFunc("/reconciliations/{id}", reconciliationSvc.GetReconciliation).Methods("GET")
+	r.HandleFunc("/reconciliations/{id}/items", reconciliationSvc.GetReconciliationItems).Methods("GET")
+	r.HandleFunc("/approvals", approvalSvc.ListApprovals).Methods("GET")
+	r.HandleFunc("/approvals/{id}", approvalSvc.GetApproval).Methods("GET")
+	r.HandleFunc("/approvals/{id}/approve", approvalSvc.ApproveApproval).Methods("POST")
+	r.HandleFunc("/approvals/{id}/reject", approvalSvc.RejectApproval).Methods("POST")
 
 	// Create a server instance
 	server := &http.Server{
-		Addr:    ":8080",
-		Handler: r,
+		Addr:         cfg.Server.Addr,
+		Handler:      r,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+
+	go func() {
+		logger.Info("server started", "addr", cfg.Server.Addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Info("shutting down")
+
+	// Finish in-flight requests first so no more transactions are queued,
+	// then let the pool complete the queued ones
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("shutting down server", "error", err)
+	}
+	if err := pool.Drain(shutdownCtx); err != nil {
+		logger.Error("draining transaction queue", "error", err)
+	}
+	if err := jobs.wait(shutdownCtx); err != nil {
+		logger.Error("waiting for background jobs", "error", err)
 	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("flushing traces", "error", err)
+	}
+}
 
-	log.Println("Server started on port 8080")
-	log.Fatal(server.ListenAndServe())
+// jobGroup tracks the background jobs started by serve.
+type jobGroup struct {
+	wg sync.WaitGroup
+}
+
+// run starts a job that returns once ctx is cancelled.
+func (g *jobGroup) run(ctx context.Context, start func(context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		start(ctx)
+	}()
+}
+
+// wait waits for every job to return, or for ctx to be done.
+func (g *jobGroup) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -0,0 +1,73 @@
+package approval
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// KindTransaction holds a transaction request until it is approved.
+	KindTransaction = "transaction"
+	// KindAdjustment holds a manual adjustment by ops until it is approved.
+	KindAdjustment = "adjustment"
+	// KindTransfer holds both legs of a transfer until it is approved.
+	KindTransfer = "transfer"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+	// StatusExecuted and StatusFailed are the outcome of running an approved
+	// request.
+	StatusExecuted = "executed"
+	StatusFailed   = "failed"
+)
+
+// Approval is a request held until someone other than its maker, the checker,
+// approves or rejects it.
+type Approval struct {
+	ID      string  `json:"id" db:"id"`
+	Kind    string  `json:"kind" db:"kind"`
+	Status  string  `json:"status" db:"status"`
+	Maker   string  `json:"maker" db:"maker"`
+	Checker *string `json:"checker,omitempty" db:"checker"`
+	// Amount is in cents, so checkers can see what is at stake at a glance.
+	Amount int64 `json:"amount" db:"amount"`
+	// Payload is the request itself, run as it is once approved.
+	Payload json.RawMessage `json:"payload" db:"payload"`
+	// Note is why the checker rejected the request, or how running it went.
+	Note      *string    `json:"note,omitempty" db:"note"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	DecidedAt *time.Time `json:"decided_at,omitempty" db:"decided_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Event is one status an approval has been in, who moved it there and why.
+// Events are only ever added, so they are the audit trail of the approval.
+type Event struct {
+	ID         string    `json:"-" db:"id"`
+	ApprovalID string    `json:"-" db:"approval_id"`
+	Status     string    `json:"status" db:"status"`
+	Actor      string    `json:"actor" db:"actor"`
+	Note       *string   `json:"note,omitempty" db:"note"`
+	ChangedAt  time.Time `json:"changed_at" db:"changed_at"`
+}
+
+// NewApproval creates a new pending approval of payload, made by maker.
+func NewApproval(kind, maker string, amount int64, payload interface{}) (*Approval, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Approval{
+		Kind:      kind,
+		Status:    StatusPending,
+		Maker:     maker,
+		Amount:    amount,
+		Payload:   data,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}, nil
+}
@@ -0,0 +1,220 @@
+package approval
+
+import (
+	"context"
+	"database/sql"
+	DB "p-system/db"
+	"p-system/tracing"
+	"p-system/utils"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+//go:generate mockgen --source=repository.go -destination=repository_mock.go -package=approval Repository
+type Repository interface {
+	// Create creates a new approval.
+	Create(ctx context.Context, approval *Approval) (*Approval, error)
+	// GetApprovalByID returns the approval with the given id.
+	GetApprovalByID(ctx context.Context, id string) (*Approval, error)
+	// GetApprovals returns the approvals with the given status, or every
+	// approval if status is empty, oldest first.
+	GetApprovals(ctx context.Context, status string) ([]Approval, error)
+	// Decide approves or rejects a pending approval on behalf of checker, with
+	// an optional note. It fails with a conflict if the approval has already
+	// been decided, and with forbidden if checker is its maker.
+	Decide(ctx context.Context, id, status, checker string, note *string) (*Approval, error)
+	// Complete records the outcome of running an approved approval, executed
+	// or failed.
+	Complete(ctx context.Context, id, status string, note *string) (*Approval, error)
+	// GetEvents returns every status the given approval has been in, oldest
+	// first.
+	GetEvents(ctx context.Context, id string) ([]Event, error)
+}
+
+// statusViolations are the errors filtering approvals reports for statuses
+// the approval_status enum does not have.
+var statusViolations = DB.Violations{
+	"invalid_text_representation": utils.InvalidRequest("invalid approval status"),
+}
+
+// decideViolations are the errors deciding an approval reports for the
+// constraints it can violate.
+var decideViolations = DB.Violations{
+	"approvals_checker_not_maker": utils.Forbidden("an approval cannot be decided by its maker"),
+	"invalid_text_representation": utils.InvalidRequest("invalid approval status"),
+}
+
+// service implements the Repository interface.
+type service struct {
+	db   DB.Queryer
+	psql sq.StatementBuilderType
+}
+
+// NewRepository creates a new approval repository running on db, which is
+// either the database or the transaction of a unit of work.
+func NewRepository(db DB.Queryer) Repository {
+	return &service{
+		db:   db,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+// Create creates a new approval. Its first event is recorded by the trigger
+// on approvals.
+func (s service) Create(ctx context.Context, approval *Approval) (*Approval, error) {
+	ctx, span := tracing.StartDB(ctx, "approval.Create")
+	defer span.End()
+
+	// The payload is sent as text, as pq would send bytes as bytea
+	query, args, err := s.psql.Insert("approvals").
+		Columns("kind", "status", "maker", "amount", "payload", "created_at", "updated_at").
+		Values(approval.Kind, approval.Status, approval.Maker, approval.Amount, string(approval.Payload), approval.CreatedAt, approval.UpdatedAt).
+		Suffix("RETURNING *").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var a Approval
+	if err := s.db.GetContext(ctx, &a, query, args...); err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// GetApprovalByID returns the approval with the given id.
+func (s service) GetApprovalByID(ctx context.Context, id string) (*Approval, error) {
+	ctx, span := tracing.StartDB(ctx, "approval.GetApprovalByID")
+	defer span.End()
+
+	query, args, err := s.psql.Select("*").
+		From("approvals").
+		Where(sq.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var a Approval
+	if err := s.db.GetContext(ctx, &a, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("approval not found")
+		}
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// GetApprovals returns the approvals with the given status, or every approval
+// if status is empty, oldest first.
+func (s service) GetApprovals(ctx context.Context, status string) ([]Approval, error) {
+	ctx, span := tracing.StartDB(ctx, "approval.GetApprovals")
+	defer span.End()
+
+	query := s.psql.Select("*").
+		From("approvals").
+		OrderBy("created_at", "id")
+	if status != "" {
+		query = query.Where(sq.Eq{"status": status})
+	}
+
+	q, args, err := query.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	approvals := []Approval{}
+	if err := s.db.SelectContext(ctx, &approvals, q, args...); err != nil {
+		return nil, statusViolations.Map(err)
+	}
+
+	return approvals, nil
+}
+
+// Decide approves or rejects a pending approval. Only a pending approval is
+// updated, so of two checkers deciding at once only one succeeds.
+func (s service) Decide(ctx context.Context, id, status, checker string, note *string) (*Approval, error) {
+	ctx, span := tracing.StartDB(ctx, "approval.Decide")
+	defer span.End()
+
+	now := time.Now()
+	query, args, err := s.psql.Update("approvals").
+		Set("status", status).
+		Set("checker", checker).
+		Set("note", note).
+		Set("decided_at", now).
+		Set("updated_at", now).
+		Where(sq.Eq{"id": id, "status": StatusPending}).
+		Suffix("RETURNING *").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.transition(ctx, id, query, args, decideViolations)
+}
+
+// Complete records the outcome of running an approved approval.
+func (s service) Complete(ctx context.Context, id, status string, note *string) (*Approval, error) {
+	ctx, span := tracing.StartDB(ctx, "approval.Complete")
+	defer span.End()
+
+	query, args, err := s.psql.Update("approvals").
+		Set("status", status).
+		Set("note", note).
+		Set("updated_at", time.Now()).
+		Where(sq.Eq{"id": id, "status": StatusApproved}).
+		Suffix("RETURNING *").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.transition(ctx, id, query, args, nil)
+}
+
+// transition runs an update of the status of an approval that only applies
+// to approvals in the status it moves them from. When it does not apply it
+// reports whether the approval is missing or in another status.
+func (s service) transition(ctx context.Context, id, query string, args []interface{}, violations DB.Violations) (*Approval, error) {
+	var a Approval
+	err := s.db.GetContext(ctx, &a, query, args...)
+	if err == sql.ErrNoRows {
+		current, err := s.GetApprovalByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return nil, utils.Conflict("approval is " + current.Status)
+	}
+	if err != nil {
+		return nil, violations.Map(err)
+	}
+
+	return &a, nil
+}
+
+// GetEvents returns every status the given approval has been in, oldest
+// first.
+func (s service) GetEvents(ctx context.Context, id string) ([]Event, error) {
+	ctx, span := tracing.StartDB(ctx, "approval.GetEvents")
+	defer span.End()
+
+	query, args, err := s.psql.Select("*").
+		From("approval_events").
+		Where(sq.Eq{"approval_id": id}).
+		OrderBy("changed_at", "id").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	events := []Event{}
+	if err := s.db.SelectContext(ctx, &events, query, args...); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,100 @@
+package approval
+
+import (
+	"context"
+	"p-system/tests"
+	"p-system/utils"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApprovalRepository(t *testing.T) {
+	db := tests.StartDB(t)
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	var created *Approval
+
+	t.Run("TestCreateApproval_Success", func(t *testing.T) {
+		a, err := NewApproval(KindTransaction, "alice", 500000, map[string]interface{}{"amount": 5000, "type": "debit"})
+		require.NoError(t, err)
+
+		created, err = repo.Create(ctx, a)
+
+		require.NoError(t, err)
+		require.NotEmpty(t, created.ID)
+		require.Equal(t, StatusPending, created.Status)
+		require.JSONEq(t, `{"amount": 5000, "type": "debit"}`, string(created.Payload))
+	})
+
+	t.Run("TestGetApprovals_ByStatus", func(t *testing.T) {
+		pending, err := repo.GetApprovals(ctx, StatusPending)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+
+		rejected, err := repo.GetApprovals(ctx, StatusRejected)
+		require.NoError(t, err)
+		require.Empty(t, rejected)
+
+		_, err = repo.GetApprovals(ctx, "maybe")
+		require.ErrorIs(t, err, utils.ErrInvalidRequest)
+	})
+
+	t.Run("TestDecide_ByMaker", func(t *testing.T) {
+		_, err := repo.Decide(ctx, created.ID, StatusApproved, "alice", nil)
+
+		require.ErrorIs(t, err, utils.ErrForbidden)
+	})
+
+	t.Run("TestDecide_Success", func(t *testing.T) {
+		a, err := repo.Decide(ctx, created.ID, StatusApproved, "bob", nil)
+
+		require.NoError(t, err)
+		require.Equal(t, StatusApproved, a.Status)
+		require.Equal(t, "bob", *a.Checker)
+		require.NotNil(t, a.DecidedAt)
+	})
+
+	t.Run("TestDecide_AlreadyDecided", func(t *testing.T) {
+		_, err := repo.Decide(ctx, created.ID, StatusRejected, "carol", nil)
+
+		require.ErrorIs(t, err, utils.ErrConflict)
+		require.EqualError(t, err, "approval is approved")
+	})
+
+	t.Run("TestComplete_Success", func(t *testing.T) {
+		note := "Transaction successful"
+		a, err := repo.Complete(ctx, created.ID, StatusExecuted, &note)
+
+		require.NoError(t, err)
+		require.Equal(t, StatusExecuted, a.Status)
+
+		_, err = repo.Complete(ctx, created.ID, StatusFailed, nil)
+		require.ErrorIs(t, err, utils.ErrConflict)
+	})
+
+	t.Run("TestGetEvents_RecordsTrail", func(t *testing.T) {
+		events, err := repo.GetEvents(ctx, created.ID)
+
+		require.NoError(t, err)
+		require.Len(t, events, 3)
+		require.Equal(t, StatusPending, events[0].Status)
+		require.Equal(t, "alice", events[0].Actor)
+		require.Equal(t, StatusApproved, events[1].Status)
+		require.Equal(t, "bob", events[1].Actor)
+		require.Equal(t, StatusExecuted, events[2].Status)
+		require.Equal(t, "Transaction successful", *events[2].Note)
+
+		// The trail cannot be rewritten
+		_, err = db.Exec("DELETE FROM approval_events WHERE approval_id = $1", created.ID)
+		require.Error(t, err)
+	})
+
+	t.Run("TestGetApprovalByID_NotFound", func(t *testing.T) {
+		_, err := repo.GetApprovalByID(ctx, "00000000-0000-0000-0000-000000000000")
+
+		require.EqualError(t, err, "approval not found")
+	})
+}
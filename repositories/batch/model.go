@@ -0,0 +1,78 @@
+package batch
+
+import "time"
+
+const (
+	StatusProcessing = "processing"
+	StatusCompleted  = "completed"
+)
+
+const (
+	ItemStatusPending   = "pending"
+	ItemStatusSucceeded = "succeeded"
+	ItemStatusFailed    = "failed"
+	// ItemStatusHeld is an item above the approval threshold, made once
+	// its approval is approved.
+	ItemStatusHeld = "held"
+)
+
+// Batch is a group of transactions submitted together and processed in the
+// background. The counts summarise the status of its items.
+type Batch struct {
+	ID          string     `json:"id" db:"id"`
+	Status      string     `json:"status" db:"status"`
+	Source      string     `json:"source" db:"source"`
+	Total       int        `json:"total" db:"total"`
+	Pending     int        `json:"pending" db:"pending"`
+	Succeeded   int        `json:"succeeded" db:"succeeded"`
+	Failed      int        `json:"failed" db:"failed"`
+	Held        int        `json:"held" db:"held"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	// Operator is the operator who submitted the batch, if one did. Items
+	// held for approval are made in their name.
+	Operator *string `json:"operator,omitempty" db:"operator"`
+}
+
+// Item is a single transaction within a batch.
+type Item struct {
+	ID        string    `json:"id" db:"id"`
+	BatchID   string    `json:"batch_id" db:"batch_id"`
+	RowNumber int       `json:"row_number" db:"row_number"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Amount    int64     `json:"amount" db:"amount"`
+	Type      string    `json:"type" db:"type"`
+	Reference string    `json:"reference" db:"reference"`
+	Status    string    `json:"status" db:"status"`
+	Error     *string   `json:"error,omitempty" db:"error"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	// ClaimedUntil is when the claim of the worker processing the item
+	// lapses.
+	ClaimedUntil *time.Time `json:"-" db:"claimed_until"`
+	// Operator is the operator of the batch, set on items being processed.
+	Operator *string `json:"-" db:"operator"`
+}
+
+// NewBatch creates a new batch in the processing state.
+func NewBatch(source string) *Batch {
+	return &Batch{
+		Status:    StatusProcessing,
+		Source:    source,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// NewItem creates a new pending batch item.
+func NewItem(rowNumber int, userID, reference, transactionType string, amount int64) Item {
+	return Item{
+		RowNumber: rowNumber,
+		UserID:    userID,
+		Amount:    amount,
+		Type:      transactionType,
+		Reference: reference,
+		Status:    ItemStatusPending,
+		UpdatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,227 @@
+package batch
+
+import (
+	"database/sql"
+	"p-system/metrics"
+	"p-system/utils"
+	"sort"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// insertChunkSize keeps multi-row inserts well below the Postgres limit of
+// 65535 bind parameters.
+const insertChunkSize = 1000
+
+// claimLease is how long a worker has claimed the items it processes for. It
+// is well beyond the time a round of items takes, so items only go to another
+// worker once the one that claimed them has stopped.
+const claimLease = 30 * time.Minute
+
+//go:generate mockgen --source=repository.go -destination=repository_mock.go -package=batch Repository
+type Repository interface {
+	// Create creates a new batch together with all of its items.
+	Create(*Batch, []Item) (*Batch, error)
+	// GetBatchByID returns the batch with the given id and its item counts.
+	GetBatchByID(id string) (*Batch, error)
+	// GetItemsByBatchID returns the items of the given batch in row order.
+	GetItemsByBatchID(id string) ([]Item, error)
+	// ProcessPending claims up to limit pending items, skipping any claimed
+	// by another worker, and calls fn for each. The status and error left by
+	// fn are saved as soon as fn returns, and batches with no pending items
+	// left are completed.
+	ProcessPending(limit int, fn func(*Item)) (int, error)
+}
+
+// service implements the Repository interface.
+type service struct {
+	db   *sqlx.DB
+	psql sq.StatementBuilderType
+}
+
+// NewRepository creates a new batch repository.
+func NewRepository(db *sqlx.DB) Repository {
+	return &service{
+		db:   db,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+// Create creates a new batch together with all of its items.
+func (s service) Create(batch *Batch, items []Item) (*Batch, error) {
+	query, args, err := s.psql.Insert("batches").
+		Columns("status", "source", "operator", "created_at", "updated_at").
+		Values(batch.Status, batch.Source, batch.Operator, batch.CreatedAt, batch.UpdatedAt).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	//use transaction so a batch is never stored with only some of its items
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	var id string
+	if err := tx.Get(&id, query, args...); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	for start := 0; start < len(items); start += insertChunkSize {
+		end := start + insertChunkSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		insert := s.psql.Insert("batch_items").
+			Columns("batch_id", "row_number", "user_id", "amount", "type", "reference", "status", "updated_at")
+		for _, item := range items[start:end] {
+			insert = insert.Values(id, item.RowNumber, item.UserID, item.Amount, item.Type, item.Reference, item.Status, item.UpdatedAt)
+		}
+
+		query, args, err := insert.ToSql()
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			tx.Rollback()
+			if err, ok := err.(*pq.Error); ok {
+				if err.Code.Name() == "foreign_key_violation" {
+					return nil, utils.NotFound("user not found")
+				}
+			}
+			return nil, err
+		}
+	}
+
+	//commit the transaction
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetBatchByID(id)
+}
+
+// GetBatchByID returns the batch with the given id and its item counts.
+func (s service) GetBatchByID(id string) (*Batch, error) {
+	query, args, err := s.psql.Select(
+		"b.*",
+		"COUNT(i.id) AS total",
+		"COUNT(i.id) FILTER (WHERE i.status = 'pending') AS pending",
+		"COUNT(i.id) FILTER (WHERE i.status = 'succeeded') AS succeeded",
+		"COUNT(i.id) FILTER (WHERE i.status = 'failed') AS failed",
+		"COUNT(i.id) FILTER (WHERE i.status = 'held') AS held",
+	).
+		From("batches b").
+		LeftJoin("batch_items i ON i.batch_id = b.id").
+		Where(sq.Eq{"b.id": id}).
+		GroupBy("b.id").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var b Batch
+	if err := s.db.Get(&b, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("batch not found")
+		}
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+// GetItemsByBatchID returns the items of the given batch in row order.
+func (s service) GetItemsByBatchID(id string) ([]Item, error) {
+	query, args, err := s.psql.Select("*").
+		From("batch_items").
+		Where(sq.Eq{"batch_id": id}).
+		OrderBy("row_number").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	items := []Item{}
+	if err := s.db.Select(&items, query, args...); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// ProcessPending claims up to limit pending items and calls fn for each, with
+// the operator of their batch. The items are claimed in a statement of their own rather than locked while fn
+// runs, and each result is saved in a transaction of its own, so a result
+// that is known is never lost to a later failure. Items whose claim lapses
+// before their result is saved are processed again.
+func (s service) ProcessPending(limit int, fn func(*Item)) (int, error) {
+	now := time.Now()
+
+	var claimed []Item
+	err := s.db.Select(&claimed, `UPDATE batch_items SET claimed_until = $1 WHERE id IN (
+			SELECT id FROM batch_items WHERE status = $2 AND (claimed_until IS NULL OR claimed_until < $3)
+			ORDER BY batch_id, row_number LIMIT $4 FOR UPDATE SKIP LOCKED)
+		RETURNING *, (SELECT operator FROM batches WHERE id = batch_items.batch_id) AS operator`, now.Add(claimLease), ItemStatusPending, now, limit)
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(claimed, func(i, j int) bool {
+		if claimed[i].BatchID != claimed[j].BatchID {
+			return claimed[i].BatchID < claimed[j].BatchID
+		}
+		return claimed[i].RowNumber < claimed[j].RowNumber
+	})
+
+	for i := range claimed {
+		item := &claimed[i]
+		fn(item)
+
+		if err := s.saveItem(item); err != nil {
+			return i, err
+		}
+	}
+
+	return len(claimed), nil
+}
+
+// saveItem saves the status and error of a processed item, and completes its
+// batch if it has nothing left to process.
+func (s service) saveItem(item *Item) error {
+	start := time.Now()
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		metrics.DBTransactionDuration.WithLabelValues("save_batch_item").Observe(time.Since(start).Seconds())
+	}()
+
+	_, err = tx.Exec("UPDATE batch_items SET status = $1, error = $2, claimed_until = NULL, updated_at = $3 WHERE id = $4 AND status = $5",
+		item.Status, item.Error, time.Now(), item.ID, ItemStatusPending)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	//complete the batch if it has nothing left to process
+	_, err = tx.Exec(`UPDATE batches SET status = $1, completed_at = $2, updated_at = $2 WHERE id = $3
+		AND NOT EXISTS (SELECT 1 FROM batch_items WHERE batch_id = $3 AND status = $4)`,
+		StatusCompleted, time.Now(), item.BatchID, ItemStatusPending)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	//commit the transaction
+	return tx.Commit()
+}
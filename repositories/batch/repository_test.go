@@ -0,0 +1,94 @@
+package batch
+
+import (
+	"p-system/tests"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchRepository(t *testing.T) {
+	db := tests.StartDB(t)
+
+	repo := NewRepository(db)
+
+	//run seeds
+	err := tests.Seed(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID := "d164e69d-26f5-448d-a18c-baeae517d9f2"
+	var created *Batch
+
+	t.Run("TestCreateBatch_Success", func(t *testing.T) {
+		items := []Item{
+			NewItem(1, userID, "payroll-1", "credit", 1000),
+			NewItem(2, userID, "payroll-2", "credit", 2000),
+		}
+
+		b := NewBatch("json")
+		operator := "alice"
+		b.Operator = &operator
+
+		created, err = repo.Create(b, items)
+
+		require.NoError(t, err)
+		require.Equal(t, StatusProcessing, created.Status)
+		require.Equal(t, "alice", *created.Operator)
+		require.Equal(t, 2, created.Total)
+		require.Equal(t, 2, created.Pending)
+	})
+
+	t.Run("TestCreateBatch_UnknownUser", func(t *testing.T) {
+		items := []Item{NewItem(1, "d164e69d-26f5-448d-a18c-baeae517d000", "payroll-3", "credit", 1000)}
+
+		_, err := repo.Create(NewBatch("csv"), items)
+
+		require.EqualError(t, err, "user not found")
+	})
+
+	t.Run("TestGetBatchByID_NotFound", func(t *testing.T) {
+		_, err := repo.GetBatchByID("d164e69d-26f5-448d-a18c-baeae517d000")
+
+		require.EqualError(t, err, "batch not found")
+	})
+
+	t.Run("TestProcessPending_CompletesBatch", func(t *testing.T) {
+		n, err := repo.ProcessPending(10, func(item *Item) {
+			require.Equal(t, "alice", *item.Operator)
+			if item.RowNumber == 1 {
+				item.Status = ItemStatusSucceeded
+				return
+			}
+
+			// The result of the first item is saved already, and the
+			// claimed items are skipped by other workers
+			items, err := repo.GetItemsByBatchID(created.ID)
+			require.NoError(t, err)
+			require.Equal(t, ItemStatusSucceeded, items[0].Status)
+			n, err := repo.ProcessPending(10, func(*Item) { panic("claimed item processed twice") })
+			require.NoError(t, err)
+			require.Zero(t, n)
+
+			msg := "Insufficient balance"
+			item.Status = ItemStatusFailed
+			item.Error = &msg
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+
+		b, err := repo.GetBatchByID(created.ID)
+		require.NoError(t, err)
+		require.Equal(t, StatusCompleted, b.Status)
+		require.Equal(t, 1, b.Succeeded)
+		require.Equal(t, 1, b.Failed)
+		require.NotNil(t, b.CompletedAt)
+
+		items, err := repo.GetItemsByBatchID(created.ID)
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		require.Equal(t, "Insufficient balance", *items[1].Error)
+	})
+}
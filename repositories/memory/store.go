@@ -0,0 +1,148 @@
+// Package memory implements the user, wallet and transaction repositories in
+// memory, for fast tests and demos that run without Postgres. They follow the
+// semantics of the Postgres repositories, which the conformance suite in
+// repositories/repotest checks both against.
+package memory
+
+import (
+	"p-system/repositories/transaction"
+	"p-system/repositories/user"
+	"p-system/repositories/wallet"
+	"p-system/utils"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Store holds the rows shared by the in-memory repositories. One mutex guards
+// them all, standing in for the row locks Postgres takes, so every repository
+// call is atomic.
+type Store struct {
+	mu           sync.Mutex
+	users        map[string]user.User
+	wallets      map[string]wallet.Wallet
+	transactions map[string]transaction.Transaction
+	history      []transaction.StatusChange
+	snapshots    []wallet.Snapshot
+}
+
+// NewStore creates an empty store.
+func NewStore() *Store {
+	return &Store{
+		users:        map[string]user.User{},
+		wallets:      map[string]wallet.Wallet{},
+		transactions: map[string]transaction.Transaction{},
+	}
+}
+
+// Users returns the user repository of the store.
+func (s *Store) Users() user.Repository {
+	return &users{store: s}
+}
+
+// Wallets returns the wallet repository of the store.
+func (s *Store) Wallets() wallet.Repository {
+	return &wallets{store: s}
+}
+
+// Transactions returns the transaction repository of the store.
+func (s *Store) Transactions() transaction.Repository {
+	return &transactions{store: s}
+}
+
+// PutUser stores u as is, like a seeded row. Users have no Create method of
+// their own.
+func (s *Store) PutUser(u user.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now()
+	}
+	s.users[u.ID] = u
+}
+
+// PutWallet stores w as is, like a seeded row.
+func (s *Store) PutWallet(w wallet.Wallet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if w.Status == "" {
+		w.Status = wallet.StatusActive
+	}
+	if w.Version == 0 {
+		w.Version = 1
+	}
+	if w.CreatedAt.IsZero() {
+		w.CreatedAt = time.Now()
+		w.UpdatedAt = w.CreatedAt
+	}
+	s.wallets[w.ID] = w
+}
+
+// PutTransaction stores t as is, like a seeded row, starting its status
+// history.
+func (s *Store) PutTransaction(t transaction.Transaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+		t.UpdatedAt = t.CreatedAt
+	}
+	if t.CompletedAt == nil {
+		t.CompletedAt = completedAt(t.Status)
+	}
+	s.transactions[t.ID] = t
+	s.recordStatus(t)
+}
+
+// recordStatus appends the status t is in to its history, as the trigger on
+// the transactions table does.
+func (s *Store) recordStatus(t transaction.Transaction) {
+	s.history = append(s.history, transaction.StatusChange{
+		ID:            newID(),
+		TransactionID: t.ID,
+		Status:        t.Status,
+		ChangedAt:     time.Now(),
+	})
+}
+
+// requireUser fails like the foreign keys on user_id when the user does not
+// exist.
+func (s *Store) requireUser(id string) error {
+	if _, ok := s.users[id]; !ok {
+		return utils.NotFound("user not found")
+	}
+	return nil
+}
+
+// netAmount sums completed credits minus completed debits of a user created
+// in (after, until], or after after when until is nil.
+func (s *Store) netAmount(userID string, after time.Time, until *time.Time) int64 {
+	var net int64
+	for _, t := range s.transactions {
+		if t.UserID != userID || t.Status != "completed" || !t.CompletedAt.After(after) {
+			continue
+		}
+		if until != nil && t.CompletedAt.After(*until) {
+			continue
+		}
+		net += signed(t)
+	}
+	return net
+}
+
+// signed returns the amount of t, negative for debits.
+func signed(t transaction.Transaction) int64 {
+	if t.Type == "debit" {
+		return -t.Amount
+	}
+	return t.Amount
+}
+
+// newID returns a random UUID, as the columns default to.
+func newID() string {
+	return uuid.NewString()
+}
@@ -0,0 +1,240 @@
+package memory
+
+import (
+	"context"
+	"p-system/repositories/transaction"
+	"p-system/utils"
+	"sort"
+	"time"
+)
+
+// transactions implements transaction.Repository on a store.
+type transactions struct {
+	store *Store
+}
+
+// Create creates a new transaction.
+func (r *transactions) Create(_ context.Context, t *transaction.Transaction) (*transaction.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	switch {
+	case t.Type != "credit" && t.Type != "debit",
+		t.Status != "pending" && t.Status != "completed" && t.Status != "failed":
+		return nil, utils.InvalidRequest("invalid transaction type or status")
+	case t.Amount <= 0:
+		return nil, utils.InvalidRequest("amount must be positive")
+	}
+	for _, existing := range r.store.transactions {
+		if existing.Reference == t.Reference {
+			return nil, utils.DuplicateReference("transaction already exists")
+		}
+	}
+	if err := r.store.requireUser(t.UserID); err != nil {
+		return nil, err
+	}
+
+	created := *t
+	created.ID = newID()
+	created.CompletedAt = completedAt(created.Status)
+	r.store.transactions[created.ID] = created
+	r.store.recordStatus(created)
+
+	return &created, nil
+}
+
+// GetTransactionByReference returns the transaction with the given reference.
+func (r *transactions) GetTransactionByReference(_ context.Context, reference string) (*transaction.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, t := range r.store.transactions {
+		if t.Reference == reference {
+			return &t, nil
+		}
+	}
+	return nil, utils.NotFound("transaction not found")
+}
+
+// GetTransactionByID returns the transaction with the given id.
+func (r *transactions) GetTransactionByID(_ context.Context, id string) (*transaction.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	t, ok := r.store.transactions[id]
+	if !ok {
+		return nil, utils.NotFound("transaction not found")
+	}
+	return &t, nil
+}
+
+// GetStatusHistory returns every status the given transaction has been in,
+// oldest first.
+func (r *transactions) GetStatusHistory(_ context.Context, id string) ([]transaction.StatusChange, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// history is appended to in order, so it is already oldest first
+	history := []transaction.StatusChange{}
+	for _, change := range r.store.history {
+		if change.TransactionID == id {
+			history = append(history, change)
+		}
+	}
+	return history, nil
+}
+
+// CountByStatus returns how many transactions have the given status.
+func (r *transactions) CountByStatus(_ context.Context, status string) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var count int64
+	for _, t := range r.store.transactions {
+		if t.Status == status {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetPendingBefore returns the transactions still pending that were created
+// before the given time, oldest first.
+func (r *transactions) GetPendingBefore(_ context.Context, before time.Time) ([]transaction.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return r.filter(func(t transaction.Transaction) bool {
+		return t.Status == "pending" && t.CreatedAt.Before(before)
+	}), nil
+}
+
+// UpdateTransactionToFailed marks a pending transaction failed.
+func (r *transactions) UpdateTransactionToFailed(_ context.Context, id string) (*transaction.Transaction, error) {
+	return r.settle(id, "failed")
+}
+
+// UpdateTransactionToCompleted marks a pending transaction completed.
+func (r *transactions) UpdateTransactionToCompleted(_ context.Context, id string) (*transaction.Transaction, error) {
+	return r.settle(id, "completed")
+}
+
+// settle moves a transaction out of pending into status, failing with a
+// conflict like the Postgres repository if it is no longer pending.
+func (r *transactions) settle(id, status string) (*transaction.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	t, ok := r.store.transactions[id]
+	if !ok {
+		return nil, utils.NotFound("transaction not found")
+	}
+	if t.Status != "pending" {
+		return nil, utils.Conflict("transaction is " + t.Status)
+	}
+	return r.setStatus(t, status, time.Now()), nil
+}
+
+// setStatus moves t into status, recording the change if it is one.
+func (r *transactions) setStatus(t transaction.Transaction, status string, updatedAt time.Time) *transaction.Transaction {
+	changed := t.Status != status
+	t.Status = status
+	t.UpdatedAt = updatedAt
+	if changed {
+		t.CompletedAt = completedAt(status)
+	}
+	r.store.transactions[t.ID] = t
+	if changed {
+		r.store.recordStatus(t)
+	}
+	return &t
+}
+
+// GetCompletedTransactions returns the completed transactions of a user
+// completed in [from, to), oldest first.
+func (r *transactions) GetCompletedTransactions(_ context.Context, userID string, from, to time.Time) ([]transaction.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	completed := r.filter(func(t transaction.Transaction) bool {
+		return t.UserID == userID && t.Status == "completed" && !t.CompletedAt.Before(from) && t.CompletedAt.Before(to)
+	})
+	sort.Slice(completed, func(i, j int) bool {
+		if !completed[i].CompletedAt.Equal(*completed[j].CompletedAt) {
+			return completed[i].CompletedAt.Before(*completed[j].CompletedAt)
+		}
+		return completed[i].ID < completed[j].ID
+	})
+	return completed, nil
+}
+
+// GetNetAmountSince returns completed credits minus completed debits of a
+// user completed at or after since.
+func (r *transactions) GetNetAmountSince(_ context.Context, userID string, since time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var net int64
+	for _, t := range r.filter(func(t transaction.Transaction) bool {
+		return t.UserID == userID && t.Status == "completed" && !t.CompletedAt.Before(since)
+	}) {
+		net += signed(t)
+	}
+	return net, nil
+}
+
+// GetTransactionsByReferences returns the transactions with any of the given
+// references.
+func (r *transactions) GetTransactionsByReferences(_ context.Context, references []string) ([]transaction.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	wanted := map[string]bool{}
+	for _, reference := range references {
+		wanted[reference] = true
+	}
+
+	return r.filter(func(t transaction.Transaction) bool {
+		return wanted[t.Reference]
+	}), nil
+}
+
+// GetCompletedTransactionsBetween returns the completed transactions of every
+// user created in [from, to).
+func (r *transactions) GetCompletedTransactionsBetween(_ context.Context, from, to time.Time) ([]transaction.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return r.filter(func(t transaction.Transaction) bool {
+		return t.Status == "completed" && !t.CreatedAt.Before(from) && t.CreatedAt.Before(to)
+	}), nil
+}
+
+// filter returns the transactions keep holds for, ordered by creation time
+// and then id.
+func (r *transactions) filter(keep func(transaction.Transaction) bool) []transaction.Transaction {
+	matched := []transaction.Transaction{}
+	for _, t := range r.store.transactions {
+		if keep(t) {
+			matched = append(matched, t)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.Before(matched[j].CreatedAt)
+		}
+		return matched[i].ID < matched[j].ID
+	})
+	return matched
+}
+
+// completedAt stamps a transaction moving into status like the trigger on the
+// transactions table: now if it is completed, otherwise nothing.
+func completedAt(status string) *time.Time {
+	if status != "completed" {
+		return nil
+	}
+	now := time.Now()
+	return &now
+}
@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"context"
+	"p-system/repositories/user"
+	"p-system/utils"
+)
+
+// users implements user.Repository on a store.
+type users struct {
+	store *Store
+}
+
+// GetUserByID returns the user with the given ID.
+func (r *users) GetUserByID(_ context.Context, id string) (*user.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	u, ok := r.store.users[id]
+	if !ok {
+		return nil, utils.NotFound("user not found")
+	}
+	return &u, nil
+}
+
+// GetUsersByIDs returns the users with any of the given IDs.
+func (r *users) GetUsersByIDs(_ context.Context, ids []string) ([]user.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	found := []user.User{}
+	for _, id := range ids {
+		if u, ok := r.store.users[id]; ok {
+			found = append(found, u)
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,250 @@
+package memory
+
+import (
+	"context"
+	"p-system/repositories/transaction"
+	"p-system/repositories/wallet"
+	"p-system/utils"
+	"sort"
+	"time"
+)
+
+// wallets implements wallet.Repository on a store.
+type wallets struct {
+	store *Store
+}
+
+// Create creates a new wallet. Like the Postgres repository it ignores the
+// id, status and transaction of w, which take their defaults.
+func (r *wallets) Create(_ context.Context, w *wallet.Wallet) (*wallet.Wallet, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if w.Balance < 0 {
+		return nil, utils.InvalidRequest("balance must not be negative")
+	}
+	for _, existing := range r.store.wallets {
+		if existing.UserID == w.UserID {
+			return nil, utils.Conflict("user already has a wallet")
+		}
+	}
+	if err := r.store.requireUser(w.UserID); err != nil {
+		return nil, err
+	}
+
+	created := wallet.Wallet{
+		ID:        newID(),
+		UserID:    w.UserID,
+		Balance:   w.Balance,
+		Status:    wallet.StatusActive,
+		Version:   1,
+		CreatedAt: w.CreatedAt,
+		UpdatedAt: w.UpdatedAt,
+	}
+	r.store.wallets[created.ID] = created
+
+	return &created, nil
+}
+
+// GetWalletByUserID returns the wallet with the given user id, the oldest one
+// if the user has several, which only wallets put in the store directly can.
+func (r *wallets) GetWalletByUserID(_ context.Context, userID string) (*wallet.Wallet, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var found *wallet.Wallet
+	for _, w := range r.store.wallets {
+		if w.UserID == userID && (found == nil || w.CreatedAt.Before(found.CreatedAt)) {
+			w := w
+			found = &w
+		}
+	}
+	if found == nil {
+		return nil, utils.NotFound("wallet not found")
+	}
+	return found, nil
+}
+
+// GetWalletByID returns the wallet with the given id.
+func (r *wallets) GetWalletByID(_ context.Context, id string) (*wallet.Wallet, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	w, ok := r.store.wallets[id]
+	if !ok {
+		return nil, utils.NotFound("wallet not found")
+	}
+	return &w, nil
+}
+
+// CreditWallet updates the balance of a wallet.
+func (r *wallets) CreditWallet(_ context.Context, w *wallet.Wallet, t transaction.Transaction, amount int64) (*wallet.Wallet, error) {
+	return r.updateBalance(w, t, amount)
+}
+
+// DebitWallet updates the balance of a wallet.
+func (r *wallets) DebitWallet(_ context.Context, w *wallet.Wallet, t transaction.Transaction, amount int64) (*wallet.Wallet, error) {
+	return r.updateBalance(w, t, -amount)
+}
+
+// updateBalance adds delta to the balance of a wallet and records the
+// transaction that changed it, holding the store lock throughout as the
+// Postgres repository holds the row lock.
+func (r *wallets) updateBalance(w *wallet.Wallet, t transaction.Transaction, delta int64) (*wallet.Wallet, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	updated, ok := r.store.wallets[w.ID]
+	if !ok {
+		return nil, utils.NotFound("wallet not found")
+	}
+
+	if w.Version != 0 && w.Version != updated.Version {
+		return nil, utils.PreconditionFailed("wallet has been modified")
+	}
+	if updated.Balance+delta < 0 {
+		return nil, utils.ErrInsufficientFunds
+	}
+
+	w.TransactionID = &t.ID
+
+	updated.Balance += delta
+	updated.Version++
+	updated.UpdatedAt = time.Now()
+	updated.TransactionID = w.TransactionID
+	r.store.wallets[w.ID] = updated
+
+	return &updated, nil
+}
+
+// GetBalanceAt returns the balance of a wallet as of the given time, worked
+// out the same way as the Postgres repository.
+func (r *wallets) GetBalanceAt(_ context.Context, walletID string, at time.Time) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	w, ok := r.store.wallets[walletID]
+	if !ok {
+		return 0, utils.NotFound("wallet not found")
+	}
+
+	if at.Before(w.CreatedAt) {
+		return 0, nil
+	}
+
+	// Roll forward from the latest snapshot at or before at, otherwise roll
+	// back from the earliest snapshot after at
+	var before, after *wallet.Snapshot
+	for _, snapshot := range r.store.snapshots {
+		snapshot := snapshot
+		if snapshot.WalletID != walletID {
+			continue
+		}
+		if !snapshot.TakenAt.After(at) {
+			if before == nil || snapshot.TakenAt.After(before.TakenAt) {
+				before = &snapshot
+			}
+		} else if after == nil || snapshot.TakenAt.Before(after.TakenAt) {
+			after = &snapshot
+		}
+	}
+
+	switch {
+	case before != nil:
+		return before.Balance + r.store.netAmount(w.UserID, before.TakenAt, &at), nil
+	case after != nil:
+		return after.Balance - r.store.netAmount(w.UserID, at, &after.TakenAt), nil
+	}
+
+	// Otherwise roll back from the current balance
+	return w.Balance - r.store.netAmount(w.UserID, at, nil), nil
+}
+
+// SnapshotBalances records the balance as of at of every wallet that has no
+// snapshot taken within every before at.
+func (r *wallets) SnapshotBalances(_ context.Context, at time.Time, every time.Duration) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	recent := map[string]bool{}
+	taken := map[string]bool{}
+	for _, snapshot := range r.store.snapshots {
+		if snapshot.TakenAt.After(at.Add(-every)) {
+			recent[snapshot.WalletID] = true
+		}
+		if snapshot.TakenAt.Equal(at) {
+			taken[snapshot.WalletID] = true
+		}
+	}
+
+	var count int64
+	for _, w := range r.store.wallets {
+		if w.CreatedAt.After(at) || recent[w.ID] || taken[w.ID] {
+			continue
+		}
+
+		r.store.snapshots = append(r.store.snapshots, wallet.Snapshot{
+			ID:        newID(),
+			WalletID:  w.ID,
+			Balance:   w.Balance - r.store.netAmount(w.UserID, at, nil),
+			TakenAt:   at,
+			CreatedAt: time.Now(),
+		})
+		count++
+	}
+
+	return count, nil
+}
+
+// CheckBalances returns every wallet whose balance differs from completed
+// credits minus completed debits, ordered by wallet id.
+func (r *wallets) CheckBalances(_ context.Context) ([]wallet.Discrepancy, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	expected := map[string]int64{}
+	for _, t := range r.store.transactions {
+		if t.Status == "completed" {
+			expected[t.UserID] += signed(t)
+		}
+	}
+
+	discrepancies := []wallet.Discrepancy{}
+	for _, w := range r.store.wallets {
+		if w.Balance != expected[w.UserID] {
+			discrepancies = append(discrepancies, wallet.Discrepancy{
+				WalletID: w.ID,
+				UserID:   w.UserID,
+				Balance:  w.Balance,
+				Expected: expected[w.UserID],
+			})
+		}
+	}
+
+	sort.Slice(discrepancies, func(i, j int) bool {
+		return discrepancies[i].WalletID < discrepancies[j].WalletID
+	})
+	return discrepancies, nil
+}
+
+// UpdateWalletStatus sets the status of a wallet.
+func (r *wallets) UpdateWalletStatus(_ context.Context, id, status string) (*wallet.Wallet, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if status != wallet.StatusActive && status != wallet.StatusFrozen {
+		return nil, utils.InvalidRequest("invalid wallet status")
+	}
+
+	w, ok := r.store.wallets[id]
+	if !ok {
+		return nil, utils.NotFound("wallet not found")
+	}
+
+	w.Status = status
+	w.UpdatedAt = time.Now()
+	w.Version++
+	r.store.wallets[id] = w
+
+	return &w, nil
+}
@@ -0,0 +1,70 @@
+package reconciliation
+
+import "time"
+
+const (
+	// CategoryMatched is a settlement line matching a completed transaction.
+	CategoryMatched = "matched"
+	// CategoryMissingOnOurSide is a settlement line with no completed
+	// transaction for its reference.
+	CategoryMissingOnOurSide = "missing_on_our_side"
+	// CategoryMissingOnProviderSide is a completed transaction in the period
+	// that the settlement file does not contain.
+	CategoryMissingOnProviderSide = "missing_on_provider_side"
+	// CategoryAmountMismatch is a settlement line whose amount differs from
+	// the transaction with the same reference.
+	CategoryAmountMismatch = "amount_mismatch"
+)
+
+// Reconciliation is the result of comparing a provider settlement file with
+// the transactions completed over the period it covers.
+type Reconciliation struct {
+	ID                    string    `json:"id" db:"id"`
+	FileName              string    `json:"file_name" db:"file_name"`
+	PeriodStart           time.Time `json:"period_start" db:"period_start"`
+	PeriodEnd             time.Time `json:"period_end" db:"period_end"`
+	Matched               int       `json:"matched" db:"matched"`
+	MissingOnOurSide      int       `json:"missing_on_our_side" db:"missing_on_our_side"`
+	MissingOnProviderSide int       `json:"missing_on_provider_side" db:"missing_on_provider_side"`
+	AmountMismatch        int       `json:"amount_mismatch" db:"amount_mismatch"`
+	CreatedAt             time.Time `json:"created_at" db:"created_at"`
+}
+
+// Item is a single settlement line or transaction and how it reconciled.
+// Amounts are in minor units.
+type Item struct {
+	ID               string  `json:"id" db:"id"`
+	ReconciliationID string  `json:"reconciliation_id" db:"reconciliation_id"`
+	Category         string  `json:"category" db:"category"`
+	Reference        string  `json:"reference" db:"reference"`
+	TransactionID    *string `json:"transaction_id,omitempty" db:"transaction_id"`
+	OurAmount        *int64  `json:"our_amount,omitempty" db:"our_amount"`
+	ProviderAmount   *int64  `json:"provider_amount,omitempty" db:"provider_amount"`
+	Note             *string `json:"note,omitempty" db:"note"`
+}
+
+// NewReconciliation creates a reconciliation of the given file over
+// [periodStart, periodEnd) with counts taken from items.
+func NewReconciliation(fileName string, periodStart, periodEnd time.Time, items []Item) *Reconciliation {
+	r := &Reconciliation{
+		FileName:    fileName,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		CreatedAt:   time.Now(),
+	}
+
+	for _, item := range items {
+		switch item.Category {
+		case CategoryMatched:
+			r.Matched++
+		case CategoryMissingOnOurSide:
+			r.MissingOnOurSide++
+		case CategoryMissingOnProviderSide:
+			r.MissingOnProviderSide++
+		case CategoryAmountMismatch:
+			r.AmountMismatch++
+		}
+	}
+
+	return r
+}
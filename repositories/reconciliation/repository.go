@@ -0,0 +1,165 @@
+package reconciliation
+
+import (
+	"database/sql"
+	"p-system/utils"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// insertChunkSize keeps multi-row inserts well below the Postgres limit of
+// 65535 bind parameters.
+const insertChunkSize = 1000
+
+//go:generate mockgen --source=repository.go -destination=repository_mock.go -package=reconciliation Repository
+type Repository interface {
+	// Create saves a reconciliation together with all of its items.
+	Create(*Reconciliation, []Item) (*Reconciliation, error)
+	// GetReconciliationByID returns the reconciliation with the given id.
+	GetReconciliationByID(id string) (*Reconciliation, error)
+	// GetReconciliations returns the most recent reconciliations, newest first.
+	GetReconciliations(limit int) ([]Reconciliation, error)
+	// GetItems returns the items of a reconciliation, optionally only those
+	// in the given category.
+	GetItems(id, category string) ([]Item, error)
+}
+
+// service implements the Repository interface.
+type service struct {
+	db   *sqlx.DB
+	psql sq.StatementBuilderType
+}
+
+// NewRepository creates a new reconciliation repository.
+func NewRepository(db *sqlx.DB) Repository {
+	return &service{
+		db:   db,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+// Create saves a reconciliation together with all of its items.
+func (s service) Create(r *Reconciliation, items []Item) (*Reconciliation, error) {
+	query, args, err := s.psql.Insert("reconciliations").
+		Columns("file_name", "period_start", "period_end", "matched", "missing_on_our_side", "missing_on_provider_side", "amount_mismatch", "created_at").
+		Values(r.FileName, r.PeriodStart, r.PeriodEnd, r.Matched, r.MissingOnOurSide, r.MissingOnProviderSide, r.AmountMismatch, r.CreatedAt).
+		Suffix("RETURNING *").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	//use transaction so a report is never stored with only some of its items
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	var created Reconciliation
+	if err := tx.Get(&created, query, args...); err != nil {
+		tx.Rollback()
+		if err, ok := err.(*pq.Error); ok {
+			if err.Code.Name() == "unique_violation" {
+				return nil, utils.Conflict("reconciliation already exists")
+			}
+		}
+		return nil, err
+	}
+
+	for start := 0; start < len(items); start += insertChunkSize {
+		end := start + insertChunkSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		insert := s.psql.Insert("reconciliation_items").
+			Columns("reconciliation_id", "category", "reference", "transaction_id", "our_amount", "provider_amount", "note")
+		for _, item := range items[start:end] {
+			insert = insert.Values(created.ID, item.Category, item.Reference, item.TransactionID, item.OurAmount, item.ProviderAmount, item.Note)
+		}
+
+		query, args, err := insert.ToSql()
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if _, err := tx.Exec(query, args...); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	//commit the transaction
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// GetReconciliationByID returns the reconciliation with the given id.
+func (s service) GetReconciliationByID(id string) (*Reconciliation, error) {
+	query, args, err := s.psql.Select("*").
+		From("reconciliations").
+		Where(sq.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var r Reconciliation
+	if err := s.db.Get(&r, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("reconciliation not found")
+		}
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// GetReconciliations returns the most recent reconciliations, newest first.
+func (s service) GetReconciliations(limit int) ([]Reconciliation, error) {
+	query, args, err := s.psql.Select("*").
+		From("reconciliations").
+		OrderBy("created_at DESC").
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	reconciliations := []Reconciliation{}
+	if err := s.db.Select(&reconciliations, query, args...); err != nil {
+		return nil, err
+	}
+
+	return reconciliations, nil
+}
+
+// GetItems returns the items of a reconciliation, optionally only those in
+// the given category.
+func (s service) GetItems(id, category string) ([]Item, error) {
+	builder := s.psql.Select("*").
+		From("reconciliation_items").
+		Where(sq.Eq{"reconciliation_id": id}).
+		OrderBy("category", "reference")
+	if category != "" {
+		builder = builder.Where(sq.Eq{"category": category})
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	items := []Item{}
+	if err := s.db.Select(&items, query, args...); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
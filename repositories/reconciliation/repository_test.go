@@ -0,0 +1,68 @@
+package reconciliation
+
+import (
+	"p-system/tests"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciliationRepository(t *testing.T) {
+	db := tests.StartDB(t)
+
+	repo := NewRepository(db)
+
+	//run seeds
+	err := tests.Seed(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transactionID := "d164e69d-26f5-448d-a18c-baeae517d9f5"
+	amount := int64(1000)
+	items := []Item{
+		{Category: CategoryMatched, Reference: "unique_reference", TransactionID: &transactionID, OurAmount: &amount, ProviderAmount: &amount},
+		{Category: CategoryMissingOnOurSide, Reference: "unknown", ProviderAmount: &amount},
+	}
+	start := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	var created *Reconciliation
+
+	t.Run("TestCreateReconciliation_Success", func(t *testing.T) {
+		created, err = repo.Create(NewReconciliation("settlement-2024-05-01.csv", start, start.AddDate(0, 0, 1), items), items)
+
+		require.NoError(t, err)
+		require.Equal(t, 1, created.Matched)
+		require.Equal(t, 1, created.MissingOnOurSide)
+	})
+
+	t.Run("TestCreateReconciliation_DuplicateFile", func(t *testing.T) {
+		_, err := repo.Create(NewReconciliation("settlement-2024-05-01.csv", start, start.AddDate(0, 0, 1), nil), nil)
+
+		require.EqualError(t, err, "reconciliation already exists")
+	})
+
+	t.Run("TestGetReconciliationByID_NotFound", func(t *testing.T) {
+		_, err := repo.GetReconciliationByID("d164e69d-26f5-448d-a18c-baeae517d000")
+
+		require.EqualError(t, err, "reconciliation not found")
+	})
+
+	t.Run("TestGetItems_ByCategory", func(t *testing.T) {
+		all, err := repo.GetItems(created.ID, "")
+		require.NoError(t, err)
+		require.Len(t, all, 2)
+
+		missing, err := repo.GetItems(created.ID, CategoryMissingOnOurSide)
+		require.NoError(t, err)
+		require.Len(t, missing, 1)
+		require.Equal(t, "unknown", missing[0].Reference)
+	})
+
+	t.Run("TestGetReconciliations_Success", func(t *testing.T) {
+		reconciliations, err := repo.GetReconciliations(10)
+
+		require.NoError(t, err)
+		require.Len(t, reconciliations, 1)
+	})
+}
@@ -0,0 +1,428 @@
+// Package repotest is a conformance suite for implementations of the user,
+// wallet and transaction repositories, so the in-memory ones can be relied on
+// to behave like the Postgres ones.
+package repotest
+
+import (
+	"context"
+	"p-system/repositories/transaction"
+	"p-system/repositories/user"
+	"p-system/repositories/wallet"
+	"p-system/utils"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The rows tests.Seed inserts, which the suite expects to start from.
+const (
+	UserID               = "d164e69d-26f5-448d-a18c-baeae517d9f2"
+	WalletID             = "d164e69d-26f5-448d-a18c-baeae517d991"
+	TransactionID        = "d164e69d-26f5-448d-a18c-baeae517d9f5"
+	TransactionReference = "unique_reference"
+)
+
+// missingID is a well-formed id no row has.
+const missingID = "00000000-0000-0000-0000-000000000000"
+
+// Repositories are the implementations under test. They must share one store
+// holding the seed data and nothing else.
+type Repositories struct {
+	Users        user.Repository
+	Wallets      wallet.Repository
+	Transactions transaction.Repository
+}
+
+// Run runs the conformance suite against repos. The subtests build on each
+// other, so they cannot be run on their own.
+func Run(t *testing.T, repos Repositories) {
+	ctx := context.Background()
+
+	t.Run("Users", func(t *testing.T) {
+		testUsers(ctx, t, repos.Users)
+	})
+	t.Run("Transactions", func(t *testing.T) {
+		testTransactions(ctx, t, repos.Transactions)
+	})
+	t.Run("Wallets", func(t *testing.T) {
+		testWallets(ctx, t, repos.Wallets)
+	})
+}
+
+func testUsers(ctx context.Context, t *testing.T, repo user.Repository) {
+	t.Run("TestGetUserByID_Success", func(t *testing.T) {
+		u, err := repo.GetUserByID(ctx, UserID)
+
+		require.NoError(t, err)
+		require.Equal(t, UserID, u.ID)
+		require.Equal(t, "john_doed", u.Username)
+	})
+
+	t.Run("TestGetUserByID_NotFound", func(t *testing.T) {
+		_, err := repo.GetUserByID(ctx, missingID)
+
+		require.EqualError(t, err, "user not found")
+	})
+
+	t.Run("TestGetUsersByIDs", func(t *testing.T) {
+		users, err := repo.GetUsersByIDs(ctx, []string{UserID, missingID})
+
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		require.Equal(t, UserID, users[0].ID)
+
+		users, err = repo.GetUsersByIDs(ctx, nil)
+
+		require.NoError(t, err)
+		require.Empty(t, users)
+	})
+}
+
+func testTransactions(ctx context.Context, t *testing.T, repo transaction.Repository) {
+	var created *transaction.Transaction
+
+	t.Run("TestCreate_Success", func(t *testing.T) {
+		var err error
+		created, err = repo.Create(ctx, transaction.NewTransaction(UserID, "request", "newref", "credit", 500))
+
+		require.NoError(t, err)
+		require.NotEmpty(t, created.ID)
+		require.Equal(t, "pending", created.Status)
+		require.Equal(t, int64(500), created.Amount)
+	})
+
+	t.Run("TestCreate_DuplicateReference", func(t *testing.T) {
+		_, err := repo.Create(ctx, transaction.NewTransaction(UserID, "request", "newref", "credit", 500))
+
+		require.EqualError(t, err, "transaction already exists")
+	})
+
+	t.Run("TestCreate_UnknownUser", func(t *testing.T) {
+		_, err := repo.Create(ctx, transaction.NewTransaction(missingID, "request", "orphan", "credit", 500))
+
+		require.ErrorIs(t, err, utils.ErrNotFound)
+		require.EqualError(t, err, "user not found")
+	})
+
+	t.Run("TestCreate_NonPositiveAmount", func(t *testing.T) {
+		_, err := repo.Create(ctx, transaction.NewTransaction(UserID, "request", "zero", "credit", 0))
+
+		require.ErrorIs(t, err, utils.ErrInvalidRequest)
+		require.EqualError(t, err, "amount must be positive")
+	})
+
+	t.Run("TestCreate_InvalidType", func(t *testing.T) {
+		_, err := repo.Create(ctx, transaction.NewTransaction(UserID, "request", "refund", "refund", 500))
+
+		require.ErrorIs(t, err, utils.ErrInvalidRequest)
+	})
+
+	t.Run("TestGetTransactionByReference", func(t *testing.T) {
+		found, err := repo.GetTransactionByReference(ctx, "newref")
+		require.NoError(t, err)
+		require.Equal(t, created.ID, found.ID)
+
+		_, err = repo.GetTransactionByReference(ctx, "nonexistentref")
+		require.EqualError(t, err, "transaction not found")
+	})
+
+	t.Run("TestGetTransactionByID", func(t *testing.T) {
+		found, err := repo.GetTransactionByID(ctx, TransactionID)
+		require.NoError(t, err)
+		require.Equal(t, TransactionReference, found.Reference)
+
+		_, err = repo.GetTransactionByID(ctx, missingID)
+		require.EqualError(t, err, "transaction not found")
+	})
+
+	t.Run("TestGetPendingBefore", func(t *testing.T) {
+		pending, err := repo.GetPendingBefore(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		require.Equal(t, created.ID, pending[0].ID)
+
+		pending, err = repo.GetPendingBefore(ctx, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		require.NotNil(t, pending)
+		require.Empty(t, pending)
+	})
+
+	t.Run("TestUpdateTransactionToFailed_RecordsHistory", func(t *testing.T) {
+		failed, err := repo.UpdateTransactionToFailed(ctx, created.ID)
+		require.NoError(t, err)
+		require.Equal(t, "failed", failed.Status)
+
+		history, err := repo.GetStatusHistory(ctx, created.ID)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		require.Equal(t, "pending", history[0].Status)
+		require.Equal(t, "failed", history[1].Status)
+	})
+
+	t.Run("TestUpdateTransaction_NotPending", func(t *testing.T) {
+		// A transaction is settled once, whoever gets to it first
+		_, err := repo.UpdateTransactionToCompleted(ctx, created.ID)
+		require.ErrorIs(t, err, utils.ErrConflict)
+		require.EqualError(t, err, "transaction is failed")
+
+		_, err = repo.UpdateTransactionToFailed(ctx, TransactionID)
+		require.ErrorIs(t, err, utils.ErrConflict)
+
+		history, err := repo.GetStatusHistory(ctx, created.ID)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+	})
+
+	t.Run("TestUpdateTransactionToCompleted_NotFound", func(t *testing.T) {
+		_, err := repo.UpdateTransactionToCompleted(ctx, missingID)
+		require.EqualError(t, err, "transaction not found")
+
+		_, err = repo.UpdateTransactionToFailed(ctx, missingID)
+		require.EqualError(t, err, "transaction not found")
+	})
+
+	t.Run("TestGetStatusHistory_Empty", func(t *testing.T) {
+		history, err := repo.GetStatusHistory(ctx, missingID)
+
+		require.NoError(t, err)
+		require.Empty(t, history)
+	})
+
+	t.Run("TestCountByStatus", func(t *testing.T) {
+		completed, err := repo.CountByStatus(ctx, "completed")
+		require.NoError(t, err)
+		require.Equal(t, int64(1), completed)
+
+		failed, err := repo.CountByStatus(ctx, "failed")
+		require.NoError(t, err)
+		require.Equal(t, int64(1), failed)
+	})
+
+	t.Run("TestCompletedTransactions", func(t *testing.T) {
+		from, to := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+		transactions, err := repo.GetCompletedTransactions(ctx, UserID, from, to)
+		require.NoError(t, err)
+		require.Len(t, transactions, 1)
+		require.Equal(t, TransactionReference, transactions[0].Reference)
+
+		transactions, err = repo.GetCompletedTransactionsBetween(ctx, from, to)
+		require.NoError(t, err)
+		require.Len(t, transactions, 1)
+
+		transactions, err = repo.GetCompletedTransactions(ctx, UserID, to, to.Add(time.Hour))
+		require.NoError(t, err)
+		require.Empty(t, transactions)
+	})
+
+	t.Run("TestGetNetAmountSince", func(t *testing.T) {
+		net, err := repo.GetNetAmountSince(ctx, UserID, time.Now().Add(-time.Hour))
+
+		require.NoError(t, err)
+		require.Equal(t, int64(1000), net)
+	})
+
+	t.Run("TestGetTransactionsByReferences", func(t *testing.T) {
+		transactions, err := repo.GetTransactionsByReferences(ctx, []string{TransactionReference, "newref", "nonexistentref"})
+		require.NoError(t, err)
+		require.Len(t, transactions, 2)
+
+		transactions, err = repo.GetTransactionsByReferences(ctx, nil)
+		require.NoError(t, err)
+		require.NotNil(t, transactions)
+		require.Empty(t, transactions)
+	})
+
+	t.Run("TestCompletedTransactions_KeyedOnCompletion", func(t *testing.T) {
+		// A credit and a debit that cancel out, asked for hours before they
+		// completed, so later balances are unchanged
+		for _, kind := range []string{"credit", "debit"} {
+			requested := transaction.NewTransaction(UserID, "late_"+kind, "late_"+kind, kind, 300)
+			requested.CreatedAt = time.Now().Add(-3 * time.Hour)
+
+			pending, err := repo.Create(ctx, requested)
+			require.NoError(t, err)
+			require.Nil(t, pending.CompletedAt)
+
+			completed, err := repo.UpdateTransactionToCompleted(ctx, pending.ID)
+			require.NoError(t, err)
+			require.NotNil(t, completed.CompletedAt)
+		}
+
+		transactions, err := repo.GetCompletedTransactions(ctx, UserID, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		require.Len(t, transactions, 3)
+		require.Equal(t, []string{TransactionReference, "late_credit", "late_debit"}, []string{transactions[0].Reference, transactions[1].Reference, transactions[2].Reference})
+
+		transactions, err = repo.GetCompletedTransactions(ctx, UserID, time.Now().Add(-4*time.Hour), time.Now().Add(-2*time.Hour))
+		require.NoError(t, err)
+		require.Empty(t, transactions)
+
+		net, err := repo.GetNetAmountSince(ctx, UserID, time.Now().Add(-2*time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, int64(1000), net)
+	})
+}
+
+func testWallets(ctx context.Context, t *testing.T, repo wallet.Repository) {
+	seeded := &wallet.Wallet{ID: WalletID, UserID: UserID}
+	paidBy := transaction.Transaction{ID: TransactionID}
+
+	t.Run("TestGetWallet", func(t *testing.T) {
+		w, err := repo.GetWalletByID(ctx, WalletID)
+		require.NoError(t, err)
+		require.Equal(t, UserID, w.UserID)
+		require.Equal(t, wallet.StatusActive, w.Status)
+		require.Equal(t, int64(1), w.Version)
+
+		w, err = repo.GetWalletByUserID(ctx, UserID)
+		require.NoError(t, err)
+		require.Equal(t, WalletID, w.ID)
+
+		_, err = repo.GetWalletByID(ctx, missingID)
+		require.EqualError(t, err, "wallet not found")
+
+		_, err = repo.GetWalletByUserID(ctx, missingID)
+		require.EqualError(t, err, "wallet not found")
+	})
+
+	t.Run("TestCheckBalances_ReportsMismatch", func(t *testing.T) {
+		// The seeded completed credit never reached the seeded wallet
+		discrepancies, err := repo.CheckBalances(ctx)
+
+		require.NoError(t, err)
+		require.Equal(t, []wallet.Discrepancy{{WalletID: WalletID, UserID: UserID, Balance: 0, Expected: 1000}}, discrepancies)
+	})
+
+	t.Run("TestCreditWallet", func(t *testing.T) {
+		w, err := repo.CreditWallet(ctx, seeded, paidBy, 1000)
+
+		require.NoError(t, err)
+		require.Equal(t, int64(1000), w.Balance)
+		require.Equal(t, int64(2), w.Version)
+		require.Equal(t, TransactionID, *w.TransactionID)
+		require.Equal(t, TransactionID, *seeded.TransactionID)
+
+		discrepancies, err := repo.CheckBalances(ctx)
+		require.NoError(t, err)
+		require.Empty(t, discrepancies)
+	})
+
+	t.Run("TestDebitWallet", func(t *testing.T) {
+		w, err := repo.DebitWallet(ctx, seeded, paidBy, 400)
+
+		require.NoError(t, err)
+		require.Equal(t, int64(600), w.Balance)
+		require.Equal(t, int64(3), w.Version)
+	})
+
+	t.Run("TestCreditWallet_Version", func(t *testing.T) {
+		// Updates of a wallet at a version it has moved on from fail
+		_, err := repo.CreditWallet(ctx, &wallet.Wallet{ID: WalletID, Version: 2}, paidBy, 1)
+		require.ErrorIs(t, err, utils.ErrPreconditionFailed)
+
+		w, err := repo.CreditWallet(ctx, &wallet.Wallet{ID: WalletID, Version: 3}, paidBy, 1)
+		require.NoError(t, err)
+		require.Equal(t, int64(601), w.Balance)
+		require.Equal(t, int64(4), w.Version)
+
+		w, err = repo.DebitWallet(ctx, seeded, paidBy, 1)
+		require.NoError(t, err)
+		require.Equal(t, int64(600), w.Balance)
+
+		_, err = repo.CreditWallet(ctx, &wallet.Wallet{ID: missingID}, paidBy, 1)
+		require.EqualError(t, err, "wallet not found")
+	})
+
+	t.Run("TestDebitWallet_InsufficientFunds", func(t *testing.T) {
+		_, err := repo.DebitWallet(ctx, seeded, paidBy, 601)
+		require.ErrorIs(t, err, utils.ErrInsufficientFunds)
+
+		w, err := repo.GetWalletByID(ctx, WalletID)
+		require.NoError(t, err)
+		require.Equal(t, int64(600), w.Balance)
+	})
+
+	t.Run("TestCreditWallet_Concurrent", func(t *testing.T) {
+		// Concurrent updates of one wallet must not lose any of them
+		var wg sync.WaitGroup
+		errs := make(chan error, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := repo.CreditWallet(ctx, &wallet.Wallet{ID: WalletID}, paidBy, 10)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			require.NoError(t, err)
+		}
+
+		w, err := repo.GetWalletByID(ctx, WalletID)
+		require.NoError(t, err)
+		require.Equal(t, int64(700), w.Balance)
+	})
+
+	t.Run("TestGetBalanceAt", func(t *testing.T) {
+		balance, err := repo.GetBalanceAt(ctx, WalletID, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, int64(700), balance)
+
+		balance, err = repo.GetBalanceAt(ctx, WalletID, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, int64(0), balance)
+
+		_, err = repo.GetBalanceAt(ctx, missingID, time.Now())
+		require.EqualError(t, err, "wallet not found")
+	})
+
+	t.Run("TestSnapshotBalances", func(t *testing.T) {
+		at := time.Now().Add(time.Minute)
+
+		taken, err := repo.SnapshotBalances(ctx, at, 24*time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), taken)
+
+		// Wallets with a recent snapshot are skipped
+		taken, err = repo.SnapshotBalances(ctx, at.Add(time.Hour), 24*time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, int64(0), taken)
+
+		balance, err := repo.GetBalanceAt(ctx, WalletID, at.Add(time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, int64(700), balance)
+	})
+
+	t.Run("TestUpdateWalletStatus", func(t *testing.T) {
+		w, err := repo.UpdateWalletStatus(ctx, WalletID, wallet.StatusFrozen)
+		require.NoError(t, err)
+		require.Equal(t, wallet.StatusFrozen, w.Status)
+		require.Equal(t, int64(16), w.Version)
+
+		_, err = repo.UpdateWalletStatus(ctx, missingID, wallet.StatusFrozen)
+		require.EqualError(t, err, "wallet not found")
+
+		_, err = repo.UpdateWalletStatus(ctx, WalletID, "closed")
+		require.ErrorIs(t, err, utils.ErrInvalidRequest)
+	})
+
+	t.Run("TestCreate", func(t *testing.T) {
+		// Every user has at most one wallet, and the seeded user has one
+		_, err := repo.Create(ctx, wallet.NewWallet(UserID, 250))
+		require.ErrorIs(t, err, utils.ErrConflict)
+		require.EqualError(t, err, "user already has a wallet")
+
+		_, err = repo.Create(ctx, wallet.NewWallet(missingID, 0))
+		require.ErrorIs(t, err, utils.ErrNotFound)
+		require.EqualError(t, err, "user not found")
+
+		_, err = repo.Create(ctx, wallet.NewWallet(UserID, -1))
+		require.ErrorIs(t, err, utils.ErrInvalidRequest)
+	})
+}
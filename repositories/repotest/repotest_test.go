@@ -0,0 +1,49 @@
+package repotest
+
+import (
+	"p-system/logging"
+	"p-system/repositories/memory"
+	"p-system/repositories/transaction"
+	"p-system/repositories/user"
+	"p-system/repositories/wallet"
+	"p-system/tests"
+	"testing"
+)
+
+func TestMemory(t *testing.T) {
+	store := memory.NewStore()
+
+	// Seed the same rows as tests.Seed
+	store.PutUser(user.User{ID: UserID, Username: "john_doed", Email: "john@ample.com", Password: "hashed_password_here"})
+	store.PutWallet(wallet.Wallet{ID: WalletID, UserID: UserID})
+	store.PutTransaction(transaction.Transaction{
+		ID:        TransactionID,
+		UserID:    UserID,
+		RequestID: "unique_request_id",
+		Amount:    1000,
+		Type:      "credit",
+		Status:    "completed",
+		Reference: TransactionReference,
+	})
+
+	Run(t, Repositories{
+		Users:        store.Users(),
+		Wallets:      store.Wallets(),
+		Transactions: store.Transactions(),
+	})
+}
+
+func TestPostgres(t *testing.T) {
+	db := tests.StartDB(t)
+
+	// Run seeds
+	if err := tests.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	Run(t, Repositories{
+		Users:        user.NewRepository(db),
+		Wallets:      wallet.NewRepository(db, nil, logging.Discard()),
+		Transactions: transaction.NewRepository(db),
+	})
+}
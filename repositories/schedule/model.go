@@ -0,0 +1,166 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// FrequencyOnce runs the schedule a single time at StartAt.
+	FrequencyOnce = "once"
+	// FrequencyDaily runs the schedule every day at the time of StartAt.
+	FrequencyDaily = "daily"
+	// FrequencyWeekly runs the schedule every week on the weekday of StartAt.
+	FrequencyWeekly = "weekly"
+	// FrequencyMonthly runs the schedule every month on the day of StartAt,
+	// clamped to the last day of shorter months.
+	FrequencyMonthly = "monthly"
+)
+
+const (
+	StatusActive    = "active"
+	StatusPaused    = "paused"
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+)
+
+const (
+	RunStatusSucceeded = "succeeded"
+	RunStatusRetrying  = "retrying"
+	RunStatusFailed    = "failed"
+	// RunStatusHeld is a run above the approval threshold, made once its
+	// approval is approved.
+	RunStatusHeld = "held"
+)
+
+// Changes are the fields of a schedule its owner can change. Nil fields are
+// left as they are.
+type Changes struct {
+	Amount *int64
+	EndAt  *time.Time
+	// Status is active or paused.
+	Status *string
+}
+
+// Schedule is a credit, debit or transfer that runs at a future time or on a
+// recurrence.
+type Schedule struct {
+	ID                 string     `json:"id" db:"id"`
+	UserID             string     `json:"user_id" db:"user_id"`
+	CounterpartyUserID *string    `json:"counterparty_user_id,omitempty" db:"counterparty_user_id"`
+	Type               string     `json:"type" db:"type"`
+	Amount             int64      `json:"amount" db:"amount"`
+	Reference          string     `json:"reference" db:"reference"`
+	Frequency          string     `json:"frequency" db:"frequency"`
+	Status             string     `json:"status" db:"status"`
+	StartAt            time.Time  `json:"start_at" db:"start_at"`
+	EndAt              *time.Time `json:"end_at,omitempty" db:"end_at"`
+	NextRunAt          *time.Time `json:"next_run_at,omitempty" db:"next_run_at"`
+	Occurrence         int        `json:"occurrence" db:"occurrence"`
+	Attempts           int        `json:"attempts" db:"attempts"`
+	MaxAttempts        int        `json:"max_attempts" db:"max_attempts"`
+	LastError          *string    `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at" db:"updated_at"`
+	// ClaimedUntil is when the claim of the worker running the schedule
+	// lapses.
+	ClaimedUntil *time.Time `json:"-" db:"claimed_until"`
+	// Operator is the operator who made the schedule, if one did. Runs held
+	// for approval are made in their name.
+	Operator *string `json:"operator,omitempty" db:"operator"`
+}
+
+// Run records the outcome of a single attempt at running a schedule.
+type Run struct {
+	ID         string    `json:"id" db:"id"`
+	ScheduleID string    `json:"schedule_id" db:"schedule_id"`
+	Occurrence int       `json:"occurrence" db:"occurrence"`
+	Attempt    int       `json:"attempt" db:"attempt"`
+	Status     string    `json:"status" db:"status"`
+	Reference  string    `json:"reference" db:"reference"`
+	Error      *string   `json:"error,omitempty" db:"error"`
+	RanAt      time.Time `json:"ran_at" db:"ran_at"`
+}
+
+// NewSchedule creates a new active schedule whose first run is at startAt.
+func NewSchedule(userID, reference, transactionType, frequency string, amount int64, startAt time.Time) *Schedule {
+	return &Schedule{
+		UserID:      userID,
+		Type:        transactionType,
+		Amount:      amount,
+		Reference:   reference,
+		Frequency:   frequency,
+		Status:      StatusActive,
+		StartAt:     startAt,
+		NextRunAt:   &startAt,
+		MaxAttempts: 3,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+}
+
+// OccurrenceAt returns the time of the n-th occurrence of the schedule,
+// counting from zero at StartAt.
+func (s Schedule) OccurrenceAt(n int) time.Time {
+	switch s.Frequency {
+	case FrequencyDaily:
+		return s.StartAt.AddDate(0, 0, n)
+	case FrequencyWeekly:
+		return s.StartAt.AddDate(0, 0, 7*n)
+	case FrequencyMonthly:
+		return addMonthsClamped(s.StartAt, n)
+	default:
+		return s.StartAt
+	}
+}
+
+// Advance moves the schedule past its current occurrence. Occurrences that
+// were missed while no worker was running are skipped rather than replayed.
+// The schedule is marked completed once there is nothing left to run.
+func (s *Schedule) Advance(now time.Time) {
+	s.Attempts = 0
+
+	if s.Frequency == FrequencyOnce || s.Frequency == "" {
+		s.complete()
+		return
+	}
+
+	s.Occurrence++
+	next := s.OccurrenceAt(s.Occurrence)
+	for !next.After(now) {
+		s.Occurrence++
+		next = s.OccurrenceAt(s.Occurrence)
+	}
+
+	if s.EndAt != nil && next.After(*s.EndAt) {
+		s.complete()
+		return
+	}
+
+	s.NextRunAt = &next
+}
+
+func (s *Schedule) complete() {
+	s.Status = StatusCompleted
+	s.NextRunAt = nil
+}
+
+// RunReference returns the transaction reference used for the given attempt
+// at the current occurrence. leg distinguishes the debit and credit sides of a
+// transfer and is empty for plain credits and debits.
+func (s Schedule) RunReference(attempt int, leg string) string {
+	return fmt.Sprintf("%s-%d%s-%d", s.Reference, s.Occurrence+1, leg, attempt)
+}
+
+// addMonthsClamped adds months to t keeping the day of month of t, or the last
+// day of the target month if it is shorter.
+func addMonthsClamped(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+
+	return first.AddDate(0, 0, day-1)
+}
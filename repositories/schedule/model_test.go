@@ -0,0 +1,56 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOccurrenceAt_MonthlyClampsToEndOfMonth(t *testing.T) {
+	s := Schedule{
+		Frequency: FrequencyMonthly,
+		StartAt:   time.Date(2024, time.January, 31, 9, 0, 0, 0, time.UTC),
+	}
+
+	require.Equal(t, time.Date(2024, time.February, 29, 9, 0, 0, 0, time.UTC), s.OccurrenceAt(1))
+	require.Equal(t, time.Date(2024, time.March, 31, 9, 0, 0, 0, time.UTC), s.OccurrenceAt(2))
+	require.Equal(t, time.Date(2024, time.April, 30, 9, 0, 0, 0, time.UTC), s.OccurrenceAt(3))
+	require.Equal(t, time.Date(2025, time.February, 28, 9, 0, 0, 0, time.UTC), s.OccurrenceAt(13))
+}
+
+func TestAdvance_SkipsMissedOccurrences(t *testing.T) {
+	start := time.Date(2024, time.May, 1, 9, 0, 0, 0, time.UTC)
+	s := NewSchedule("user123", "ref", "credit", FrequencyDaily, 100, start)
+	s.Attempts = 2
+
+	s.Advance(start.AddDate(0, 0, 3).Add(time.Hour))
+
+	require.Equal(t, 4, s.Occurrence)
+	require.Equal(t, 0, s.Attempts)
+	require.Equal(t, start.AddDate(0, 0, 4), *s.NextRunAt)
+	require.Equal(t, StatusActive, s.Status)
+}
+
+func TestAdvance_CompletesAfterEnd(t *testing.T) {
+	start := time.Date(2024, time.May, 1, 9, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+	s := NewSchedule("user123", "ref", "credit", FrequencyWeekly, 100, start)
+	s.EndAt = &end
+
+	s.Advance(start)
+	require.Equal(t, StatusActive, s.Status)
+
+	s.Advance(end)
+	require.Equal(t, StatusCompleted, s.Status)
+	require.Nil(t, s.NextRunAt)
+}
+
+func TestAdvance_OnceCompletes(t *testing.T) {
+	s := NewSchedule("user123", "ref", "debit", FrequencyOnce, 100, time.Now())
+
+	s.Advance(time.Now())
+
+	require.Equal(t, StatusCompleted, s.Status)
+	require.Nil(t, s.NextRunAt)
+}
@@ -0,0 +1,303 @@
+package schedule
+
+import (
+	"context"
+	"database/sql"
+	DB "p-system/db"
+	"p-system/metrics"
+	"p-system/tracing"
+	"p-system/utils"
+	"sort"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+)
+
+//go:generate mockgen --source=repository.go -destination=repository_mock.go -package=schedule Repository
+type Repository interface {
+	// Create creates a new schedule.
+	Create(context.Context, *Schedule) (*Schedule, error)
+	// GetScheduleByID returns the schedule with the given id.
+	GetScheduleByID(ctx context.Context, id string) (*Schedule, error)
+	// GetSchedulesByUserID returns the schedules belonging to the given user.
+	GetSchedulesByUserID(ctx context.Context, userID string) ([]Schedule, error)
+	// Update sets the fields of the schedule with the given id that changes
+	// sets, unless the schedule has finished. Nothing else is written, so an
+	// update never undoes a run saved in the meantime.
+	Update(ctx context.Context, id string, changes Changes) (*Schedule, error)
+	// Cancel cancels the schedule with the given id unless it has finished.
+	Cancel(ctx context.Context, id string) (*Schedule, error)
+	// GetRunsByScheduleID returns the runs recorded for the given schedule.
+	GetRunsByScheduleID(ctx context.Context, id string) ([]Run, error)
+	// ProcessDue claims up to limit active schedules due at now, skipping any
+	// claimed by another worker, and calls fn for each. The run returned by fn
+	// is recorded and the run state left by fn is saved as soon as fn
+	// returns, without undoing changes made to the schedule in the meantime,
+	// even if ctx is cancelled by then.
+	ProcessDue(ctx context.Context, now time.Time, limit int, fn func(context.Context, *Schedule) Run) (int, error)
+}
+
+// service implements the Repository interface.
+type service struct {
+	db   DB.Queryer
+	psql sq.StatementBuilderType
+}
+
+// NewRepository creates a new schedule repository.
+func NewRepository(db DB.Queryer) Repository {
+	return &service{
+		db:   db,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+	}
+}
+
+// Create creates a new schedule.
+func (s service) Create(ctx context.Context, schedule *Schedule) (*Schedule, error) {
+	ctx, span := tracing.StartDB(ctx, "schedule.Create")
+	defer span.End()
+
+	query, args, err := s.psql.Insert("schedules").
+		Columns("user_id", "counterparty_user_id", "type", "amount", "reference", "frequency", "status", "start_at", "end_at", "next_run_at", "max_attempts", "operator", "created_at", "updated_at").
+		Values(schedule.UserID, schedule.CounterpartyUserID, schedule.Type, schedule.Amount, schedule.Reference, schedule.Frequency, schedule.Status, schedule.StartAt, schedule.EndAt, schedule.NextRunAt, schedule.MaxAttempts, schedule.Operator, schedule.CreatedAt, schedule.UpdatedAt).
+		Suffix("RETURNING *").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var sc Schedule
+	if err := s.db.GetContext(ctx, &sc, query, args...); err != nil {
+		if err, ok := err.(*pq.Error); ok {
+			if err.Code.Name() == "unique_violation" {
+				return nil, utils.DuplicateReference("schedule already exists")
+			}
+		}
+
+		return nil, err
+	}
+
+	return &sc, nil
+}
+
+// GetScheduleByID returns the schedule with the given id.
+func (s service) GetScheduleByID(ctx context.Context, id string) (*Schedule, error) {
+	ctx, span := tracing.StartDB(ctx, "schedule.GetScheduleByID")
+	defer span.End()
+
+	query, args, err := s.psql.Select("*").
+		From("schedules").
+		Where(sq.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var sc Schedule
+	if err := s.db.GetContext(ctx, &sc, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("schedule not found")
+		}
+		return nil, err
+	}
+
+	return &sc, nil
+}
+
+// GetSchedulesByUserID returns the schedules belonging to the given user.
+func (s service) GetSchedulesByUserID(ctx context.Context, userID string) ([]Schedule, error) {
+	ctx, span := tracing.StartDB(ctx, "schedule.GetSchedulesByUserID")
+	defer span.End()
+
+	query, args, err := s.psql.Select("*").
+		From("schedules").
+		Where(sq.Eq{"user_id": userID}).
+		OrderBy("created_at").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := []Schedule{}
+	if err := s.db.SelectContext(ctx, &schedules, query, args...); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+// Update sets the fields of the schedule with the given id that changes sets,
+// unless the schedule has finished. Runs in progress do not write these
+// fields back when they are saved. A schedule whose next run falls after its
+// new end is completed, since there is nothing left to run.
+func (s service) Update(ctx context.Context, id string, changes Changes) (*Schedule, error) {
+	ctx, span := tracing.StartDB(ctx, "schedule.Update")
+	defer span.End()
+
+	update := s.psql.Update("schedules").
+		Set("updated_at", time.Now()).
+		Where(sq.Eq{"id": id, "status": []string{StatusActive, StatusPaused}})
+	if changes.Amount != nil {
+		update = update.Set("amount", *changes.Amount)
+	}
+	var status sq.Sqlizer
+	if changes.Status != nil {
+		status = sq.Expr("?", *changes.Status)
+	}
+	if changes.EndAt != nil {
+		end := *changes.EndAt
+		if status == nil {
+			status = sq.Expr("status")
+		}
+		status = sq.Expr("CASE WHEN next_run_at > ? THEN ? ELSE ? END", end, StatusCompleted, status)
+		update = update.Set("end_at", end).
+			Set("next_run_at", sq.Expr("CASE WHEN next_run_at > ? THEN NULL ELSE next_run_at END", end)).
+			Where(sq.LtOrEq{"start_at": end})
+	}
+	if status != nil {
+		update = update.Set("status", status)
+	}
+
+	query, args, err := update.Suffix("RETURNING *").ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.change(ctx, id, query, args, utils.InvalidRequest("end_at must be after start_at"))
+}
+
+// Cancel cancels the schedule with the given id unless it has finished.
+func (s service) Cancel(ctx context.Context, id string) (*Schedule, error) {
+	ctx, span := tracing.StartDB(ctx, "schedule.Cancel")
+	defer span.End()
+
+	query, args, err := s.psql.Update("schedules").
+		Set("status", StatusCancelled).
+		Set("next_run_at", nil).
+		Set("updated_at", time.Now()).
+		Where(sq.Eq{"id": id, "status": []string{StatusActive, StatusPaused}}).
+		Suffix("RETURNING *").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.change(ctx, id, query, args, nil)
+}
+
+// change runs an update of the schedule with the given id that only applies
+// to unfinished schedules. When it does not apply it reports whether the
+// schedule is missing or finished, or else returns invalid.
+func (s service) change(ctx context.Context, id, query string, args []interface{}, invalid error) (*Schedule, error) {
+	var sc Schedule
+	err := s.db.GetContext(ctx, &sc, query, args...)
+	if err == nil {
+		return &sc, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	current, err := s.GetScheduleByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status == StatusCompleted || current.Status == StatusCancelled || invalid == nil {
+		return nil, utils.Conflict("schedule is " + current.Status)
+	}
+	return nil, invalid
+}
+
+// GetRunsByScheduleID returns the runs recorded for the given schedule.
+func (s service) GetRunsByScheduleID(ctx context.Context, id string) ([]Run, error) {
+	ctx, span := tracing.StartDB(ctx, "schedule.GetRunsByScheduleID")
+	defer span.End()
+
+	query, args, err := s.psql.Select("*").
+		From("schedule_runs").
+		Where(sq.Eq{"schedule_id": id}).
+		OrderBy("ran_at").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []Run{}
+	if err := s.db.SelectContext(ctx, &runs, query, args...); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// claimLease is how long a worker has claimed the schedules it runs for. It is
+// well beyond the time a round of runs takes, so schedules only go to another
+// worker once the one that claimed them has stopped.
+const claimLease = 30 * time.Minute
+
+// ProcessDue claims up to limit active schedules due at now and calls fn for
+// each. The schedules are claimed in a statement of their own rather than
+// locked while fn runs, and each run is saved in a transaction of its own, so
+// a run that is known is never lost to a later failure. Schedules whose claim
+// lapses before their run is saved are run again, and schedules whose next
+// run falls after their end are not run at all.
+func (s service) ProcessDue(ctx context.Context, now time.Time, limit int, fn func(context.Context, *Schedule) Run) (int, error) {
+	ctx, span := tracing.StartDB(ctx, "schedule.ProcessDue")
+	defer span.End()
+
+	claimedAt := time.Now()
+
+	var due []Schedule
+	err := s.db.SelectContext(ctx, &due, `UPDATE schedules SET claimed_until = $1 WHERE id IN (
+			SELECT id FROM schedules WHERE status = $2 AND next_run_at <= $3 AND (end_at IS NULL OR next_run_at <= end_at)
+				AND (claimed_until IS NULL OR claimed_until < $4)
+			ORDER BY next_run_at LIMIT $5 FOR UPDATE SKIP LOCKED)
+		RETURNING *`, claimedAt.Add(claimLease), StatusActive, now, claimedAt, limit)
+	if err != nil {
+		return 0, err
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextRunAt.Before(*due[j].NextRunAt)
+	})
+
+	// A run that has been made is saved even once ctx is cancelled
+	saveCtx := context.WithoutCancel(ctx)
+	for i := range due {
+		sc := &due[i]
+		run := fn(ctx, sc)
+
+		if err := s.saveRun(saveCtx, sc, run); err != nil {
+			return i, err
+		}
+	}
+
+	return len(due), nil
+}
+
+// saveRun records a run and saves the state it left the schedule in. A
+// schedule cancelled, paused or completed while it ran stays so, and only a
+// run that finishes the schedule completes it.
+func (s service) saveRun(ctx context.Context, sc *Schedule, run Run) error {
+	ctx, span := tracing.StartDB(ctx, "schedule.saveRun")
+	defer span.End()
+
+	start := time.Now()
+	defer func() {
+		metrics.DBTransactionDuration.WithLabelValues("save_schedule_run").Observe(time.Since(start).Seconds())
+	}()
+
+	return DB.InTx(ctx, s.db, nil, func(tx DB.Queryer) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO schedule_runs (schedule_id, occurrence, attempt, status, reference, error, ran_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+			sc.ID, run.Occurrence, run.Attempt, run.Status, run.Reference, run.Error, run.RanAt)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE schedules SET occurrence = $1, attempts = $2, last_error = $3, updated_at = $4, claimed_until = NULL,
+				status = CASE WHEN $5::boolean AND status <> $6 THEN $7 ELSE status END,
+				next_run_at = CASE WHEN status IN ($6, $7) THEN NULL ELSE $8::timestamp END
+			WHERE id = $9`,
+			sc.Occurrence, sc.Attempts, sc.LastError, time.Now(), sc.Status == StatusCompleted, StatusCancelled, StatusCompleted, sc.NextRunAt, sc.ID)
+		return err
+	})
+}
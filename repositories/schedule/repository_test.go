@@ -0,0 +1,192 @@
+package schedule
+
+import (
+	"context"
+	"p-system/tests"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleRepository(t *testing.T) {
+	db := tests.StartDB(t)
+
+	repo := NewRepository(db)
+	ctx := context.Background()
+
+	//run seeds
+	err := tests.Seed(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID := "d164e69d-26f5-448d-a18c-baeae517d9f2"
+	var created *Schedule
+
+	t.Run("TestCreateSchedule_Success", func(t *testing.T) {
+		newSchedule := NewSchedule(userID, "rent", "debit", FrequencyMonthly, 5000, time.Now().Add(-time.Minute))
+		operator := "alice"
+		newSchedule.Operator = &operator
+
+		created, err = repo.Create(ctx, newSchedule)
+
+		require.NoError(t, err)
+		require.Equal(t, userID, created.UserID)
+		require.Equal(t, StatusActive, created.Status)
+		require.Equal(t, "alice", *created.Operator)
+	})
+
+	t.Run("TestCreateSchedule_DuplicateReference", func(t *testing.T) {
+		_, err := repo.Create(ctx, NewSchedule(userID, "rent", "debit", FrequencyMonthly, 5000, time.Now()))
+
+		require.EqualError(t, err, "schedule already exists")
+	})
+
+	t.Run("TestGetScheduleByID_NotFound", func(t *testing.T) {
+		_, err := repo.GetScheduleByID(ctx, "d164e69d-26f5-448d-a18c-baeae517d000")
+
+		require.EqualError(t, err, "schedule not found")
+	})
+
+	t.Run("TestGetSchedulesByUserID_Success", func(t *testing.T) {
+		schedules, err := repo.GetSchedulesByUserID(ctx, userID)
+
+		require.NoError(t, err)
+		require.Len(t, schedules, 1)
+		require.Equal(t, "rent", schedules[0].Reference)
+	})
+
+	t.Run("TestProcessDue_RecordsRun", func(t *testing.T) {
+		n, err := repo.ProcessDue(ctx, time.Now(), 10, func(_ context.Context, sc *Schedule) Run {
+			run := Run{Occurrence: sc.Occurrence, Attempt: 1, Status: RunStatusSucceeded, Reference: sc.RunReference(1, ""), RanAt: time.Now()}
+			sc.Advance(time.Now())
+			return run
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+
+		runs, err := repo.GetRunsByScheduleID(ctx, created.ID)
+		require.NoError(t, err)
+		require.Len(t, runs, 1)
+		require.Equal(t, "rent-1-1", runs[0].Reference)
+
+		sc, err := repo.GetScheduleByID(ctx, created.ID)
+		require.NoError(t, err)
+		require.Equal(t, 1, sc.Occurrence)
+		require.True(t, sc.NextRunAt.After(time.Now()))
+	})
+
+	t.Run("TestUpdateSchedule_Success", func(t *testing.T) {
+		paused := StatusPaused
+
+		// created still has the next run time from before the run
+		updated, err := repo.Update(ctx, created.ID, Changes{Status: &paused})
+
+		require.NoError(t, err)
+		require.Equal(t, StatusPaused, updated.Status)
+		require.Equal(t, 1, updated.Occurrence)
+		require.True(t, updated.NextRunAt.After(time.Now()))
+	})
+
+	// runWhileChanging runs the due schedules while change is made, as a
+	// PATCH arriving during the provider call would be.
+	runWhileChanging := func(t *testing.T, change func()) {
+		n, err := repo.ProcessDue(ctx, time.Now(), 10, func(_ context.Context, due *Schedule) Run {
+			change()
+
+			run := Run{Occurrence: due.Occurrence + 1, Attempt: 1, Status: RunStatusSucceeded, Reference: due.RunReference(1, ""), RanAt: time.Now()}
+			due.Advance(time.Now())
+			return run
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+	}
+
+	t.Run("TestProcessDue_KeepsConcurrentUpdate", func(t *testing.T) {
+		sc, err := repo.Create(ctx, NewSchedule(userID, "gym", "debit", FrequencyMonthly, 5000, time.Now().Add(-time.Minute)))
+		require.NoError(t, err)
+
+		amount := int64(7000)
+		runWhileChanging(t, func() {
+			_, err := repo.Update(ctx, sc.ID, Changes{Amount: &amount})
+			require.NoError(t, err)
+
+			// The schedule is claimed, so no other worker runs it
+			n, err := repo.ProcessDue(ctx, time.Now(), 10, func(context.Context, *Schedule) Run { panic("claimed schedule run twice") })
+			require.NoError(t, err)
+			require.Zero(t, n)
+		})
+
+		// Neither the update nor the run undoes the other
+		sc, err = repo.GetScheduleByID(ctx, sc.ID)
+		require.NoError(t, err)
+		require.Equal(t, int64(7000), sc.Amount)
+		require.Equal(t, 1, sc.Occurrence)
+		require.True(t, sc.NextRunAt.After(time.Now()))
+	})
+
+	t.Run("TestProcessDue_DoesNotReviveCancelledSchedule", func(t *testing.T) {
+		sc, err := repo.Create(ctx, NewSchedule(userID, "cable", "debit", FrequencyMonthly, 5000, time.Now().Add(-time.Minute)))
+		require.NoError(t, err)
+
+		runWhileChanging(t, func() {
+			_, err := repo.Cancel(ctx, sc.ID)
+			require.NoError(t, err)
+		})
+
+		sc, err = repo.GetScheduleByID(ctx, sc.ID)
+		require.NoError(t, err)
+		require.Equal(t, StatusCancelled, sc.Status)
+		require.Nil(t, sc.NextRunAt)
+		require.Equal(t, 1, sc.Occurrence)
+	})
+
+	t.Run("TestUpdateSchedule_Completed", func(t *testing.T) {
+		sc, err := repo.Create(ctx, NewSchedule(userID, "once", "debit", FrequencyOnce, 5000, time.Now().Add(-time.Minute)))
+		require.NoError(t, err)
+		runWhileChanging(t, func() {})
+
+		active := StatusActive
+		_, err = repo.Update(ctx, sc.ID, Changes{Status: &active})
+
+		require.EqualError(t, err, "schedule is completed")
+	})
+
+	t.Run("TestUpdateSchedule_EndBeforeNextRun", func(t *testing.T) {
+		sc, err := repo.Create(ctx, NewSchedule(userID, "paper", "debit", FrequencyMonthly, 5000, time.Now().Add(-time.Minute)))
+		require.NoError(t, err)
+		runWhileChanging(t, func() {})
+
+		// The next run is a month away, past the new end
+		end := time.Now()
+		updated, err := repo.Update(ctx, sc.ID, Changes{EndAt: &end})
+
+		require.NoError(t, err)
+		require.Equal(t, StatusCompleted, updated.Status)
+		require.Nil(t, updated.NextRunAt)
+	})
+
+	t.Run("TestProcessDue_SkipsScheduleEnded", func(t *testing.T) {
+		sc, err := repo.Create(ctx, NewSchedule(userID, "milk", "debit", FrequencyDaily, 5000, time.Now().Add(-time.Hour)))
+		require.NoError(t, err)
+		_, err = db.Exec("UPDATE schedules SET end_at = start_at, next_run_at = start_at + interval '1 minute' WHERE id = $1", sc.ID)
+		require.NoError(t, err)
+
+		n, err := repo.ProcessDue(ctx, time.Now(), 10, func(context.Context, *Schedule) Run { panic("ended schedule run") })
+
+		require.NoError(t, err)
+		require.Zero(t, n)
+	})
+
+	t.Run("TestCancelSchedule_Finished", func(t *testing.T) {
+		sc, err := repo.Cancel(ctx, created.ID)
+		require.NoError(t, err)
+		require.Equal(t, StatusCancelled, sc.Status)
+		require.Nil(t, sc.NextRunAt)
+
+		_, err = repo.Cancel(ctx, created.ID)
+		require.EqualError(t, err, "schedule is cancelled")
+	})
+}
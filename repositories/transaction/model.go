@@ -12,6 +12,12 @@ type Transaction struct {
 	Reference string    `json:"reference" db:"reference"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	// CompletedAt is when the transaction became completed, which is when it
+	// counts towards the balance of the wallet.
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	// Reason is why an operator adjusted a wallet by hand. Only adjustments
+	// have one.
+	Reason *string `json:"reason,omitempty" db:"reason"`
 }
 
 // NewTransaction creates a new transaction.
@@ -27,3 +33,11 @@ func NewTransaction(userID, requestID, reference, transactionType string, amount
 		Type:      transactionType,
 	}
 }
+
+// StatusChange records a status a transaction moved into and when.
+type StatusChange struct {
+	ID            string    `json:"-" db:"id"`
+	TransactionID string    `json:"-" db:"transaction_id"`
+	Status        string    `json:"status" db:"status"`
+	ChangedAt     time.Time `json:"changed_at" db:"changed_at"`
+}
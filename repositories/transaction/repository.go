@@ -1,11 +1,14 @@
 package transaction
 
 import (
+	"context"
 	"database/sql"
-	"errors"
+	DB "p-system/db"
+	"p-system/tracing"
+	"p-system/utils"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
-	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 )
 
@@ -13,24 +16,63 @@ import (
 //go:generate mockgen --source=repository.go -destination=respository_mock.go -package=transaction Repository
 type Repository interface {
 	// Create creates a new transaction.
-	Create(*Transaction) (*Transaction, error)
+	Create(context.Context, *Transaction) (*Transaction, error)
 	// GetTransactionByReference returns the transaction with the given reference.
-	GetTransactionByReference(string) (*Transaction, error)
+	GetTransactionByReference(context.Context, string) (*Transaction, error)
+	// GetTransactionByID returns the transaction with the given id.
+	GetTransactionByID(ctx context.Context, id string) (*Transaction, error)
+	// GetStatusHistory returns every status the given transaction has been in,
+	// oldest first.
+	GetStatusHistory(ctx context.Context, id string) ([]StatusChange, error)
+	// CountByStatus returns how many transactions have the given status.
+	CountByStatus(ctx context.Context, status string) (int64, error)
+	// GetPendingBefore returns the transactions still pending that were
+	// created before the given time, oldest first.
+	GetPendingBefore(ctx context.Context, before time.Time) ([]Transaction, error)
 
-	//UpdateTransactionToFailed updates a transaction
-	UpdateTransactionToFailed(id string) (*Transaction, error)
+	// UpdateTransactionToFailed marks a pending transaction failed. It fails
+	// with a conflict if the transaction is no longer pending.
+	UpdateTransactionToFailed(ctx context.Context, id string) (*Transaction, error)
+	// UpdateTransactionToCompleted marks a pending transaction completed,
+	// failing with a conflict if it is no longer pending. It belongs in the
+	// same unit of work as the wallet update it pays for, so the update is
+	// rolled back when the transaction was settled by someone else.
+	UpdateTransactionToCompleted(ctx context.Context, id string) (*Transaction, error)
+
+	// GetCompletedTransactions returns the completed transactions of a user
+	// completed in [from, to), oldest first.
+	GetCompletedTransactions(ctx context.Context, userID string, from, to time.Time) ([]Transaction, error)
+	// GetNetAmountSince returns completed credits minus completed debits of a
+	// user completed at or after since.
+	GetNetAmountSince(ctx context.Context, userID string, since time.Time) (int64, error)
+	// GetTransactionsByReferences returns the transactions with any of the
+	// given references.
+	GetTransactionsByReferences(ctx context.Context, references []string) ([]Transaction, error)
+	// GetCompletedTransactionsBetween returns the completed transactions of
+	// every user created in [from, to).
+	GetCompletedTransactionsBetween(ctx context.Context, from, to time.Time) ([]Transaction, error)
 }
 
 // service implements the Repository interface.
 type service struct {
-	db   *sqlx.DB
+	db   DB.Queryer
 	psql sq.StatementBuilderType
 }
 
 
 
-// NewRepository creates a new transaction repository.
-func NewRepository(db *sqlx.DB) Repository {
+// createViolations are the errors creating a transaction reports for the
+// constraints it can violate.
+var createViolations = DB.Violations{
+	"transactions_reference_key":   utils.DuplicateReference("transaction already exists"),
+	"transactions_user_id_fkey":    utils.NotFound("user not found"),
+	"transactions_amount_positive": utils.InvalidRequest("amount must be positive"),
+	"invalid_text_representation":  utils.InvalidRequest("invalid transaction type or status"),
+}
+
+// NewRepository creates a new transaction repository running on db, which is
+// either the database or the transaction of a unit of work.
+func NewRepository(db DB.Queryer) Repository {
 	return &service{
 		db:   db,
 		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
@@ -38,10 +80,13 @@ func NewRepository(db *sqlx.DB) Repository {
 }
 
 // Create creates a new transaction.
-func (s service) Create(transaction *Transaction) (*Transaction, error) {
+func (s service) Create(ctx context.Context, transaction *Transaction) (*Transaction, error) {
+	ctx, span := tracing.StartDB(ctx, "transaction.Create")
+	defer span.End()
+
 	query, args, err := s.psql.Insert("transactions").
-		Columns("user_id", "request_id", "type", "amount", "status", "reference", "created_at", "updated_at").
-		Values(transaction.UserID, transaction.RequestID, transaction.Type, transaction.Amount, transaction.Status, transaction.Reference, transaction.CreatedAt, transaction.UpdatedAt).
+		Columns("user_id", "request_id", "type", "amount", "status", "reference", "created_at", "updated_at", "reason").
+		Values(transaction.UserID, transaction.RequestID, transaction.Type, transaction.Amount, transaction.Status, transaction.Reference, transaction.CreatedAt, transaction.UpdatedAt, transaction.Reason).
 		Suffix("RETURNING *").
 		ToSql()
 	if err != nil {
@@ -49,22 +94,19 @@ func (s service) Create(transaction *Transaction) (*Transaction, error) {
 	}
 
 	var t Transaction
-	if err := s.db.Get(&t, query, args...); err != nil {
+	if err := s.db.GetContext(ctx, &t, query, args...); err != nil {
 		//check if error is due to constraint violation
-		if err, ok := err.(*pq.Error); ok {
-			if err.Code.Name() == "unique_violation" {
-				return nil, errors.New("transaction already exists")
-			}
-		}
-
-		return nil, err
+		return nil, createViolations.Map(err)
 	}
 
 	return &t, nil
 }
 
 // GetTransactionByReference returns the transaction with the given reference.
-func (s service) GetTransactionByReference(reference string) (*Transaction, error) {
+func (s service) GetTransactionByReference(ctx context.Context, reference string) (*Transaction, error) {
+	ctx, span := tracing.StartDB(ctx, "transaction.GetTransactionByReference")
+	defer span.End()
+
 	query, args, err := s.psql.Select("*").
 		From("transactions").
 		Where(sq.Eq{"reference": reference}).
@@ -74,9 +116,9 @@ func (s service) GetTransactionByReference(reference string) (*Transaction, erro
 	}
 
 	var t Transaction
-	if err := s.db.Get(&t, query, args...); err != nil {
+	if err := s.db.GetContext(ctx, &t, query, args...); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("transaction not found")
+			return nil, utils.NotFound("transaction not found")
 		}
 		return nil, err
 	}
@@ -84,10 +126,122 @@ func (s service) GetTransactionByReference(reference string) (*Transaction, erro
 	return &t, nil
 }
 
-func (s service) UpdateTransactionToFailed(id string) (*Transaction, error) {
-	query, args, err := s.psql.Update("transactions").
-		Set("status", "failed").
+// GetTransactionByID returns the transaction with the given id.
+func (s service) GetTransactionByID(ctx context.Context, id string) (*Transaction, error) {
+	ctx, span := tracing.StartDB(ctx, "transaction.GetTransactionByID")
+	defer span.End()
+
+	query, args, err := s.psql.Select("*").
+		From("transactions").
 		Where(sq.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var t Transaction
+	if err := s.db.GetContext(ctx, &t, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("transaction not found")
+		}
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// GetStatusHistory returns every status the given transaction has been in,
+// oldest first.
+func (s service) GetStatusHistory(ctx context.Context, id string) ([]StatusChange, error) {
+	ctx, span := tracing.StartDB(ctx, "transaction.GetStatusHistory")
+	defer span.End()
+
+	query, args, err := s.psql.Select("*").
+		From("transaction_status_history").
+		Where(sq.Eq{"transaction_id": id}).
+		OrderBy("changed_at", "id").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	history := []StatusChange{}
+	if err := s.db.SelectContext(ctx, &history, query, args...); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// CountByStatus returns how many transactions have the given status.
+func (s service) CountByStatus(ctx context.Context, status string) (int64, error) {
+	ctx, span := tracing.StartDB(ctx, "transaction.CountByStatus")
+	defer span.End()
+
+	query, args, err := s.psql.Select("COUNT(*)").
+		From("transactions").
+		Where(sq.Eq{"status": status}).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	if err := s.db.GetContext(ctx, &count, query, args...); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetPendingBefore returns the transactions still pending that were created
+// before the given time, oldest first.
+func (s service) GetPendingBefore(ctx context.Context, before time.Time) ([]Transaction, error) {
+	ctx, span := tracing.StartDB(ctx, "transaction.GetPendingBefore")
+	defer span.End()
+
+	query, args, err := s.psql.Select("*").
+		From("transactions").
+		Where(sq.Eq{"status": "pending"}).
+		Where(sq.Lt{"created_at": before}).
+		OrderBy("created_at", "id").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := []Transaction{}
+	if err := s.db.SelectContext(ctx, &transactions, query, args...); err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+// UpdateTransactionToFailed marks a pending transaction failed.
+func (s service) UpdateTransactionToFailed(ctx context.Context, id string) (*Transaction, error) {
+	ctx, span := tracing.StartDB(ctx, "transaction.UpdateTransactionToFailed")
+	defer span.End()
+
+	return s.settle(ctx, id, "failed")
+}
+
+// UpdateTransactionToCompleted marks a pending transaction completed.
+func (s service) UpdateTransactionToCompleted(ctx context.Context, id string) (*Transaction, error) {
+	ctx, span := tracing.StartDB(ctx, "transaction.UpdateTransactionToCompleted")
+	defer span.End()
+
+	return s.settle(ctx, id, "completed")
+}
+
+// settle moves a transaction out of pending into status. Only a pending
+// transaction is updated, so of two callers settling it at once only one
+// succeeds; the other gets a conflict, which rolls back its unit of work.
+func (s service) settle(ctx context.Context, id, status string) (*Transaction, error) {
+	query, args, err := s.psql.Update("transactions").
+		Set("status", status).
+		Set("updated_at", time.Now()).
+		Where(sq.Eq{"id": id, "status": "pending"}).
 		Suffix("RETURNING *").
 		ToSql()
 	if err != nil {
@@ -95,9 +249,108 @@ func (s service) UpdateTransactionToFailed(id string) (*Transaction, error) {
 	}
 
 	var t Transaction
-	if err := s.db.Get(&t, query, args...); err != nil {
+	err = s.db.GetContext(ctx, &t, query, args...)
+	if err == sql.ErrNoRows {
+		current, err := s.GetTransactionByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return nil, utils.Conflict("transaction is " + current.Status)
+	}
+	if err != nil {
 		return nil, err
 	}
 
 	return &t, nil
 }
+
+// GetCompletedTransactions returns the completed transactions of a user
+// completed in [from, to), oldest first.
+func (s service) GetCompletedTransactions(ctx context.Context, userID string, from, to time.Time) ([]Transaction, error) {
+	ctx, span := tracing.StartDB(ctx, "transaction.GetCompletedTransactions")
+	defer span.End()
+
+	query, args, err := s.psql.Select("*").
+		From("transactions").
+		Where(sq.Eq{"user_id": userID, "status": "completed"}).
+		Where(sq.GtOrEq{"completed_at": from}).
+		Where(sq.Lt{"completed_at": to}).
+		OrderBy("completed_at", "id").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := []Transaction{}
+	if err := s.db.SelectContext(ctx, &transactions, query, args...); err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+// GetNetAmountSince returns completed credits minus completed debits of a
+// user completed at or after since.
+func (s service) GetNetAmountSince(ctx context.Context, userID string, since time.Time) (int64, error) {
+	ctx, span := tracing.StartDB(ctx, "transaction.GetNetAmountSince")
+	defer span.End()
+
+	query, args, err := s.psql.Select("COALESCE(SUM(CASE WHEN type = 'debit' THEN -amount ELSE amount END), 0)").
+		From("transactions").
+		Where(sq.Eq{"user_id": userID, "status": "completed"}).
+		Where(sq.GtOrEq{"completed_at": since}).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var net int64
+	if err := s.db.GetContext(ctx, &net, query, args...); err != nil {
+		return 0, err
+	}
+
+	return net, nil
+}
+
+// GetTransactionsByReferences returns the transactions with any of the given
+// references.
+func (s service) GetTransactionsByReferences(ctx context.Context, references []string) ([]Transaction, error) {
+	ctx, span := tracing.StartDB(ctx, "transaction.GetTransactionsByReferences")
+	defer span.End()
+
+	transactions := []Transaction{}
+	if len(references) == 0 {
+		return transactions, nil
+	}
+
+	if err := s.db.SelectContext(ctx, &transactions, "SELECT * FROM transactions WHERE reference = ANY($1)", pq.Array(references)); err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+// GetCompletedTransactionsBetween returns the completed transactions of every
+// user created in [from, to).
+func (s service) GetCompletedTransactionsBetween(ctx context.Context, from, to time.Time) ([]Transaction, error) {
+	ctx, span := tracing.StartDB(ctx, "transaction.GetCompletedTransactionsBetween")
+	defer span.End()
+
+	query, args, err := s.psql.Select("*").
+		From("transactions").
+		Where(sq.Eq{"status": "completed"}).
+		Where(sq.GtOrEq{"created_at": from}).
+		Where(sq.Lt{"created_at": to}).
+		OrderBy("created_at", "id").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := []Transaction{}
+	if err := s.db.SelectContext(ctx, &transactions, query, args...); err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
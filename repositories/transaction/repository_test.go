@@ -1,8 +1,10 @@
 package transaction
 
 import (
+	"context"
 	"p-system/tests"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -11,6 +13,7 @@ func TestTransactionRepository(t *testing.T) {
 	db := tests.StartDB(t)
 
 	repo := NewRepository(db)
+	ctx := context.Background()
 
 	//run seeds
 	err := tests.Seed(db)
@@ -22,7 +25,7 @@ func TestTransactionRepository(t *testing.T) {
 
 		newTransaction := NewTransaction("d164e69d-26f5-448d-a18c-baeae517d9f2", "d164e69d-26f5-448d-a18c-baeae517d9f2", "newref", "credit", 1000)
 
-		createdTransaction, err := repo.Create(newTransaction)
+		createdTransaction, err := repo.Create(ctx, newTransaction)
 
 		require.NoError(t, err)
 
@@ -33,7 +36,7 @@ func TestTransactionRepository(t *testing.T) {
 		// Assuming you've seeded the database with a transaction having the reference "newref"
 		duplicateTransaction := NewTransaction("d164e69d-26f5-448d-a18c-baeae517d9f2", "d164e69d-26f5-448d-a18c-baeae517d9f2", "newref", "credit", 1000)
 
-		_, err := repo.Create(duplicateTransaction)
+		_, err := repo.Create(ctx, duplicateTransaction)
 
 		require.Error(t, err)
 		require.EqualError(t, err, "transaction already exists")
@@ -41,7 +44,7 @@ func TestTransactionRepository(t *testing.T) {
 
 	t.Run("TestGetTransactionByReference_NotFound", func(t *testing.T) {
 		// Assuming "nonexistentref" does not exist in the seeded data
-		_, err := repo.GetTransactionByReference("nonexistentref")
+		_, err := repo.GetTransactionByReference(ctx, "nonexistentref")
 
 		require.Error(t, err)
 		require.EqualError(t, err, "transaction not found")
@@ -49,7 +52,7 @@ func TestTransactionRepository(t *testing.T) {
 
 	t.Run("TestGetTransactionByReference_Success", func(t *testing.T) {
 		// Assuming "newref" exists in the seeded data
-		transaction, err := repo.GetTransactionByReference("newref")
+		transaction, err := repo.GetTransactionByReference(ctx, "newref")
 
 		require.NoError(t, err)
 		require.Equal(t, "newref", transaction.Reference)
@@ -57,14 +60,81 @@ func TestTransactionRepository(t *testing.T) {
 
 	t.Run("TestUpdateTransactionToFailed_Success", func(t *testing.T) {
 		// Assuming "newref" exists in the seeded data
-		transaction, err := repo.GetTransactionByReference("newref")
+		transaction, err := repo.GetTransactionByReference(ctx, "newref")
 
 		require.NoError(t, err)
 		require.Equal(t, "newref", transaction.Reference)
 
-		updatedTransaction, err := repo.UpdateTransactionToFailed(transaction.ID)
+		updatedTransaction, err := repo.UpdateTransactionToFailed(ctx, transaction.ID)
 
 		require.NoError(t, err)
 		require.Equal(t, "failed", updatedTransaction.Status)
 	})
+
+	t.Run("TestGetCompletedTransactions_Success", func(t *testing.T) {
+		// Only the seeded "unique_reference" transaction is completed
+		transactions, err := repo.GetCompletedTransactions(ctx, "d164e69d-26f5-448d-a18c-baeae517d9f2", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+
+		require.NoError(t, err)
+		require.Len(t, transactions, 1)
+		require.Equal(t, "unique_reference", transactions[0].Reference)
+	})
+
+	t.Run("TestGetNetAmountSince_Success", func(t *testing.T) {
+		net, err := repo.GetNetAmountSince(ctx, "d164e69d-26f5-448d-a18c-baeae517d9f2", time.Now().Add(-time.Hour))
+
+		require.NoError(t, err)
+		require.Equal(t, int64(1000), net)
+	})
+
+	t.Run("TestGetTransactionsByReferences_Success", func(t *testing.T) {
+		transactions, err := repo.GetTransactionsByReferences(ctx, []string{"unique_reference", "newref", "nonexistentref"})
+
+		require.NoError(t, err)
+		require.Len(t, transactions, 2)
+	})
+
+	t.Run("TestGetCompletedTransactionsBetween_Success", func(t *testing.T) {
+		transactions, err := repo.GetCompletedTransactionsBetween(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+
+		require.NoError(t, err)
+		require.Len(t, transactions, 1)
+		require.Equal(t, "unique_reference", transactions[0].Reference)
+	})
+
+	t.Run("TestGetTransactionByID_Success", func(t *testing.T) {
+		transaction, err := repo.GetTransactionByReference(ctx, "newref")
+		require.NoError(t, err)
+
+		found, err := repo.GetTransactionByID(ctx, transaction.ID)
+
+		require.NoError(t, err)
+		require.Equal(t, "newref", found.Reference)
+	})
+
+	t.Run("TestGetTransactionByID_NotFound", func(t *testing.T) {
+		_, err := repo.GetTransactionByID(ctx, "00000000-0000-0000-0000-000000000000")
+
+		require.EqualError(t, err, "transaction not found")
+	})
+
+	t.Run("TestGetStatusHistory_Success", func(t *testing.T) {
+		// "newref" was created pending and then failed
+		transaction, err := repo.GetTransactionByReference(ctx, "newref")
+		require.NoError(t, err)
+
+		history, err := repo.GetStatusHistory(ctx, transaction.ID)
+
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		require.Equal(t, "pending", history[0].Status)
+		require.Equal(t, "failed", history[1].Status)
+	})
+
+	t.Run("TestCountByStatus_Success", func(t *testing.T) {
+		count, err := repo.CountByStatus(ctx, "completed")
+
+		require.NoError(t, err)
+		require.Equal(t, int64(1), count)
+	})
 }
@@ -5,7 +5,9 @@
 package transaction
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -33,47 +35,182 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// CountByStatus mocks base method.
+func (m *MockRepository) CountByStatus(ctx context.Context, status string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountByStatus", ctx, status)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountByStatus indicates an expected call of CountByStatus.
+func (mr *MockRepositoryMockRecorder) CountByStatus(ctx, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountByStatus", reflect.TypeOf((*MockRepository)(nil).CountByStatus), ctx, status)
+}
+
 // Create mocks base method.
-func (m *MockRepository) Create(arg0 *Transaction) (*Transaction, error) {
+func (m *MockRepository) Create(arg0 context.Context, arg1 *Transaction) (*Transaction, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", arg0)
+	ret := m.ctrl.Call(m, "Create", arg0, arg1)
 	ret0, _ := ret[0].(*Transaction)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockRepositoryMockRecorder) Create(arg0 interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) Create(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRepository)(nil).Create), arg0, arg1)
+}
+
+// GetCompletedTransactions mocks base method.
+func (m *MockRepository) GetCompletedTransactions(ctx context.Context, userID string, from, to time.Time) ([]Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCompletedTransactions", ctx, userID, from, to)
+	ret0, _ := ret[0].([]Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCompletedTransactions indicates an expected call of GetCompletedTransactions.
+func (mr *MockRepositoryMockRecorder) GetCompletedTransactions(ctx, userID, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCompletedTransactions", reflect.TypeOf((*MockRepository)(nil).GetCompletedTransactions), ctx, userID, from, to)
+}
+
+// GetCompletedTransactionsBetween mocks base method.
+func (m *MockRepository) GetCompletedTransactionsBetween(ctx context.Context, from, to time.Time) ([]Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCompletedTransactionsBetween", ctx, from, to)
+	ret0, _ := ret[0].([]Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCompletedTransactionsBetween indicates an expected call of GetCompletedTransactionsBetween.
+func (mr *MockRepositoryMockRecorder) GetCompletedTransactionsBetween(ctx, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCompletedTransactionsBetween", reflect.TypeOf((*MockRepository)(nil).GetCompletedTransactionsBetween), ctx, from, to)
+}
+
+// GetNetAmountSince mocks base method.
+func (m *MockRepository) GetNetAmountSince(ctx context.Context, userID string, since time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNetAmountSince", ctx, userID, since)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNetAmountSince indicates an expected call of GetNetAmountSince.
+func (mr *MockRepositoryMockRecorder) GetNetAmountSince(ctx, userID, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNetAmountSince", reflect.TypeOf((*MockRepository)(nil).GetNetAmountSince), ctx, userID, since)
+}
+
+// GetPendingBefore mocks base method.
+func (m *MockRepository) GetPendingBefore(ctx context.Context, before time.Time) ([]Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPendingBefore", ctx, before)
+	ret0, _ := ret[0].([]Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPendingBefore indicates an expected call of GetPendingBefore.
+func (mr *MockRepositoryMockRecorder) GetPendingBefore(ctx, before interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRepository)(nil).Create), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingBefore", reflect.TypeOf((*MockRepository)(nil).GetPendingBefore), ctx, before)
+}
+
+// GetStatusHistory mocks base method.
+func (m *MockRepository) GetStatusHistory(ctx context.Context, id string) ([]StatusChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatusHistory", ctx, id)
+	ret0, _ := ret[0].([]StatusChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatusHistory indicates an expected call of GetStatusHistory.
+func (mr *MockRepositoryMockRecorder) GetStatusHistory(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatusHistory", reflect.TypeOf((*MockRepository)(nil).GetStatusHistory), ctx, id)
+}
+
+// GetTransactionByID mocks base method.
+func (m *MockRepository) GetTransactionByID(ctx context.Context, id string) (*Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransactionByID", ctx, id)
+	ret0, _ := ret[0].(*Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransactionByID indicates an expected call of GetTransactionByID.
+func (mr *MockRepositoryMockRecorder) GetTransactionByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionByID", reflect.TypeOf((*MockRepository)(nil).GetTransactionByID), ctx, id)
 }
 
 // GetTransactionByReference mocks base method.
-func (m *MockRepository) GetTransactionByReference(arg0 string) (*Transaction, error) {
+func (m *MockRepository) GetTransactionByReference(arg0 context.Context, arg1 string) (*Transaction, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTransactionByReference", arg0)
+	ret := m.ctrl.Call(m, "GetTransactionByReference", arg0, arg1)
 	ret0, _ := ret[0].(*Transaction)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetTransactionByReference indicates an expected call of GetTransactionByReference.
-func (mr *MockRepositoryMockRecorder) GetTransactionByReference(arg0 interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetTransactionByReference(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionByReference", reflect.TypeOf((*MockRepository)(nil).GetTransactionByReference), arg0, arg1)
+}
+
+// GetTransactionsByReferences mocks base method.
+func (m *MockRepository) GetTransactionsByReferences(ctx context.Context, references []string) ([]Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransactionsByReferences", ctx, references)
+	ret0, _ := ret[0].([]Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransactionsByReferences indicates an expected call of GetTransactionsByReferences.
+func (mr *MockRepositoryMockRecorder) GetTransactionsByReferences(ctx, references interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionsByReferences", reflect.TypeOf((*MockRepository)(nil).GetTransactionsByReferences), ctx, references)
+}
+
+// UpdateTransactionToCompleted mocks base method.
+func (m *MockRepository) UpdateTransactionToCompleted(ctx context.Context, id string) (*Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTransactionToCompleted", ctx, id)
+	ret0, _ := ret[0].(*Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateTransactionToCompleted indicates an expected call of UpdateTransactionToCompleted.
+func (mr *MockRepositoryMockRecorder) UpdateTransactionToCompleted(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionByReference", reflect.TypeOf((*MockRepository)(nil).GetTransactionByReference), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTransactionToCompleted", reflect.TypeOf((*MockRepository)(nil).UpdateTransactionToCompleted), ctx, id)
 }
 
 // UpdateTransactionToFailed mocks base method.
-func (m *MockRepository) UpdateTransactionToFailed(id string) (*Transaction, error) {
+func (m *MockRepository) UpdateTransactionToFailed(ctx context.Context, id string) (*Transaction, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateTransactionToFailed", id)
+	ret := m.ctrl.Call(m, "UpdateTransactionToFailed", ctx, id)
 	ret0, _ := ret[0].(*Transaction)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // UpdateTransactionToFailed indicates an expected call of UpdateTransactionToFailed.
-func (mr *MockRepositoryMockRecorder) UpdateTransactionToFailed(id interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) UpdateTransactionToFailed(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTransactionToFailed", reflect.TypeOf((*MockRepository)(nil).UpdateTransactionToFailed), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTransactionToFailed", reflect.TypeOf((*MockRepository)(nil).UpdateTransactionToFailed), ctx, id)
 }
@@ -0,0 +1,74 @@
+// Package unitofwork runs calls to several repositories in one database
+// transaction.
+package unitofwork
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	DB "p-system/db"
+	"p-system/repositories/transaction"
+	"p-system/repositories/user"
+	"p-system/repositories/wallet"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Repositories are the repositories bound to one unit of work.
+type Repositories struct {
+	Users        user.Repository
+	Wallets      wallet.Repository
+	Transactions transaction.Repository
+}
+
+type UnitOfWork interface {
+	// Do runs fn in one transaction, with repositories bound to it. The
+	// transaction is committed if fn returns nil and rolled back if it returns
+	// an error or panics. fn is run again from the start when the transaction
+	// fails to serialize, so it must not have effects outside the database.
+	Do(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error
+
+	// Read runs fn in one read-only repeatable read transaction, so every
+	// read in it sees the same state of the database.
+	Read(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error
+}
+
+// service implements the UnitOfWork interface.
+type service struct {
+	db     *sqlx.DB
+	policy DB.TxPolicy
+	logger *slog.Logger
+}
+
+// New creates a unit of work running on db, with the isolation level and
+// retries policies give the unit_of_work operation.
+func New(db *sqlx.DB, policies DB.Policies, logger *slog.Logger) UnitOfWork {
+	return &service{
+		db:     db,
+		policy: policies.For("unit_of_work"),
+		logger: logger,
+	}
+}
+
+// Do runs fn in one transaction.
+func (s service) Do(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error {
+	return DB.RetryTx(ctx, s.db, "unit_of_work", s.policy, s.logger, func(tx DB.Queryer) error {
+		return fn(ctx, s.bind(tx))
+	})
+}
+
+// Read runs fn in one read-only snapshot of the database.
+func (s service) Read(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error {
+	return DB.InTx(ctx, s.db, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}, func(tx DB.Queryer) error {
+		return fn(ctx, s.bind(tx))
+	})
+}
+
+// bind returns the repositories running on q.
+func (s service) bind(q DB.Queryer) Repositories {
+	return Repositories{
+		Users:        user.NewRepository(q),
+		Wallets:      wallet.NewRepository(q, nil, s.logger),
+		Transactions: transaction.NewRepository(q),
+	}
+}
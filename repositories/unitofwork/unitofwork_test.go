@@ -0,0 +1,134 @@
+package unitofwork
+
+import (
+	"context"
+	"errors"
+	"p-system/logging"
+	"p-system/repositories/transaction"
+	"p-system/repositories/wallet"
+	"p-system/tests"
+	"p-system/utils"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnitOfWork(t *testing.T) {
+	db := tests.StartDB(t)
+
+	uow := New(db, nil, logging.Discard())
+	ctx := context.Background()
+
+	// Run seeds
+	err := tests.Seed(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		userID   = "d164e69d-26f5-448d-a18c-baeae517d9f2"
+		walletID = "d164e69d-26f5-448d-a18c-baeae517d991"
+	)
+
+	// Only a pending transaction can be marked failed
+	pending, err := transaction.NewRepository(db).Create(ctx, transaction.NewTransaction(userID, "uow_request_id", "uow_reference", "credit", 700))
+	require.NoError(t, err)
+	transactionID := pending.ID
+
+	statusOf := func(t *testing.T) string {
+		tr, err := transaction.NewRepository(db).GetTransactionByID(ctx, transactionID)
+		require.NoError(t, err)
+		return tr.Status
+	}
+
+	t.Run("TestDo_RollsBackOnError", func(t *testing.T) {
+		errBoom := errors.New("boom")
+
+		err := uow.Do(ctx, func(ctx context.Context, repos Repositories) error {
+			if _, err := repos.Transactions.UpdateTransactionToFailed(ctx, transactionID); err != nil {
+				return err
+			}
+			return errBoom
+		})
+
+		require.ErrorIs(t, err, errBoom)
+		require.Equal(t, "pending", statusOf(t))
+	})
+
+	t.Run("TestDo_RollsBackOnPanic", func(t *testing.T) {
+		require.Panics(t, func() {
+			_ = uow.Do(ctx, func(ctx context.Context, repos Repositories) error {
+				if _, err := repos.Transactions.UpdateTransactionToFailed(ctx, transactionID); err != nil {
+					return err
+				}
+				panic("boom")
+			})
+		})
+
+		require.Equal(t, "pending", statusOf(t))
+	})
+
+	t.Run("TestDo_CommitsEveryRepository", func(t *testing.T) {
+		w := &wallet.Wallet{ID: walletID}
+		tr := transaction.Transaction{ID: transactionID}
+
+		err := uow.Do(ctx, func(ctx context.Context, repos Repositories) error {
+			if _, err := repos.Transactions.UpdateTransactionToFailed(ctx, transactionID); err != nil {
+				return err
+			}
+			_, err := repos.Wallets.CreditWallet(ctx, w, tr, 700)
+			return err
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, "failed", statusOf(t))
+		updated, err := wallet.NewRepository(db, nil, logging.Discard()).GetWalletByID(ctx, walletID)
+		require.NoError(t, err)
+		require.Equal(t, int64(700), updated.Balance)
+	})
+
+	t.Run("TestDo_RollsBackWhenAlreadySettled", func(t *testing.T) {
+		w := &wallet.Wallet{ID: walletID}
+		tr := transaction.Transaction{ID: transactionID}
+
+		// A second processor of the same transaction does not credit it again
+		err := uow.Do(ctx, func(ctx context.Context, repos Repositories) error {
+			if _, err := repos.Transactions.UpdateTransactionToCompleted(ctx, transactionID); err != nil {
+				return err
+			}
+			_, err := repos.Wallets.CreditWallet(ctx, w, tr, 700)
+			return err
+		})
+		require.ErrorIs(t, err, utils.ErrConflict)
+
+		require.Equal(t, "failed", statusOf(t))
+		updated, err := wallet.NewRepository(db, nil, logging.Discard()).GetWalletByID(ctx, walletID)
+		require.NoError(t, err)
+		require.Equal(t, int64(700), updated.Balance)
+	})
+
+	t.Run("TestRead_SeesOneSnapshot", func(t *testing.T) {
+		err := uow.Read(ctx, func(ctx context.Context, repos Repositories) error {
+			before, err := repos.Wallets.GetWalletByID(ctx, walletID)
+			require.NoError(t, err)
+
+			// A change committed after the first read is not seen by the second
+			_, err = db.ExecContext(ctx, "UPDATE wallets SET balance = balance + 100 WHERE id = $1", walletID)
+			require.NoError(t, err)
+
+			after, err := repos.Wallets.GetWalletByID(ctx, walletID)
+			require.NoError(t, err)
+			require.Equal(t, before.Balance, after.Balance)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("TestRead_RejectsWrites", func(t *testing.T) {
+		err := uow.Read(ctx, func(ctx context.Context, repos Repositories) error {
+			_, err := repos.Transactions.UpdateTransactionToFailed(ctx, transactionID)
+			return err
+		})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,28 @@
+// Package unitofworktest provides a unit of work for tests of services,
+// running against mocked or in-memory repositories.
+package unitofworktest
+
+import (
+	"context"
+	"p-system/repositories/unitofwork"
+)
+
+// direct runs units of work straight against fixed repositories.
+type direct struct {
+	repos unitofwork.Repositories
+}
+
+// Direct returns a unit of work that runs fn against repos as they are,
+// without a transaction: nothing is rolled back when fn fails. It is only
+// for tests, with mocked or in-memory repositories.
+func Direct(repos unitofwork.Repositories) unitofwork.UnitOfWork {
+	return direct{repos: repos}
+}
+
+func (d direct) Do(ctx context.Context, fn func(ctx context.Context, repos unitofwork.Repositories) error) error {
+	return fn(ctx, d.repos)
+}
+
+func (d direct) Read(ctx context.Context, fn func(ctx context.Context, repos unitofwork.Repositories) error) error {
+	return fn(ctx, d.repos)
+}
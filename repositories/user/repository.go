@@ -1,27 +1,34 @@
 package user
 
 import (
+	"context"
 	"database/sql"
-	"errors"
+	DB "p-system/db"
+	"p-system/tracing"
+	"p-system/utils"
 
 	sq "github.com/Masterminds/squirrel"
-	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 //go:generate mockgen --source=repository.go -destination=repository_mock.go -package=user Repository
 type Repository interface {
 	// GetUserByID  returns the user with the given ID.
-	GetUserByID(id string) (*User, error)
+	GetUserByID(ctx context.Context, id string) (*User, error)
+	// GetUsersByIDs returns the users with any of the given IDs, which must
+	// be UUIDs.
+	GetUsersByIDs(ctx context.Context, ids []string) ([]User, error)
 }
 
 // service implements the Repository interface.
 type service struct {
-	db   *sqlx.DB
+	db   DB.Queryer
 	psql sq.StatementBuilderType
 }
 
-// NewRepository creates a new user repository.
-func NewRepository(db *sqlx.DB) Repository {
+// NewRepository creates a new user repository running on db, which is
+// either the database or the transaction of a unit of work.
+func NewRepository(db DB.Queryer) Repository {
 	return &service{
 		db:   db,
 		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
@@ -29,7 +36,10 @@ func NewRepository(db *sqlx.DB) Repository {
 }
 
 // GetUserByID returns the user with the given ID.
-func (s service) GetUserByID(id string) (*User, error) {
+func (s service) GetUserByID(ctx context.Context, id string) (*User, error) {
+	ctx, span := tracing.StartDB(ctx, "user.GetUserByID")
+	defer span.End()
+
 	query, args, err := s.psql.Select("*").
 		From("users").
 		Where(sq.Eq{"id": id}).
@@ -39,12 +49,30 @@ func (s service) GetUserByID(id string) (*User, error) {
 	}
 
 	var user User
-	if err := s.db.Get(&user, query, args...); err != nil {
+	if err := s.db.GetContext(ctx, &user, query, args...); err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
+			return nil, utils.NotFound("user not found")
 		}
 		return nil, err
 	}
 
 	return &user, nil
 }
+
+// GetUsersByIDs returns the users with any of the given IDs, which must be
+// UUIDs.
+func (s service) GetUsersByIDs(ctx context.Context, ids []string) ([]User, error) {
+	ctx, span := tracing.StartDB(ctx, "user.GetUsersByIDs")
+	defer span.End()
+
+	users := []User{}
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	if err := s.db.SelectContext(ctx, &users, "SELECT * FROM users WHERE id = ANY($1::uuid[])", pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"context"
 	"p-system/tests"
 	"testing"
 
@@ -11,6 +12,7 @@ func TestGetUserByID(t *testing.T) {
 	db := tests.StartDB(t)
 
 	repo := NewRepository(db)
+	ctx := context.Background()
 
 	//run seeds
 	err := tests.Seed(db)
@@ -24,7 +26,7 @@ func TestGetUserByID(t *testing.T) {
 			Email: "john@ample.com",
 		}
 
-		user, err := repo.GetUserByID(expectedUser.ID)
+		user, err := repo.GetUserByID(ctx, expectedUser.ID)
 
 		require.NoError(t, err)
 		require.Equal(t, expectedUser.ID, user.ID)
@@ -33,7 +35,7 @@ func TestGetUserByID(t *testing.T) {
 
 	t.Run("TestGetUserByID_NotFound", func(t *testing.T) {
 		// Assuming "nonexistentid" does not exist in the seeded data
-		_, err := repo.GetUserByID("d164e69d-26f5-448d-a18c-baeae517d912")
+		_, err := repo.GetUserByID(ctx, "d164e69d-26f5-448d-a18c-baeae517d912")
 
 		require.Error(t, err)
 		require.EqualError(t, err, "user not found")
@@ -2,13 +2,23 @@ package wallet
 
 import "time"
 
+const (
+	StatusActive = "active"
+	// StatusFrozen wallets cannot be credited or debited.
+	StatusFrozen = "frozen"
+)
+
 type Wallet struct {
 	ID            string    `json:"id" db:"id"`
 	UserID        string    `json:"user_id" db:"user_id"`
 	Balance       int64     `json:"balance" db:"balance"`
+	Status        string    `json:"status" db:"status"`
 	CreatedAt     time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
 	TransactionID *string   `json:"transaction_id" db:"transaction_id"`
+	// Version goes up by one on every change to the wallet. Set on a wallet
+	// being credited or debited, it is the version the wallet must still be at.
+	Version int64 `json:"version" db:"version"`
 }
 
 // NewWallet creates a new wallet.
@@ -16,7 +26,27 @@ func NewWallet(userID string, balance int64) *Wallet {
 	return &Wallet{
 		UserID:    userID,
 		Balance:   balance,
+		Status:    StatusActive,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 }
+
+// Snapshot is the balance of a wallet as of a point in time. Snapshots let
+// historical balances be worked out without replaying a wallet's whole history.
+type Snapshot struct {
+	ID        string    `json:"id" db:"id"`
+	WalletID  string    `json:"wallet_id" db:"wallet_id"`
+	Balance   int64     `json:"balance" db:"balance"`
+	TakenAt   time.Time `json:"taken_at" db:"taken_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Discrepancy is a wallet whose balance differs from the balance its completed
+// transactions add up to.
+type Discrepancy struct {
+	WalletID string `json:"wallet_id" db:"wallet_id"`
+	UserID   string `json:"user_id" db:"user_id"`
+	Balance  int64  `json:"balance" db:"balance"`
+	Expected int64  `json:"expected" db:"expected"`
+}
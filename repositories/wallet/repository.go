@@ -1,42 +1,94 @@
 package wallet
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"database/sql"
+	"log/slog"
+	DB "p-system/db"
+	"p-system/metrics"
 	"p-system/repositories/transaction"
-	"strings"
+	"p-system/tracing"
+	"p-system/utils"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
-	"github.com/jmoiron/sqlx"
 )
 
 //go:generate mockgen --source=repository.go -destination=respository_mock.go -package=wallet Repository
 type Repository interface {
 	// Create creates a new wallet.
-	Create(*Wallet) (*Wallet, error)
+	Create(context.Context, *Wallet) (*Wallet, error)
 	// GetWalletByUserID returns the wallet with the given user id.
-	GetWalletByUserID(string) (*Wallet, error)
-	// CreditWallet updates the balance of a wallet.
-	CreditWallet(*Wallet, transaction.Transaction, int64) (*Wallet, error)
-	// DebitWallet updates the balance of a wallet.
-	DebitWallet(*Wallet, transaction.Transaction, int64) (*Wallet, error)
+	GetWalletByUserID(context.Context, string) (*Wallet, error)
+	// GetWalletByID returns the wallet with the given id.
+	GetWalletByID(context.Context, string) (*Wallet, error)
+	// CreditWallet updates the balance of a wallet and returns the wallet as
+	// updated. It fails with a precondition failed error if the wallet has a
+	// version it is no longer at.
+	CreditWallet(context.Context, *Wallet, transaction.Transaction, int64) (*Wallet, error)
+	// DebitWallet updates the balance of a wallet and returns the wallet as
+	// updated, like CreditWallet.
+	DebitWallet(context.Context, *Wallet, transaction.Transaction, int64) (*Wallet, error)
+	// GetBalanceAt returns the balance of a wallet as of the given time.
+	GetBalanceAt(ctx context.Context, walletID string, at time.Time) (int64, error)
+	// SnapshotBalances records the balance as of at of every wallet that has
+	// no snapshot taken within every before at, and returns how many it took.
+	SnapshotBalances(ctx context.Context, at time.Time, every time.Duration) (int64, error)
+	// CheckBalances returns every wallet whose balance differs from the sum of
+	// its completed transactions.
+	CheckBalances(ctx context.Context) ([]Discrepancy, error)
+	// UpdateWalletStatus sets the status of a wallet.
+	UpdateWalletStatus(ctx context.Context, id, status string) (*Wallet, error)
+}
+
+// netAmount sums completed credits minus completed debits.
+const netAmount = "COALESCE(SUM(CASE WHEN type = 'debit' THEN -amount ELSE amount END), 0)"
+
+// createViolations are the errors creating a wallet reports for the
+// constraints it can violate.
+var createViolations = DB.Violations{
+	"wallets_user_id_key":          utils.Conflict("user already has a wallet"),
+	"wallets_user_id_fkey":         utils.NotFound("user not found"),
+	"wallets_balance_non_negative": utils.InvalidRequest("balance must not be negative"),
+}
+
+// balanceViolations are the errors updating a balance reports for the
+// constraints it can violate.
+var balanceViolations = DB.Violations{
+	"wallets_balance_non_negative": utils.ErrInsufficientFunds,
+}
+
+// statusViolations are the errors setting the status of a wallet reports for
+// values the wallet_status enum does not have.
+var statusViolations = DB.Violations{
+	"invalid_text_representation": utils.InvalidRequest("invalid wallet status"),
 }
 
 type service struct {
-	db   *sqlx.DB
-	psql sq.StatementBuilderType
+	db       DB.Queryer
+	policies DB.Policies
+	psql     sq.StatementBuilderType
+	logger   *slog.Logger
 }
 
-func NewRepository(db *sqlx.DB) Repository {
+// NewRepository creates a new wallet repository running on db, which is
+// either the database or the transaction of a unit of work. Credits and debits
+// that begin their own transaction run it as policies say; nil policies use
+// the defaults.
+func NewRepository(db DB.Queryer, policies DB.Policies, logger *slog.Logger) Repository {
 	return &service{
-		db:   db,
-		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		db:       db,
+		policies: policies,
+		psql:     sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
+		logger:   logger,
 	}
 }
 
 // Create creates a new wallet.
-func (s service) Create(wallet *Wallet) (*Wallet, error) {
+func (s service) Create(ctx context.Context, wallet *Wallet) (*Wallet, error) {
+	ctx, span := tracing.StartDB(ctx, "wallet.Create")
+	defer span.End()
+
 	query, args, err := s.psql.Insert("wallets").
 		Columns("user_id", "balance", "created_at", "updated_at").
 		Values(wallet.UserID, wallet.Balance, wallet.CreatedAt, wallet.UpdatedAt).
@@ -47,15 +99,18 @@ func (s service) Create(wallet *Wallet) (*Wallet, error) {
 	}
 
 	var w Wallet
-	if err := s.db.Get(&w, query, args...); err != nil {
-		return nil, err
+	if err := s.db.GetContext(ctx, &w, query, args...); err != nil {
+		return nil, createViolations.Map(err)
 	}
 
 	return &w, nil
 }
 
 // GetWalletByUserID returns the wallet with the given user id.
-func (s service) GetWalletByUserID(userID string) (*Wallet, error) {
+func (s service) GetWalletByUserID(ctx context.Context, userID string) (*Wallet, error) {
+	ctx, span := tracing.StartDB(ctx, "wallet.GetWalletByUserID")
+	defer span.End()
+
 	query, args, err := s.psql.Select("*").
 		From("wallets").
 		Where(sq.Eq{"user_id": userID}).
@@ -64,156 +119,251 @@ func (s service) GetWalletByUserID(userID string) (*Wallet, error) {
 		return nil, err
 	}
 
-	log.Println("query", Log(query, args...))
-
 	var w Wallet
-	if err := s.db.Get(&w, query, args...); err != nil {
-		log.Println("error", err)
+	if err := s.db.GetContext(ctx, &w, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("wallet not found")
+		}
 		return nil, err
 	}
 
 	return &w, nil
 }
 
-// CreditWallet updates the balance of a wallet.
-func (s service) CreditWallet(wallet *Wallet, transaction transaction.Transaction, amount int64) (*Wallet, error) {
-	//use transaction to ensure atomicity
-	tx, err := s.db.Beginx()
-	if err != nil {
-		return nil, err
-	}
+// GetWalletByID returns the wallet with the given id.
+func (s service) GetWalletByID(ctx context.Context, id string) (*Wallet, error) {
+	ctx, span := tracing.StartDB(ctx, "wallet.GetWalletByID")
+	defer span.End()
 
-	//update transaction status to completed
-	_, err = tx.Exec("UPDATE transactions SET status = $1,updated_at = $2 WHERE id = $3", "completed", time.Now(), transaction.ID)
+	query, args, err := s.psql.Select("*").
+		From("wallets").
+		Where(sq.Eq{"id": id}).
+		ToSql()
 	if err != nil {
-		tx.Rollback()
 		return nil, err
 	}
 
-	//get the transaction id
-	wallet.TransactionID = &transaction.ID
-
-	//lock the wallet row to prevent concurrent updates
-	_, err = tx.Exec("SELECT * FROM wallets WHERE id = $1 FOR UPDATE", wallet.ID)
-
-	if err != nil {
-		tx.Rollback()
+	var w Wallet
+	if err := s.db.GetContext(ctx, &w, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("wallet not found")
+		}
 		return nil, err
 	}
 
-	//update the wallet and transaction id with the values passed
+	return &w, nil
+}
 
-	_, err = tx.Exec("UPDATE wallets SET balance = balance + $1, updated_at = $2, transaction_id = $3 WHERE id = $4", amount, time.Now(), wallet.TransactionID, wallet.ID)
+// CreditWallet updates the balance of a wallet.
+func (s service) CreditWallet(ctx context.Context, wallet *Wallet, transaction transaction.Transaction, amount int64) (*Wallet, error) {
+	ctx, span := tracing.StartDB(ctx, "wallet.CreditWallet")
+	defer span.End()
 
-	if err != nil {
-		tx.Rollback()
-		return nil, err
-	}
+	return s.updateBalance(ctx, "credit_wallet", wallet, transaction, amount)
+}
 
-	//commit the transaction
-	err = tx.Commit()
-	if err != nil {
-		return nil, err
-	}
+// DebitWallet updates the balance of a wallet.
+func (s service) DebitWallet(ctx context.Context, wallet *Wallet, transaction transaction.Transaction, amount int64) (*Wallet, error) {
+	ctx, span := tracing.StartDB(ctx, "wallet.DebitWallet")
+	defer span.End()
 
-	//fetch the updated wallet
-	query, args, err := s.psql.Select("*").
-		From("wallets").
-		Where(sq.Eq{"id": wallet.ID}).
-		ToSql()
+	return s.updateBalance(ctx, "debit_wallet", wallet, transaction, -amount)
+}
 
-	if err != nil {
-		return nil, err
-	}
+// updateBalance adds delta to the balance of a wallet and records the
+// transaction that changed it. Completing the transaction itself is up to the
+// caller, in the same unit of work. If wallet has a version the update only
+// goes ahead while the wallet is still at it. The wallet is returned as the
+// update left it, whatever happens to it afterwards.
+func (s service) updateBalance(ctx context.Context, operation string, wallet *Wallet, transaction transaction.Transaction, delta int64) (*Wallet, error) {
+	//use transaction to ensure atomicity, joining the unit of work if any,
+	//and run it again if it deadlocks with a concurrent update
+	start := time.Now()
+	defer func() {
+		metrics.DBTransactionDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}()
 
 	var w Wallet
+	err := DB.RetryTx(ctx, s.db, operation, s.policies.For(operation), s.logger, func(tx DB.Queryer) error {
+		//lock the wallet row to prevent concurrent updates
+		lockStart := time.Now()
+		var version int64
+		err := tx.GetContext(ctx, &version, "SELECT version FROM wallets WHERE id = $1 FOR UPDATE", wallet.ID)
+		metrics.DBLockWait.WithLabelValues(operation).Observe(time.Since(lockStart).Seconds())
+		if err == sql.ErrNoRows {
+			return utils.NotFound("wallet not found")
+		}
+		if err != nil {
+			return err
+		}
+		if wallet.Version != 0 && wallet.Version != version {
+			return utils.PreconditionFailed("wallet has been modified")
+		}
+
+		//get the transaction id
+		wallet.TransactionID = &transaction.ID
 
-	if err := s.db.Get(&w, query, args...); err != nil {
+		//update the wallet and transaction id with the values passed
+		err = tx.GetContext(ctx, &w, "UPDATE wallets SET balance = balance + $1, updated_at = $2, transaction_id = $3, version = version + 1 WHERE id = $4 RETURNING *", delta, time.Now(), wallet.TransactionID, wallet.ID)
+		return balanceViolations.Map(err)
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return &w, nil
+}
 
+// GetBalanceAt returns the balance of a wallet as of the given time. It starts
+// from the closest snapshot on either side of at, or the current balance if
+// there are none, and applies the completed transactions in between.
+func (s service) GetBalanceAt(ctx context.Context, walletID string, at time.Time) (int64, error) {
+	ctx, span := tracing.StartDB(ctx, "wallet.GetBalanceAt")
+	defer span.End()
+
+	//use a repeatable read transaction so every query sees the same state
+	var balance int64
+	err := DB.InTx(ctx, s.db, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}, func(tx DB.Queryer) error {
+		var err error
+		balance, err = s.balanceAt(ctx, tx, walletID, at)
+		return err
+	})
+	return balance, err
 }
 
-// DebitWallet updates the balance of a wallet.
-func (s service) DebitWallet(wallet *Wallet, transaction transaction.Transaction, amount int64) (*Wallet, error) {
-	//use transaction to ensure atomicity
-	tx, err := s.db.Beginx()
-	if err != nil {
-		return nil, err
+// balanceAt works out the balance of a wallet as of at within tx.
+func (s service) balanceAt(ctx context.Context, tx DB.Queryer, walletID string, at time.Time) (int64, error) {
+	var w Wallet
+	if err := tx.GetContext(ctx, &w, "SELECT * FROM wallets WHERE id = $1", walletID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, utils.NotFound("wallet not found")
+		}
+		return 0, err
 	}
 
-	//update transaction status to completed
-	_, err = tx.Exec("UPDATE transactions SET status = $1,updated_at = $2 WHERE id = $3", "completed", time.Now(), transaction.ID)
-	if err != nil {
-		tx.Rollback()
-		return nil, err
+	if at.Before(w.CreatedAt) {
+		return 0, nil
 	}
 
-	wallet.TransactionID = &transaction.ID
+	net := func(after time.Time, until *time.Time) (int64, error) {
+		query := s.psql.Select(netAmount).
+			From("transactions").
+			Where(sq.Eq{"user_id": w.UserID, "status": "completed"}).
+			Where(sq.Gt{"completed_at": after})
+		if until != nil {
+			query = query.Where(sq.LtOrEq{"completed_at": *until})
+		}
 
-	//lock the wallet row to prevent concurrent updates
-	_, err = tx.Exec("SELECT * FROM wallets WHERE id = $1 FOR UPDATE", wallet.ID)
+		q, args, err := query.ToSql()
+		if err != nil {
+			return 0, err
+		}
 
-	if err != nil {
-		tx.Rollback()
-		return nil, err
+		var amount int64
+		err = tx.GetContext(ctx, &amount, q, args...)
+		return amount, err
 	}
 
-	//update the wallet and transaction id with the values passed
+	var snapshot Snapshot
 
-	_, err = tx.Exec("UPDATE wallets SET balance = balance - $1, updated_at = $2, transaction_id = $3 WHERE id = $4", amount, time.Now(), wallet.TransactionID, wallet.ID)
+	// Roll forward from the latest snapshot at or before at
+	err := tx.GetContext(ctx, &snapshot, "SELECT * FROM wallet_balance_snapshots WHERE wallet_id = $1 AND taken_at <= $2 ORDER BY taken_at DESC LIMIT 1", walletID, at)
+	if err == nil {
+		amount, err := net(snapshot.TakenAt, &at)
+		return snapshot.Balance + amount, err
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
 
-	if err != nil {
-		tx.Rollback()
-		return nil, err
+	// Otherwise roll back from the earliest snapshot after at
+	err = tx.GetContext(ctx, &snapshot, "SELECT * FROM wallet_balance_snapshots WHERE wallet_id = $1 AND taken_at > $2 ORDER BY taken_at LIMIT 1", walletID, at)
+	if err == nil {
+		amount, err := net(at, &snapshot.TakenAt)
+		return snapshot.Balance - amount, err
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
 	}
 
-	//commit the transaction
-	err = tx.Commit()
+	// Otherwise roll back from the current balance
+	amount, err := net(at, nil)
+	return w.Balance - amount, err
+}
+
+// SnapshotBalances records the balance as of at of every wallet that has no
+// snapshot taken within every before at. The balance is derived from the
+// current balance in a single statement so it is consistent with the
+// transactions table.
+func (s service) SnapshotBalances(ctx context.Context, at time.Time, every time.Duration) (int64, error) {
+	ctx, span := tracing.StartDB(ctx, "wallet.SnapshotBalances")
+	defer span.End()
+
+	result, err := s.db.ExecContext(ctx, `INSERT INTO wallet_balance_snapshots (wallet_id, balance, taken_at)
+		SELECT w.id, w.balance - (SELECT `+netAmount+` FROM transactions t
+			WHERE t.user_id = w.user_id AND t.status = 'completed' AND t.completed_at > $1), $1
+		FROM wallets w
+		WHERE w.created_at <= $1
+		AND NOT EXISTS (SELECT 1 FROM wallet_balance_snapshots ws WHERE ws.wallet_id = w.id AND ws.taken_at > $2)
+		ON CONFLICT (wallet_id, taken_at) DO NOTHING`, at, at.Add(-every))
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	//fetch the updated wallet
-	query, args, err := s.psql.Select("*").
-		From("wallets").
-		Where(sq.Eq{"id": wallet.ID}).
-		ToSql()
+	return result.RowsAffected()
+}
 
+// CheckBalances returns every wallet whose balance differs from completed
+// credits minus completed debits. It runs as a single statement so balances
+// and transactions are read from the same snapshot.
+func (s service) CheckBalances(ctx context.Context) ([]Discrepancy, error) {
+	ctx, span := tracing.StartDB(ctx, "wallet.CheckBalances")
+	defer span.End()
+
+	expected := "COALESCE(SUM(CASE WHEN t.type = 'debit' THEN -t.amount ELSE t.amount END), 0)"
+
+	query, args, err := s.psql.Select("w.id AS wallet_id", "w.user_id", "w.balance", expected+" AS expected").
+		From("wallets w").
+		LeftJoin("transactions t ON t.user_id = w.user_id AND t.status = 'completed'").
+		GroupBy("w.id").
+		Having("w.balance <> " + expected).
+		OrderBy("w.id").
+		ToSql()
 	if err != nil {
 		return nil, err
 	}
 
-	var w Wallet
-
-	if err := s.db.Get(&w, query, args...); err != nil {
+	discrepancies := []Discrepancy{}
+	if err := s.db.SelectContext(ctx, &discrepancies, query, args...); err != nil {
 		return nil, err
 	}
 
-	return &w, nil
+	return discrepancies, nil
 }
 
-// Log provides a pretty print version of the query and parameters.
-func Log(query string, args ...interface{}) string {
-	for i, arg := range args {
-		n := fmt.Sprintf("$%d", i+1)
-
-		var a string
-		switch v := arg.(type) {
-		case string:
-			a = fmt.Sprintf("%q", v)
-		case []byte:
-			a = string(v)
-		case []string:
-			a = strings.Join(v, ",")
-		default:
-			a = fmt.Sprintf("%v", v)
-		}
+// UpdateWalletStatus sets the status of a wallet.
+func (s service) UpdateWalletStatus(ctx context.Context, id, status string) (*Wallet, error) {
+	ctx, span := tracing.StartDB(ctx, "wallet.UpdateWalletStatus")
+	defer span.End()
+
+	query, args, err := s.psql.Update("wallets").
+		Set("status", status).
+		Set("updated_at", time.Now()).
+		Set("version", sq.Expr("version + 1")).
+		Where(sq.Eq{"id": id}).
+		Suffix("RETURNING *").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
 
-		query = strings.Replace(query, n, a, 1)
+	var w Wallet
+	if err := s.db.GetContext(ctx, &w, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("wallet not found")
+		}
+		return nil, statusViolations.Map(err)
 	}
 
-	return query
+	return &w, nil
 }
@@ -1,8 +1,11 @@
 package wallet
 
 import (
+	"context"
+	"p-system/logging"
 	"p-system/repositories/transaction"
 	"p-system/tests"
+	"p-system/utils"
 	"testing"
 	"time"
 
@@ -12,7 +15,8 @@ import (
 func TestWalletRepository(t *testing.T) {
 	db := tests.StartDB(t)
 
-	repo := NewRepository(db)
+	repo := NewRepository(db, nil, logging.Discard())
+	ctx := context.Background()
 
 	// Run seeds
 	err := tests.Seed(db)
@@ -21,29 +25,51 @@ func TestWalletRepository(t *testing.T) {
 	}
 
 	t.Run("TestCreateWallet_Success", func(t *testing.T) {
+		_, err := db.Exec("INSERT INTO users (id, username, email, password) VALUES ('d164e69d-26f5-448d-a18c-baeae517d9f3', 'jane_doe', 'jane@ample.com', 'hashed_password_here')")
+		require.NoError(t, err)
+
 		newWallet := &Wallet{
-			UserID:    "d164e69d-26f5-448d-a18c-baeae517d9f2",
-			Balance:   1000,
+			UserID:    "d164e69d-26f5-448d-a18c-baeae517d9f3",
+			Balance:   0,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		}
 
-		createdWallet, err := repo.Create(newWallet)
+		createdWallet, err := repo.Create(ctx, newWallet)
 
 		require.NoError(t, err)
 		require.Equal(t, newWallet.UserID, createdWallet.UserID)
 	})
 
+	t.Run("TestCreateWallet_UserHasWallet", func(t *testing.T) {
+		_, err := repo.Create(ctx, NewWallet("d164e69d-26f5-448d-a18c-baeae517d9f2", 0))
+
+		require.ErrorIs(t, err, utils.ErrConflict)
+		require.EqualError(t, err, "user already has a wallet")
+	})
+
+	t.Run("TestCreateWallet_UnknownUser", func(t *testing.T) {
+		_, err := repo.Create(ctx, NewWallet("d164e69d-26f5-448d-a18c-baeae517d000", 0))
+
+		require.EqualError(t, err, "user not found")
+	})
+
 	t.Run("TestGetWalletByUserID_Success", func(t *testing.T) {
 		expectedUserID := "d164e69d-26f5-448d-a18c-baeae517d9f2"
 
-		wallet, err := repo.GetWalletByUserID(expectedUserID)
+		wallet, err := repo.GetWalletByUserID(ctx, expectedUserID)
 
 		require.NoError(t, err)
 		require.NotNil(t, wallet)
 		require.Equal(t, expectedUserID, wallet.UserID)
 	})
 
+	t.Run("TestGetWalletByID_NotFound", func(t *testing.T) {
+		_, err := repo.GetWalletByID(ctx, "d164e69d-26f5-448d-a18c-baeae517d000")
+
+		require.EqualError(t, err, "wallet not found")
+	})
+
 	t.Run("TestCreditWallet_Success", func(t *testing.T) {
 		wallet := &Wallet{
 			ID:        "d164e69d-26f5-448d-a18c-baeae517d991",
@@ -57,11 +83,12 @@ func TestWalletRepository(t *testing.T) {
 			Amount: 500,
 		}
 
-		updatedWallet, err := repo.CreditWallet(wallet, transaction, 5000)
+		updatedWallet, err := repo.CreditWallet(ctx, wallet, transaction, 5000)
 
 		require.NoError(t, err)
 		require.NotNil(t, updatedWallet)
 		require.Equal(t, int64(5000), updatedWallet.Balance)
+		require.Equal(t, int64(2), updatedWallet.Version)
 	})
 
 	t.Run("TestDebitWallet_Success", func(t *testing.T) {
@@ -77,10 +104,99 @@ func TestWalletRepository(t *testing.T) {
 			ID: "d164e69d-26f5-448d-a18c-baeae517d9f5",
 		}
 
-		updatedWallet, err := repo.DebitWallet(wallet, transaction, 500)
+		updatedWallet, err := repo.DebitWallet(ctx, wallet, transaction, 500)
 
 		require.NoError(t, err)
 		require.NotNil(t, updatedWallet)
 		require.Equal(t, int64(4500), updatedWallet.Balance)
 	})
+
+	t.Run("TestDebitWallet_InsufficientFunds", func(t *testing.T) {
+		wallet := &Wallet{ID: "d164e69d-26f5-448d-a18c-baeae517d991"}
+
+		_, err := repo.DebitWallet(ctx, wallet, transaction.Transaction{ID: "d164e69d-26f5-448d-a18c-baeae517d9f5"}, 10000)
+		require.ErrorIs(t, err, utils.ErrInsufficientFunds)
+
+		w, err := repo.GetWalletByID(ctx, "d164e69d-26f5-448d-a18c-baeae517d991")
+		require.NoError(t, err)
+		require.Equal(t, int64(4500), w.Balance)
+	})
+
+	t.Run("TestCreditWallet_StaleVersion", func(t *testing.T) {
+		// Crediting and debiting above moved the wallet on from version 1
+		_, err := repo.CreditWallet(ctx, &Wallet{ID: "d164e69d-26f5-448d-a18c-baeae517d991", Version: 1}, transaction.Transaction{ID: "d164e69d-26f5-448d-a18c-baeae517d9f5"}, 100)
+
+		require.ErrorIs(t, err, utils.ErrPreconditionFailed)
+	})
+
+	t.Run("TestCreditWallet_JoinsTransaction", func(t *testing.T) {
+		// Credit within a transaction that is rolled back when the subtest ends
+		t.Run("Credit", func(t *testing.T) {
+			txRepo := NewRepository(tests.BeginTx(t, db), nil, logging.Discard())
+
+			updatedWallet, err := txRepo.CreditWallet(ctx, &Wallet{ID: "d164e69d-26f5-448d-a18c-baeae517d991"}, transaction.Transaction{ID: "d164e69d-26f5-448d-a18c-baeae517d9f5"}, 100)
+
+			require.NoError(t, err)
+			require.Equal(t, int64(4600), updatedWallet.Balance)
+		})
+
+		w, err := repo.GetWalletByID(ctx, "d164e69d-26f5-448d-a18c-baeae517d991")
+
+		require.NoError(t, err)
+		require.Equal(t, int64(4500), w.Balance)
+	})
+
+	t.Run("TestGetBalanceAt_Now", func(t *testing.T) {
+		balance, err := repo.GetBalanceAt(ctx, "d164e69d-26f5-448d-a18c-baeae517d991", time.Now().Add(time.Minute))
+
+		require.NoError(t, err)
+		require.Equal(t, int64(4500), balance)
+	})
+
+	t.Run("TestGetBalanceAt_BeforeCreation", func(t *testing.T) {
+		balance, err := repo.GetBalanceAt(ctx, "d164e69d-26f5-448d-a18c-baeae517d991", time.Now().Add(-time.Hour))
+
+		require.NoError(t, err)
+		require.Equal(t, int64(0), balance)
+	})
+
+	t.Run("TestSnapshotBalances_Success", func(t *testing.T) {
+		at := time.Now().Add(time.Minute)
+
+		taken, err := repo.SnapshotBalances(ctx, at, 24*time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, int64(2), taken)
+
+		// Wallets with a recent snapshot are skipped
+		taken, err = repo.SnapshotBalances(ctx, at.Add(time.Hour), 24*time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, int64(0), taken)
+
+		balance, err := repo.GetBalanceAt(ctx, "d164e69d-26f5-448d-a18c-baeae517d991", at.Add(time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, int64(4500), balance)
+	})
+
+	t.Run("TestCheckBalances_ReportsMismatch", func(t *testing.T) {
+		// The seeded wallet was credited and debited above without matching
+		// completed transactions
+		discrepancies, err := repo.CheckBalances(ctx)
+
+		require.NoError(t, err)
+		require.NotEmpty(t, discrepancies)
+		require.Equal(t, "d164e69d-26f5-448d-a18c-baeae517d9f2", discrepancies[0].UserID)
+	})
+
+	t.Run("TestUpdateWalletStatus_Success", func(t *testing.T) {
+		w, err := repo.UpdateWalletStatus(ctx, "d164e69d-26f5-448d-a18c-baeae517d991", StatusFrozen)
+
+		require.NoError(t, err)
+		require.Equal(t, StatusFrozen, w.Status)
+	})
+
+	t.Run("TestUpdateWalletStatus_InvalidStatus", func(t *testing.T) {
+		_, err := repo.UpdateWalletStatus(ctx, "d164e69d-26f5-448d-a18c-baeae517d991", "closed")
+
+		require.ErrorIs(t, err, utils.ErrInvalidRequest)
+	})
 }
@@ -5,8 +5,10 @@
 package wallet
 
 import (
+	context "context"
 	transaction "p-system/repositories/transaction"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -34,62 +36,137 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// CheckBalances mocks base method.
+func (m *MockRepository) CheckBalances(ctx context.Context) ([]Discrepancy, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckBalances", ctx)
+	ret0, _ := ret[0].([]Discrepancy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckBalances indicates an expected call of CheckBalances.
+func (mr *MockRepositoryMockRecorder) CheckBalances(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckBalances", reflect.TypeOf((*MockRepository)(nil).CheckBalances), ctx)
+}
+
 // Create mocks base method.
-func (m *MockRepository) Create(arg0 *Wallet) (*Wallet, error) {
+func (m *MockRepository) Create(arg0 context.Context, arg1 *Wallet) (*Wallet, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", arg0)
+	ret := m.ctrl.Call(m, "Create", arg0, arg1)
 	ret0, _ := ret[0].(*Wallet)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockRepositoryMockRecorder) Create(arg0 interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) Create(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRepository)(nil).Create), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRepository)(nil).Create), arg0, arg1)
 }
 
 // CreditWallet mocks base method.
-func (m *MockRepository) CreditWallet(arg0 *Wallet, arg1 transaction.Transaction, arg2 int64) (*Wallet, error) {
+func (m *MockRepository) CreditWallet(arg0 context.Context, arg1 *Wallet, arg2 transaction.Transaction, arg3 int64) (*Wallet, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreditWallet", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "CreditWallet", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(*Wallet)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreditWallet indicates an expected call of CreditWallet.
-func (mr *MockRepositoryMockRecorder) CreditWallet(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) CreditWallet(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreditWallet", reflect.TypeOf((*MockRepository)(nil).CreditWallet), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreditWallet", reflect.TypeOf((*MockRepository)(nil).CreditWallet), arg0, arg1, arg2, arg3)
 }
 
 // DebitWallet mocks base method.
-func (m *MockRepository) DebitWallet(arg0 *Wallet, arg1 transaction.Transaction, arg2 int64) (*Wallet, error) {
+func (m *MockRepository) DebitWallet(arg0 context.Context, arg1 *Wallet, arg2 transaction.Transaction, arg3 int64) (*Wallet, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DebitWallet", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "DebitWallet", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(*Wallet)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DebitWallet indicates an expected call of DebitWallet.
-func (mr *MockRepositoryMockRecorder) DebitWallet(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) DebitWallet(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DebitWallet", reflect.TypeOf((*MockRepository)(nil).DebitWallet), arg0, arg1, arg2, arg3)
+}
+
+// GetBalanceAt mocks base method.
+func (m *MockRepository) GetBalanceAt(ctx context.Context, walletID string, at time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalanceAt", ctx, walletID, at)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBalanceAt indicates an expected call of GetBalanceAt.
+func (mr *MockRepositoryMockRecorder) GetBalanceAt(ctx, walletID, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalanceAt", reflect.TypeOf((*MockRepository)(nil).GetBalanceAt), ctx, walletID, at)
+}
+
+// GetWalletByID mocks base method.
+func (m *MockRepository) GetWalletByID(arg0 context.Context, arg1 string) (*Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWalletByID", arg0, arg1)
+	ret0, _ := ret[0].(*Wallet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWalletByID indicates an expected call of GetWalletByID.
+func (mr *MockRepositoryMockRecorder) GetWalletByID(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DebitWallet", reflect.TypeOf((*MockRepository)(nil).DebitWallet), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWalletByID", reflect.TypeOf((*MockRepository)(nil).GetWalletByID), arg0, arg1)
 }
 
 // GetWalletByUserID mocks base method.
-func (m *MockRepository) GetWalletByUserID(arg0 string) (*Wallet, error) {
+func (m *MockRepository) GetWalletByUserID(arg0 context.Context, arg1 string) (*Wallet, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetWalletByUserID", arg0)
+	ret := m.ctrl.Call(m, "GetWalletByUserID", arg0, arg1)
 	ret0, _ := ret[0].(*Wallet)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetWalletByUserID indicates an expected call of GetWalletByUserID.
-func (mr *MockRepositoryMockRecorder) GetWalletByUserID(arg0 interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) GetWalletByUserID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWalletByUserID", reflect.TypeOf((*MockRepository)(nil).GetWalletByUserID), arg0, arg1)
+}
+
+// SnapshotBalances mocks base method.
+func (m *MockRepository) SnapshotBalances(ctx context.Context, at time.Time, every time.Duration) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SnapshotBalances", ctx, at, every)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SnapshotBalances indicates an expected call of SnapshotBalances.
+func (mr *MockRepositoryMockRecorder) SnapshotBalances(ctx, at, every interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SnapshotBalances", reflect.TypeOf((*MockRepository)(nil).SnapshotBalances), ctx, at, every)
+}
+
+// UpdateWalletStatus mocks base method.
+func (m *MockRepository) UpdateWalletStatus(ctx context.Context, id, status string) (*Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWalletStatus", ctx, id, status)
+	ret0, _ := ret[0].(*Wallet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateWalletStatus indicates an expected call of UpdateWalletStatus.
+func (mr *MockRepositoryMockRecorder) UpdateWalletStatus(ctx, id, status interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWalletByUserID", reflect.TypeOf((*MockRepository)(nil).GetWalletByUserID), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWalletStatus", reflect.TypeOf((*MockRepository)(nil).UpdateWalletStatus), ctx, id, status)
 }
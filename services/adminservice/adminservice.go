@@ -0,0 +1,258 @@
+// Package adminservice implements the operations of the admin CLI.
+package adminservice
+
+import (
+	"context"
+	"errors"
+	"p-system/repositories/approval"
+	"p-system/repositories/transaction"
+	"p-system/repositories/unitofwork"
+	"p-system/repositories/user"
+	"p-system/repositories/wallet"
+	"p-system/utils"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserReport is a user and their wallet.
+type UserReport struct {
+	User   *user.User     `json:"user"`
+	Wallet *wallet.Wallet `json:"wallet,omitempty"`
+}
+
+// WalletReport is a wallet and the balance its completed transactions add up
+// to, which differs from the balance when something went wrong.
+type WalletReport struct {
+	Wallet   *wallet.Wallet `json:"wallet"`
+	Expected int64          `json:"expected_balance"`
+}
+
+// TransactionReport is a transaction and every status it has been in.
+type TransactionReport struct {
+	Transaction *transaction.Transaction   `json:"transaction"`
+	History     []transaction.StatusChange `json:"history"`
+}
+
+// Adjustment is a credit or debit of a wallet made by hand.
+type Adjustment struct {
+	WalletID string `json:"wallet_id"`
+	// Type is credit or debit.
+	Type string `json:"type"`
+	// Amount is in cents.
+	Amount int64 `json:"amount"`
+	// Reason is required, and kept on the adjustment transaction.
+	Reason string `json:"reason"`
+	// Operator is who made the adjustment. The operator of a requested
+	// adjustment is the one authenticated when it was requested.
+	Operator string `json:"operator"`
+	// Reference is the reference of the adjustment transaction, generated if
+	// empty. An approved adjustment is made under a reference of its
+	// approval, so it is never made twice.
+	Reference string `json:"-"`
+}
+
+// validate checks an adjustment can be made at all.
+func (a Adjustment) validate() error {
+	switch {
+	case a.Type != "credit" && a.Type != "debit":
+		return errors.New("type must be credit or debit")
+	case a.Amount <= 0:
+		return errors.New("amount must be positive")
+	case strings.TrimSpace(a.Reason) == "":
+		return errors.New("a reason is required")
+	}
+	return nil
+}
+
+// AdjustmentReport is an adjustment transaction and the wallet it changed.
+type AdjustmentReport struct {
+	Transaction *transaction.Transaction `json:"transaction"`
+	Wallet      *wallet.Wallet           `json:"wallet"`
+}
+
+// RetryResult is the outcome of retrying one pending transaction.
+type RetryResult struct {
+	TransactionID string `json:"transaction_id"`
+	Reference     string `json:"reference"`
+	// Status is the status the transaction ended in, or pending on a dry run
+	// or when it could not be retried.
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// InspectUser returns a user and their wallet, if they have one.
+func (s service) InspectUser(ctx context.Context, id string) (*UserReport, error) {
+	u, err := s.userRepo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := s.walletRepo.GetWalletByUserID(ctx, id)
+	if err != nil && !errors.Is(err, utils.ErrNotFound) {
+		return nil, err
+	}
+
+	return &UserReport{User: u, Wallet: w}, nil
+}
+
+// InspectWallet returns a wallet and the balance its completed transactions
+// add up to.
+func (s service) InspectWallet(ctx context.Context, id string) (*WalletReport, error) {
+	w, err := s.walletRepo.GetWalletByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	expected, err := s.transactionRepo.GetNetAmountSince(ctx, w.UserID, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &WalletReport{Wallet: w, Expected: expected}, nil
+}
+
+// InspectTransaction returns a transaction and its status history.
+func (s service) InspectTransaction(ctx context.Context, id string) (*TransactionReport, error) {
+	t, err := s.transactionRepo.GetTransactionByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.transactionRepo.GetStatusHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TransactionReport{Transaction: t, History: history}, nil
+}
+
+// RequestAdjustment holds an adjustment until someone other than its
+// operator approves it. The operator is the one carried by ctx, whoever the
+// adjustment names.
+func (s service) RequestAdjustment(ctx context.Context, adjustment Adjustment) (*approval.Approval, error) {
+	if err := adjustment.validate(); err != nil {
+		return nil, err
+	}
+	adjustment.Operator = utils.OperatorFrom(ctx)
+	if adjustment.Operator == "" {
+		return nil, utils.Unauthorized("an operator is required")
+	}
+	if _, err := s.walletRepo.GetWalletByID(ctx, adjustment.WalletID); err != nil {
+		return nil, err
+	}
+
+	a, err := approval.NewApproval(approval.KindAdjustment, adjustment.Operator, adjustment.Amount, adjustment)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err = s.approvalRepo.Create(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "requested adjustment", "approval_id", a.ID, "wallet_id", adjustment.WalletID, "type", adjustment.Type, "operator", adjustment.Operator)
+	return a, nil
+}
+
+// Adjust credits or debits a wallet by hand. Frozen wallets can be adjusted,
+// since fixing them is often why they are adjusted, but debits still cannot
+// overdraw them.
+func (s service) Adjust(ctx context.Context, adjustment Adjustment) (*AdjustmentReport, error) {
+	if err := adjustment.validate(); err != nil {
+		return nil, err
+	}
+	reason := strings.TrimSpace(adjustment.Reason)
+	reference := adjustment.Reference
+	if reference == "" {
+		reference = "adjustment-" + uuid.NewString()
+	}
+
+	var report AdjustmentReport
+	err := s.uow.Do(ctx, func(ctx context.Context, repos unitofwork.Repositories) error {
+		w, err := repos.Wallets.GetWalletByID(ctx, adjustment.WalletID)
+		if err != nil {
+			return err
+		}
+		if adjustment.Type == "debit" && w.Balance < adjustment.Amount {
+			return utils.ErrInsufficientFunds
+		}
+
+		t := transaction.NewTransaction(w.UserID, "admin:"+adjustment.Operator, reference, adjustment.Type, adjustment.Amount)
+		t.Status = "completed"
+		t.Reason = &reason
+
+		if report.Transaction, err = repos.Transactions.Create(ctx, t); err != nil {
+			return err
+		}
+
+		// w carries the version its balance was checked at, so the update
+		// fails rather than act on a balance that has since changed
+		if adjustment.Type == "debit" {
+			report.Wallet, err = repos.Wallets.DebitWallet(ctx, w, *report.Transaction, adjustment.Amount)
+		} else {
+			report.Wallet, err = repos.Wallets.CreditWallet(ctx, w, *report.Transaction, adjustment.Amount)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The amount and reason are left to the report, they are redacted from
+	// logs
+	s.logger.InfoContext(ctx, "adjusted wallet", "wallet_id", adjustment.WalletID, "transaction_id", report.Transaction.ID, "type", adjustment.Type, "operator", adjustment.Operator, "reason", reason)
+
+	return &report, nil
+}
+
+// SetFrozen freezes or unfreezes a wallet.
+func (s service) SetFrozen(ctx context.Context, walletID string, frozen bool) (*wallet.Wallet, error) {
+	status := wallet.StatusActive
+	if frozen {
+		status = wallet.StatusFrozen
+	}
+
+	w, err := s.walletRepo.UpdateWalletStatus(ctx, walletID, status)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "set wallet status", "wallet_id", walletID, "status", status)
+	return w, nil
+}
+
+// RetryPending completes the transactions still pending that were created
+// before the given time, one at a time. Payments the provider has made
+// already are settled without being made again. A transaction that fails to
+// complete is reported and the rest are still retried.
+func (s service) RetryPending(ctx context.Context, before time.Time, dryRun bool) ([]RetryResult, error) {
+	pending, err := s.transactionRepo.GetPendingBefore(ctx, before)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []RetryResult{}
+	for _, t := range pending {
+		result := RetryResult{TransactionID: t.ID, Reference: t.Reference, Status: t.Status}
+		if dryRun {
+			results = append(results, result)
+			continue
+		}
+
+		if _, err := s.transactions.RetryTransaction(ctx, t.ID); err != nil {
+			result.Error = err.Error()
+			s.logger.WarnContext(ctx, "retrying pending transaction", "transaction_id", t.ID, "error", err)
+		}
+
+		// Report the status the transaction ended in, whatever the outcome
+		if current, err := s.transactionRepo.GetTransactionByID(ctx, t.ID); err == nil {
+			result.Status = current.Status
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
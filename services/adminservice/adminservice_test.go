@@ -0,0 +1,168 @@
+package adminservice
+
+import (
+	"context"
+	"p-system/fixtures"
+	"p-system/logging"
+	"p-system/repositories/approval"
+	"p-system/repositories/memory"
+	"p-system/repositories/unitofwork"
+	"p-system/repositories/unitofwork/unitofworktest"
+	"p-system/services/transactionsservice"
+	"p-system/utils"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestService returns a service on an in-memory store holding a user
+// with a wallet of 1000 cents.
+func newTestService(transactions transactionsservice.Service) (Service, *memory.Store) {
+	return newTestServiceWithApprovals(transactions, nil)
+}
+
+// newTestServiceWithApprovals is newTestService holding adjustments in
+// approvalRepo.
+func newTestServiceWithApprovals(transactions transactionsservice.Service, approvalRepo approval.Repository) (Service, *memory.Store) {
+	store := memory.NewStore()
+	store.PutUser(fixtures.User().WithID("user123").Build())
+	store.PutWallet(fixtures.Wallet("user123").WithID("wallet123").WithBalance(1000).Build())
+	store.PutTransaction(fixtures.Transaction("user123").Credit(1000).Completed().Build())
+
+	repos := unitofwork.Repositories{Users: store.Users(), Wallets: store.Wallets(), Transactions: store.Transactions()}
+	svc := NewService(repos.Users, repos.Wallets, repos.Transactions, unitofworktest.Direct(repos), transactions, approvalRepo, logging.Discard())
+	return svc, store
+}
+
+func TestAdjust_RecordsAdjustment(t *testing.T) {
+	svc, store := newTestService(nil)
+	ctx := context.Background()
+
+	report, err := svc.Adjust(ctx, Adjustment{WalletID: "wallet123", Type: "debit", Amount: 300, Reason: " duplicate refund ", Operator: "ops"})
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(700), report.Wallet.Balance)
+	assert.Equal(t, "completed", report.Transaction.Status)
+	assert.Equal(t, "duplicate refund", *report.Transaction.Reason)
+	assert.Equal(t, "admin:ops", report.Transaction.RequestID)
+
+	// The adjustment keeps the wallet consistent with its transactions
+	inspected, err := svc.InspectWallet(ctx, "wallet123")
+	require.NoError(t, err)
+	assert.Equal(t, inspected.Wallet.Balance, inspected.Expected)
+
+	discrepancies, err := store.Wallets().CheckBalances(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, discrepancies)
+}
+
+func TestAdjust_Rejects(t *testing.T) {
+	svc, _ := newTestService(nil)
+	ctx := context.Background()
+
+	_, err := svc.Adjust(ctx, Adjustment{WalletID: "wallet123", Type: "credit", Amount: 300, Reason: "  "})
+	assert.EqualError(t, err, "a reason is required")
+
+	_, err = svc.Adjust(ctx, Adjustment{WalletID: "wallet123", Type: "credit", Amount: 0, Reason: "fix"})
+	assert.EqualError(t, err, "amount must be positive")
+
+	_, err = svc.Adjust(ctx, Adjustment{WalletID: "wallet123", Type: "debit", Amount: 5000, Reason: "fix"})
+	assert.ErrorIs(t, err, utils.ErrInsufficientFunds)
+
+	_, err = svc.Adjust(ctx, Adjustment{WalletID: "missing", Type: "credit", Amount: 300, Reason: "fix"})
+	assert.ErrorIs(t, err, utils.ErrNotFound)
+}
+
+func TestRequestAdjustment_HoldsForApproval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	approvalRepo := approval.NewMockRepository(ctrl)
+	svc, store := newTestServiceWithApprovals(nil, approvalRepo)
+	ctx := context.Background()
+
+	approvalRepo.EXPECT().Create(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, a *approval.Approval) (*approval.Approval, error) {
+			assert.Equal(t, approval.KindAdjustment, a.Kind)
+			assert.Equal(t, "ops", a.Maker)
+			assert.Equal(t, int64(300), a.Amount)
+			return a, nil
+		})
+
+	// The adjustment is made by the authenticated operator, not the one it
+	// names
+	a, err := svc.RequestAdjustment(utils.WithOperator(ctx, "ops"), Adjustment{WalletID: "wallet123", Type: "debit", Amount: 300, Reason: "duplicate refund", Operator: "bob"})
+
+	require.NoError(t, err)
+	assert.Equal(t, approval.StatusPending, a.Status)
+
+	// The wallet is untouched until the adjustment is approved
+	w, err := store.Wallets().GetWalletByID(ctx, "wallet123")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), w.Balance)
+
+	_, err = svc.RequestAdjustment(ctx, Adjustment{WalletID: "wallet123", Type: "debit", Amount: 300, Reason: "fix", Operator: "ops"})
+	assert.ErrorIs(t, err, utils.ErrUnauthorized)
+
+	_, err = svc.RequestAdjustment(utils.WithOperator(ctx, "ops"), Adjustment{WalletID: "missing", Type: "credit", Amount: 300, Reason: "fix"})
+	assert.ErrorIs(t, err, utils.ErrNotFound)
+}
+
+func TestSetFrozen(t *testing.T) {
+	svc, _ := newTestService(nil)
+	ctx := context.Background()
+
+	w, err := svc.SetFrozen(ctx, "wallet123", true)
+	require.NoError(t, err)
+	assert.Equal(t, "frozen", w.Status)
+
+	// Frozen wallets can still be adjusted
+	_, err = svc.Adjust(ctx, Adjustment{WalletID: "wallet123", Type: "credit", Amount: 100, Reason: "goodwill"})
+	require.NoError(t, err)
+
+	w, err = svc.SetFrozen(ctx, "wallet123", false)
+	require.NoError(t, err)
+	assert.Equal(t, "active", w.Status)
+}
+
+func TestInspectUser(t *testing.T) {
+	svc, _ := newTestService(nil)
+
+	report, err := svc.InspectUser(context.Background(), "user123")
+
+	require.NoError(t, err)
+	assert.Equal(t, "user123", report.User.ID)
+	assert.Equal(t, "wallet123", report.Wallet.ID)
+}
+
+func TestRetryPending(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	transactions := transactionsservice.NewMockService(ctrl)
+	svc, store := newTestService(transactions)
+	ctx := context.Background()
+
+	stuck := fixtures.Transaction("user123").At(time.Now().Add(-time.Hour)).Build()
+	recent := fixtures.Transaction("user123").Build()
+	store.PutTransaction(stuck)
+	store.PutTransaction(recent)
+
+	// A dry run only lists the stuck transaction
+	results, err := svc.RetryPending(ctx, time.Now().Add(-15*time.Minute), true)
+	require.NoError(t, err)
+	assert.Equal(t, []RetryResult{{TransactionID: stuck.ID, Reference: stuck.Reference, Status: "pending"}}, results)
+
+	transactions.EXPECT().RetryTransaction(gomock.Any(), stuck.ID).DoAndReturn(func(ctx context.Context, id string) (transactionsservice.TransactionResponse, error) {
+		_, err := store.Transactions().UpdateTransactionToFailed(ctx, id)
+		return transactionsservice.TransactionResponse{}, err
+	})
+
+	results, err = svc.RetryPending(ctx, time.Now().Add(-15*time.Minute), false)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "failed", results[0].Status)
+}
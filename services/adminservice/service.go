@@ -0,0 +1,64 @@
+package adminservice
+
+import (
+	"context"
+	"log/slog"
+	"p-system/repositories/approval"
+	"p-system/repositories/transaction"
+	"p-system/repositories/unitofwork"
+	"p-system/repositories/user"
+	"p-system/repositories/wallet"
+	"p-system/services/transactionsservice"
+	"time"
+)
+
+type service struct {
+	userRepo        user.Repository
+	walletRepo      wallet.Repository
+	transactionRepo transaction.Repository
+	// uow records adjustments together with the wallet update.
+	uow unitofwork.UnitOfWork
+	// transactions completes stuck pending transactions.
+	transactions transactionsservice.Service
+	// approvalRepo holds adjustments until they are approved.
+	approvalRepo approval.Repository
+	logger       *slog.Logger
+}
+
+// Service carries out the operations support staff would otherwise do by
+// hand in SQL.
+//
+//go:generate mockgen --source=service.go -destination=service_mock.go -package=adminservice Service
+type Service interface {
+	// InspectUser returns a user and their wallet.
+	InspectUser(ctx context.Context, id string) (*UserReport, error)
+	// InspectWallet returns a wallet and the balance its completed
+	// transactions add up to.
+	InspectWallet(ctx context.Context, id string) (*WalletReport, error)
+	// InspectTransaction returns a transaction and its status history.
+	InspectTransaction(ctx context.Context, id string) (*TransactionReport, error)
+	// RequestAdjustment holds an adjustment, made by the operator carried by
+	// ctx, until someone other than them approves it, when it is made with
+	// Adjust.
+	RequestAdjustment(ctx context.Context, adjustment Adjustment) (*approval.Approval, error)
+	// Adjust credits or debits a wallet by hand, recording the reason on a
+	// completed adjustment transaction.
+	Adjust(ctx context.Context, adjustment Adjustment) (*AdjustmentReport, error)
+	// SetFrozen freezes or unfreezes a wallet.
+	SetFrozen(ctx context.Context, walletID string, frozen bool) (*wallet.Wallet, error)
+	// RetryPending completes the transactions still pending that were created
+	// before the given time, or only lists them on a dry run.
+	RetryPending(ctx context.Context, before time.Time, dryRun bool) ([]RetryResult, error)
+}
+
+func NewService(userRepo user.Repository, walletRepo wallet.Repository, transactionRepo transaction.Repository, uow unitofwork.UnitOfWork, transactions transactionsservice.Service, approvalRepo approval.Repository, logger *slog.Logger) Service {
+	return &service{
+		userRepo:        userRepo,
+		walletRepo:      walletRepo,
+		transactionRepo: transactionRepo,
+		uow:             uow,
+		transactions:    transactions,
+		approvalRepo:    approvalRepo,
+		logger:          logger,
+	}
+}
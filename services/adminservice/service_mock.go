@@ -0,0 +1,143 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: service.go
+
+// Package adminservice is a generated GoMock package.
+package adminservice
+
+import (
+	context "context"
+	approval "p-system/repositories/approval"
+	wallet "p-system/repositories/wallet"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockService is a mock of Service interface.
+type MockService struct {
+	ctrl     *gomock.Controller
+	recorder *MockServiceMockRecorder
+}
+
+// MockServiceMockRecorder is the mock recorder for MockService.
+type MockServiceMockRecorder struct {
+	mock *MockService
+}
+
+// NewMockService creates a new mock instance.
+func NewMockService(ctrl *gomock.Controller) *MockService {
+	mock := &MockService{ctrl: ctrl}
+	mock.recorder = &MockServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockService) EXPECT() *MockServiceMockRecorder {
+	return m.recorder
+}
+
+// Adjust mocks base method.
+func (m *MockService) Adjust(ctx context.Context, adjustment Adjustment) (*AdjustmentReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Adjust", ctx, adjustment)
+	ret0, _ := ret[0].(*AdjustmentReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Adjust indicates an expected call of Adjust.
+func (mr *MockServiceMockRecorder) Adjust(ctx, adjustment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Adjust", reflect.TypeOf((*MockService)(nil).Adjust), ctx, adjustment)
+}
+
+// InspectTransaction mocks base method.
+func (m *MockService) InspectTransaction(ctx context.Context, id string) (*TransactionReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InspectTransaction", ctx, id)
+	ret0, _ := ret[0].(*TransactionReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InspectTransaction indicates an expected call of InspectTransaction.
+func (mr *MockServiceMockRecorder) InspectTransaction(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InspectTransaction", reflect.TypeOf((*MockService)(nil).InspectTransaction), ctx, id)
+}
+
+// InspectUser mocks base method.
+func (m *MockService) InspectUser(ctx context.Context, id string) (*UserReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InspectUser", ctx, id)
+	ret0, _ := ret[0].(*UserReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InspectUser indicates an expected call of InspectUser.
+func (mr *MockServiceMockRecorder) InspectUser(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InspectUser", reflect.TypeOf((*MockService)(nil).InspectUser), ctx, id)
+}
+
+// InspectWallet mocks base method.
+func (m *MockService) InspectWallet(ctx context.Context, id string) (*WalletReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InspectWallet", ctx, id)
+	ret0, _ := ret[0].(*WalletReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InspectWallet indicates an expected call of InspectWallet.
+func (mr *MockServiceMockRecorder) InspectWallet(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InspectWallet", reflect.TypeOf((*MockService)(nil).InspectWallet), ctx, id)
+}
+
+// RequestAdjustment mocks base method.
+func (m *MockService) RequestAdjustment(ctx context.Context, adjustment Adjustment) (*approval.Approval, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestAdjustment", ctx, adjustment)
+	ret0, _ := ret[0].(*approval.Approval)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequestAdjustment indicates an expected call of RequestAdjustment.
+func (mr *MockServiceMockRecorder) RequestAdjustment(ctx, adjustment interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestAdjustment", reflect.TypeOf((*MockService)(nil).RequestAdjustment), ctx, adjustment)
+}
+
+// RetryPending mocks base method.
+func (m *MockService) RetryPending(ctx context.Context, before time.Time, dryRun bool) ([]RetryResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryPending", ctx, before, dryRun)
+	ret0, _ := ret[0].([]RetryResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RetryPending indicates an expected call of RetryPending.
+func (mr *MockServiceMockRecorder) RetryPending(ctx, before, dryRun interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryPending", reflect.TypeOf((*MockService)(nil).RetryPending), ctx, before, dryRun)
+}
+
+// SetFrozen mocks base method.
+func (m *MockService) SetFrozen(ctx context.Context, walletID string, frozen bool) (*wallet.Wallet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetFrozen", ctx, walletID, frozen)
+	ret0, _ := ret[0].(*wallet.Wallet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetFrozen indicates an expected call of SetFrozen.
+func (mr *MockServiceMockRecorder) SetFrozen(ctx, walletID, frozen interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetFrozen", reflect.TypeOf((*MockService)(nil).SetFrozen), ctx, walletID, frozen)
+}
@@ -0,0 +1,403 @@
+// Package approvalsservice implements maker-checker approval of transactions
+// above the approval threshold and of manual adjustments: someone other than
+// whoever made a request approves or rejects it, and only approved requests
+// are run. Every step is kept in the audit trail of the approval.
+package approvalsservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"p-system/logging"
+	"p-system/repositories/approval"
+	"p-system/services/adminservice"
+	"p-system/services/transactionsservice"
+	"p-system/utils"
+	"time"
+)
+
+// ApprovalDetail is an approval together with its audit trail.
+type ApprovalDetail struct {
+	approval.Approval
+	Events []approval.Event `json:"events"`
+}
+
+type RejectRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// errDecidedByMaker is returned when a maker tries to approve or reject their
+// own request.
+var errDecidedByMaker = utils.Forbidden("an approval cannot be decided by its maker")
+
+// Approve approves a pending approval and runs the request it holds. Whether
+// running it succeeded or not, the approval is returned with the outcome.
+func (s service) Approve(ctx context.Context, id, checker string) (*approval.Approval, error) {
+	a, err := s.approvalRepo.GetApprovalByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a.Maker == checker {
+		return nil, errDecidedByMaker
+	}
+
+	// Only one checker gets to approve it, so it runs once
+	a, err = s.approvalRepo.Decide(ctx, id, approval.StatusApproved, checker, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.InfoContext(ctx, "approved request", "approval_id", id, "kind", a.Kind, "maker", a.Maker, "checker", checker)
+
+	// Whatever the outcome, it is recorded even if the checker has gone away
+	// in the meantime
+	return s.execute(context.WithoutCancel(ctx), *a)
+}
+
+// execute runs the request of an approved approval and records how it went.
+func (s service) execute(ctx context.Context, a approval.Approval) (*approval.Approval, error) {
+	status := approval.StatusExecuted
+	note, err := s.run(ctx, a)
+	if err != nil {
+		s.logger.WarnContext(ctx, "running approved request", "approval_id", a.ID, "error", err)
+		status = approval.StatusFailed
+		note = failureNote(err)
+	}
+
+	return s.approvalRepo.Complete(ctx, a.ID, status, &note)
+}
+
+// Resume runs the approvals approved before the given time that never got to
+// their outcome, as when the process stopped between approving and running
+// one. A request that was made in the meantime is not made again; only its
+// outcome is recorded. The approvals are returned with their outcome.
+func (s service) Resume(ctx context.Context, before time.Time) ([]approval.Approval, error) {
+	approved, err := s.approvalRepo.GetApprovals(ctx, approval.StatusApproved)
+	if err != nil {
+		return nil, err
+	}
+
+	resumed := []approval.Approval{}
+	for _, a := range approved {
+		// Approvals decided since may still be running
+		if a.DecidedAt == nil || !a.DecidedAt.Before(before) {
+			continue
+		}
+
+		done, err := s.resume(ctx, a)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "resuming approval", "approval_id", a.ID, "error", err)
+			continue
+		}
+		s.logger.InfoContext(ctx, "resumed approval", "approval_id", a.ID, "status", done.Status)
+		resumed = append(resumed, *done)
+	}
+
+	return resumed, nil
+}
+
+// resume records the outcome of the request of a if it was already made, and
+// runs it otherwise.
+func (s service) resume(ctx context.Context, a approval.Approval) (*approval.Approval, error) {
+	if a.Kind == approval.KindTransfer {
+		return s.resumeTransfer(ctx, a)
+	}
+
+	reference, err := madeReference(a)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := s.transactionRepo.GetTransactionByReference(ctx, reference)
+	if errors.Is(err, utils.ErrNotFound) {
+		return s.execute(ctx, a)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case a.Kind == approval.KindAdjustment:
+		// Adjustments are made whole or not at all
+		note := "Adjusted with transaction " + t.ID
+		return s.approvalRepo.Complete(ctx, a.ID, approval.StatusExecuted, &note)
+	case t.RequestID != requestID(a):
+		// The reference was taken by another request, so running it fails
+		// as a duplicate
+		return s.execute(ctx, a)
+	case t.Status == "completed":
+		note := "Transaction successful"
+		return s.approvalRepo.Complete(ctx, a.ID, approval.StatusExecuted, &note)
+	case t.Status == "failed":
+		note := "Transaction failed"
+		return s.approvalRepo.Complete(ctx, a.ID, approval.StatusFailed, &note)
+	}
+
+	// The transaction was recorded but never completed
+	status := approval.StatusExecuted
+	resp, err := s.transactions.ProcessTransaction(ctx, t.ID)
+	note := resp.Message
+	if err != nil {
+		status = approval.StatusFailed
+		note = failureNote(err)
+	}
+	return s.approvalRepo.Complete(ctx, a.ID, status, &note)
+}
+
+// resumeTransfer records the outcome of the transfer of a if it was already
+// made, and runs it otherwise. A transfer left with its debit but not its
+// credit has the debit reversed, as running it would have.
+func (s service) resumeTransfer(ctx context.Context, a approval.Approval) (*approval.Approval, error) {
+	var t transactionsservice.Transfer
+	if err := json.Unmarshal(a.Payload, &t); err != nil {
+		return nil, err
+	}
+
+	debit, err := s.transactionRepo.GetTransactionByReference(ctx, t.Debit.Reference)
+	if errors.Is(err, utils.ErrNotFound) {
+		return s.execute(ctx, a)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case debit.RequestID != requestID(a):
+		// The reference was taken by another request, so running it fails
+		// as a duplicate
+		return s.execute(ctx, a)
+	case debit.Status == "failed":
+		note := "Transaction failed"
+		return s.approvalRepo.Complete(ctx, a.ID, approval.StatusFailed, &note)
+	case debit.Status != "completed":
+		return nil, fmt.Errorf("debit %s of the transfer is still %s", debit.ID, debit.Status)
+	}
+
+	credit, err := s.transactionRepo.GetTransactionByReference(ctx, t.Credit.Reference)
+	if err != nil && !errors.Is(err, utils.ErrNotFound) {
+		return nil, err
+	}
+	if err == nil && credit.Status == "completed" {
+		note := "Transfer successful"
+		return s.approvalRepo.Complete(ctx, a.ID, approval.StatusExecuted, &note)
+	}
+
+	if _, err := s.transactions.ReverseTransaction(ctx, debit.ID); err != nil {
+		return nil, err
+	}
+	note := "Transfer failed, debit reversed"
+	return s.approvalRepo.Complete(ctx, a.ID, approval.StatusFailed, &note)
+}
+
+// madeReference returns the reference the request of a is made under.
+func madeReference(a approval.Approval) (string, error) {
+	if a.Kind == approval.KindAdjustment {
+		return "approval-" + a.ID, nil
+	}
+
+	var req transactionsservice.Request
+	if err := json.Unmarshal(a.Payload, &req); err != nil {
+		return "", err
+	}
+	return req.Reference, nil
+}
+
+// requestID is the request ID the transaction of a is made under, which tells
+// it apart from another transaction with the same reference.
+func requestID(a approval.Approval) string {
+	return "approval:" + a.ID
+}
+
+// Reject rejects a pending approval, keeping the reason in its trail.
+func (s service) Reject(ctx context.Context, id, checker, reason string) (*approval.Approval, error) {
+	a, err := s.approvalRepo.GetApprovalByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a.Maker == checker {
+		return nil, errDecidedByMaker
+	}
+
+	a, err = s.approvalRepo.Decide(ctx, id, approval.StatusRejected, checker, &reason)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "rejected request", "approval_id", id, "kind", a.Kind, "maker", a.Maker, "checker", checker)
+	return a, nil
+}
+
+// run makes the request a holds and returns a note on how it went.
+func (s service) run(ctx context.Context, a approval.Approval) (string, error) {
+	switch a.Kind {
+	case approval.KindTransaction:
+		var req transactionsservice.Request
+		if err := json.Unmarshal(a.Payload, &req); err != nil {
+			return "", err
+		}
+		req.ApprovalID = a.ID
+
+		resp, err := s.transactions.HandleTransactionRequest(logging.WithRequestID(ctx, requestID(a)), req)
+		if err != nil {
+			return "", err
+		}
+		return resp.Message, nil
+	case approval.KindTransfer:
+		var t transactionsservice.Transfer
+		if err := json.Unmarshal(a.Payload, &t); err != nil {
+			return "", err
+		}
+		t.Debit.ApprovalID, t.Credit.ApprovalID = a.ID, a.ID
+
+		resp, err := s.transactions.HandleTransfer(logging.WithRequestID(ctx, requestID(a)), t)
+		if err != nil {
+			return "", err
+		}
+		return resp.Message, nil
+	case approval.KindAdjustment:
+		var adjustment adminservice.Adjustment
+		if err := json.Unmarshal(a.Payload, &adjustment); err != nil {
+			return "", err
+		}
+		adjustment.Reference, _ = madeReference(a)
+
+		report, err := s.admin.Adjust(ctx, adjustment)
+		if err != nil {
+			return "", err
+		}
+		return "Adjusted with transaction " + report.Transaction.ID, nil
+	default:
+		return "", fmt.Errorf("unknown approval kind %q", a.Kind)
+	}
+}
+
+// failureNote describes why running an approved request failed without
+// giving away the details of unexpected errors, which are logged instead.
+func failureNote(err error) string {
+	var domainErr *utils.DomainError
+	if errors.As(err, &domainErr) {
+		return domainErr.Message
+	}
+	return "internal error"
+}
+
+// ListApprovals returns the approvals in the status given in the status query
+// parameter, or every approval if it is omitted.
+func (s service) ListApprovals(w http.ResponseWriter, r *http.Request) {
+	query := struct {
+		Status string `json:"status" validate:"omitempty,oneof=pending approved rejected executed failed"`
+	}{Status: r.URL.Query().Get("status")}
+	if err := utils.Validate(query); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	approvals, err := s.approvalRepo.GetApprovals(r.Context(), query.Status)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, approvals)
+}
+
+// GetApproval returns the approval with the id in the path and its trail.
+func (s service) GetApproval(w http.ResponseWriter, r *http.Request) {
+	id, ok := approvalID(w, r)
+	if !ok {
+		return
+	}
+
+	a, err := s.approvalRepo.GetApprovalByID(r.Context(), id)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	s.respondWithDetail(w, r, a)
+}
+
+// ApproveApproval approves the approval with the id in the path on behalf of
+// the authenticated operator of the request, and runs it.
+func (s service) ApproveApproval(w http.ResponseWriter, r *http.Request) {
+	id, ok := approvalID(w, r)
+	if !ok {
+		return
+	}
+	checker, ok := operator(w, r)
+	if !ok {
+		return
+	}
+
+	a, err := s.Approve(r.Context(), id, checker)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	s.respondWithDetail(w, r, a)
+}
+
+// RejectApproval rejects the approval with the id in the path on behalf of
+// the authenticated operator of the request.
+func (s service) RejectApproval(w http.ResponseWriter, r *http.Request) {
+	id, ok := approvalID(w, r)
+	if !ok {
+		return
+	}
+	checker, ok := operator(w, r)
+	if !ok {
+		return
+	}
+
+	var req RejectRequest
+
+	//decode and validate request body
+	if err := utils.Decode(r, &req); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	a, err := s.Reject(r.Context(), id, checker, req.Reason)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	s.respondWithDetail(w, r, a)
+}
+
+// approvalID returns the id in the path, responding with an error if it is
+// not a UUID.
+func approvalID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id, err := utils.PathID(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return "", false
+	}
+	return id, true
+}
+
+// operator returns the authenticated operator deciding an approval,
+// responding with an error if no operator is. Nobody else decides approvals.
+func operator(w http.ResponseWriter, r *http.Request) (string, bool) {
+	checker := utils.OperatorFrom(r.Context())
+	if checker == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		utils.RespondError(w, utils.Unauthorized("an operator token is required to decide approvals"))
+		return "", false
+	}
+	return checker, true
+}
+
+// respondWithDetail sends a with its trail.
+func (s service) respondWithDetail(w http.ResponseWriter, r *http.Request, a *approval.Approval) {
+	events, err := s.approvalRepo.GetEvents(r.Context(), a.ID)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, ApprovalDetail{Approval: *a, Events: events})
+}
@@ -0,0 +1,297 @@
+package approvalsservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"p-system/logging"
+	"p-system/repositories/approval"
+	"p-system/repositories/transaction"
+	"p-system/services/adminservice"
+	"p-system/services/transactionsservice"
+	"p-system/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const approvalID1 = "d164e69d-26f5-448d-a18c-baeae517d9a1"
+
+// pending returns a pending approval of the given kind made by alice.
+func pending(t *testing.T, kind string, payload interface{}) *approval.Approval {
+	a, err := approval.NewApproval(kind, "alice", 500000, payload)
+	require.NoError(t, err)
+	a.ID = approvalID1
+	return a
+}
+
+func decided(a *approval.Approval, status, checker string) *approval.Approval {
+	d := *a
+	d.Status = status
+	d.Checker = &checker
+	return &d
+}
+
+func TestApprove_RunsTransaction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	approvalRepo := approval.NewMockRepository(ctrl)
+	transactions := transactionsservice.NewMockService(ctrl)
+	svc := NewService(approvalRepo, nil, transactions, nil, logging.Discard())
+
+	req := transactionsservice.Request{UserID: "user123", Type: "debit", Amount: 5000}
+	a := pending(t, approval.KindTransaction, req)
+	approved := decided(a, approval.StatusApproved, "bob")
+	executed := decided(a, approval.StatusExecuted, "bob")
+	// It is made as approved, rather than held again
+	made := req
+	made.ApprovalID = a.ID
+
+	approvalRepo.EXPECT().GetApprovalByID(gomock.Any(), a.ID).Return(a, nil)
+	approvalRepo.EXPECT().Decide(gomock.Any(), a.ID, approval.StatusApproved, "bob", nil).Return(approved, nil)
+	transactions.EXPECT().HandleTransactionRequest(gomock.Any(), made).
+		DoAndReturn(func(ctx context.Context, _ transactionsservice.Request) (transactionsservice.TransactionResponse, error) {
+			// Its transaction is told apart by the request ID
+			assert.Equal(t, "approval:"+a.ID, logging.RequestID(ctx))
+			return transactionsservice.TransactionResponse{Success: true, Message: "Transaction successful"}, nil
+		})
+	approvalRepo.EXPECT().Complete(gomock.Any(), a.ID, approval.StatusExecuted, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, _ string, note *string) (*approval.Approval, error) {
+			assert.Equal(t, "Transaction successful", *note)
+			return executed, nil
+		})
+
+	got, err := svc.Approve(context.Background(), a.ID, "bob")
+
+	require.NoError(t, err)
+	assert.Equal(t, approval.StatusExecuted, got.Status)
+}
+
+func TestApprove_RecordsFailedAdjustment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	approvalRepo := approval.NewMockRepository(ctrl)
+	admin := adminservice.NewMockService(ctrl)
+	svc := NewService(approvalRepo, nil, nil, admin, logging.Discard())
+
+	adjustment := adminservice.Adjustment{WalletID: "wallet123", Type: "debit", Amount: 300, Reason: "refund", Operator: "alice"}
+	a := pending(t, approval.KindAdjustment, adjustment)
+	// It is made under the reference of its approval, so only once
+	made := adjustment
+	made.Reference = "approval-" + a.ID
+
+	approvalRepo.EXPECT().GetApprovalByID(gomock.Any(), a.ID).Return(a, nil)
+	approvalRepo.EXPECT().Decide(gomock.Any(), a.ID, approval.StatusApproved, "bob", nil).Return(decided(a, approval.StatusApproved, "bob"), nil)
+	admin.EXPECT().Adjust(gomock.Any(), made).Return(nil, utils.ErrInsufficientFunds)
+	approvalRepo.EXPECT().Complete(gomock.Any(), a.ID, approval.StatusFailed, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, _ string, note *string) (*approval.Approval, error) {
+			assert.Equal(t, utils.ErrInsufficientFunds.Error(), *note)
+			return decided(a, approval.StatusFailed, "bob"), nil
+		})
+
+	got, err := svc.Approve(context.Background(), a.ID, "bob")
+
+	require.NoError(t, err)
+	assert.Equal(t, approval.StatusFailed, got.Status)
+}
+
+func TestApprove_ByMaker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	approvalRepo := approval.NewMockRepository(ctrl)
+	svc := NewService(approvalRepo, nil, nil, nil, logging.Discard())
+
+	a := pending(t, approval.KindTransaction, transactionsservice.Request{UserID: "user123", Type: "credit", Amount: 5000})
+	approvalRepo.EXPECT().GetApprovalByID(gomock.Any(), a.ID).Return(a, nil)
+
+	_, err := svc.Approve(context.Background(), a.ID, "alice")
+
+	require.ErrorIs(t, err, utils.ErrForbidden)
+}
+
+func TestApprove_AlreadyDecided(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	approvalRepo := approval.NewMockRepository(ctrl)
+	svc := NewService(approvalRepo, nil, nil, nil, logging.Discard())
+
+	a := pending(t, approval.KindTransaction, transactionsservice.Request{UserID: "user123", Type: "credit", Amount: 5000})
+	approvalRepo.EXPECT().GetApprovalByID(gomock.Any(), a.ID).Return(a, nil)
+	approvalRepo.EXPECT().Decide(gomock.Any(), a.ID, approval.StatusApproved, "bob", nil).Return(nil, utils.Conflict("approval is rejected"))
+
+	// Nothing runs unless the approval is approved
+	_, err := svc.Approve(context.Background(), a.ID, "bob")
+
+	require.ErrorIs(t, err, utils.ErrConflict)
+}
+
+// stuck returns a approved an hour ago and never run.
+func stuck(a *approval.Approval) approval.Approval {
+	d := decided(a, approval.StatusApproved, "bob")
+	decidedAt := time.Now().Add(-time.Hour)
+	d.DecidedAt = &decidedAt
+	return *d
+}
+
+func TestResume_RunsRequestNeverMade(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	approvalRepo := approval.NewMockRepository(ctrl)
+	transactionRepo := transaction.NewMockRepository(ctrl)
+	transactions := transactionsservice.NewMockService(ctrl)
+	svc := NewService(approvalRepo, transactionRepo, transactions, nil, logging.Discard())
+
+	req := transactionsservice.Request{UserID: "user123", Type: "debit", Amount: 5000, Reference: "ref-1"}
+	a := stuck(pending(t, approval.KindTransaction, req))
+	// Decided a moment ago, so it may still be running
+	recent := stuck(pending(t, approval.KindTransaction, req))
+	recent.ID = "d164e69d-26f5-448d-a18c-baeae517d9a2"
+	now := time.Now()
+	recent.DecidedAt = &now
+
+	approvalRepo.EXPECT().GetApprovals(gomock.Any(), approval.StatusApproved).Return([]approval.Approval{a, recent}, nil)
+	transactionRepo.EXPECT().GetTransactionByReference(gomock.Any(), "ref-1").Return(nil, utils.NotFound("transaction not found"))
+	transactions.EXPECT().HandleTransactionRequest(gomock.Any(), gomock.Any()).Return(transactionsservice.TransactionResponse{Success: true, Message: "Transaction successful"}, nil)
+	approvalRepo.EXPECT().Complete(gomock.Any(), a.ID, approval.StatusExecuted, gomock.Any()).Return(decided(&a, approval.StatusExecuted, "bob"), nil)
+
+	resumed, err := svc.Resume(context.Background(), now.Add(-time.Minute))
+
+	require.NoError(t, err)
+	require.Len(t, resumed, 1)
+	assert.Equal(t, a.ID, resumed[0].ID)
+	assert.Equal(t, approval.StatusExecuted, resumed[0].Status)
+}
+
+func TestResume_RecordsRequestAlreadyMade(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	approvalRepo := approval.NewMockRepository(ctrl)
+	transactionRepo := transaction.NewMockRepository(ctrl)
+	// Nothing is made again
+	svc := NewService(approvalRepo, transactionRepo, nil, nil, logging.Discard())
+
+	req := transactionsservice.Request{UserID: "user123", Type: "debit", Amount: 5000, Reference: "ref-1"}
+	a := stuck(pending(t, approval.KindTransaction, req))
+	adjustment := stuck(pending(t, approval.KindAdjustment, adminservice.Adjustment{WalletID: "wallet123", Type: "credit", Amount: 300, Reason: "refund", Operator: "alice"}))
+	adjustment.ID = "d164e69d-26f5-448d-a18c-baeae517d9a2"
+
+	approvalRepo.EXPECT().GetApprovals(gomock.Any(), approval.StatusApproved).Return([]approval.Approval{a, adjustment}, nil)
+	transactionRepo.EXPECT().GetTransactionByReference(gomock.Any(), "ref-1").Return(&transaction.Transaction{ID: "tx1", RequestID: "approval:" + a.ID, Status: "completed"}, nil)
+	transactionRepo.EXPECT().GetTransactionByReference(gomock.Any(), "approval-"+adjustment.ID).Return(&transaction.Transaction{ID: "tx2", Status: "completed"}, nil)
+	approvalRepo.EXPECT().Complete(gomock.Any(), a.ID, approval.StatusExecuted, gomock.Any()).Return(decided(&a, approval.StatusExecuted, "bob"), nil)
+	approvalRepo.EXPECT().Complete(gomock.Any(), adjustment.ID, approval.StatusExecuted, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, _ string, note *string) (*approval.Approval, error) {
+			assert.Equal(t, "Adjusted with transaction tx2", *note)
+			return decided(&adjustment, approval.StatusExecuted, "bob"), nil
+		})
+
+	resumed, err := svc.Resume(context.Background(), time.Now())
+
+	require.NoError(t, err)
+	assert.Len(t, resumed, 2)
+}
+
+func TestResume_ReversesTransferLeftHalfMade(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	approvalRepo := approval.NewMockRepository(ctrl)
+	transactionRepo := transaction.NewMockRepository(ctrl)
+	transactions := transactionsservice.NewMockService(ctrl)
+	svc := NewService(approvalRepo, transactionRepo, transactions, nil, logging.Discard())
+
+	a := stuck(pending(t, approval.KindTransfer, transactionsservice.Transfer{
+		Debit:  transactionsservice.Request{UserID: "user123", Type: "debit", Amount: 5000, Reference: "ref-D"},
+		Credit: transactionsservice.Request{UserID: "user456", Type: "credit", Amount: 5000, Reference: "ref-C"},
+	}))
+
+	// The debit was made but the credit never was
+	approvalRepo.EXPECT().GetApprovals(gomock.Any(), approval.StatusApproved).Return([]approval.Approval{a}, nil)
+	transactionRepo.EXPECT().GetTransactionByReference(gomock.Any(), "ref-D").Return(&transaction.Transaction{ID: "tx1", RequestID: "approval:" + a.ID, Status: "completed"}, nil)
+	transactionRepo.EXPECT().GetTransactionByReference(gomock.Any(), "ref-C").Return(nil, utils.NotFound("transaction not found"))
+	transactions.EXPECT().ReverseTransaction(gomock.Any(), "tx1").Return(&transaction.Transaction{ID: "tx2"}, nil)
+	approvalRepo.EXPECT().Complete(gomock.Any(), a.ID, approval.StatusFailed, gomock.Any()).Return(decided(&a, approval.StatusFailed, "bob"), nil)
+
+	resumed, err := svc.Resume(context.Background(), time.Now())
+
+	require.NoError(t, err)
+	require.Len(t, resumed, 1)
+	assert.Equal(t, approval.StatusFailed, resumed[0].Status)
+}
+
+func TestRejectApproval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	approvalRepo := approval.NewMockRepository(ctrl)
+	svc := NewService(approvalRepo, nil, nil, nil, logging.Discard())
+
+	a := pending(t, approval.KindTransaction, transactionsservice.Request{UserID: "user123", Type: "credit", Amount: 5000})
+	rejected := decided(a, approval.StatusRejected, "bob")
+	events := []approval.Event{{Status: approval.StatusPending, Actor: "alice"}, {Status: approval.StatusRejected, Actor: "bob"}}
+
+	approvalRepo.EXPECT().GetApprovalByID(gomock.Any(), a.ID).Return(a, nil)
+	approvalRepo.EXPECT().Decide(gomock.Any(), a.ID, approval.StatusRejected, "bob", gomock.Any()).Return(rejected, nil)
+	approvalRepo.EXPECT().GetEvents(gomock.Any(), a.ID).Return(events, nil)
+
+	r := httptest.NewRequest(http.MethodPost, "/approvals/"+a.ID+"/reject", strings.NewReader(`{"reason": "not expected"}`))
+	r = r.WithContext(utils.WithOperator(r.Context(), "bob"))
+	r = mux.SetURLVars(r, map[string]string{"id": a.ID})
+	rec := httptest.NewRecorder()
+	svc.RejectApproval(rec, r)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var detail ApprovalDetail
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&detail))
+	assert.Equal(t, approval.StatusRejected, detail.Status)
+	assert.Len(t, detail.Events, 2)
+}
+
+func TestRejectApproval_Invalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		operator   string
+		body       string
+		wantStatus int
+	}{
+		// Only authenticated operators decide approvals, so makers cannot
+		// pass for someone else
+		{name: "no operator", body: `{"reason": "not expected"}`, wantStatus: http.StatusUnauthorized},
+		{name: "no reason", operator: "bob", body: `{}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Nothing is looked up for an invalid request
+			svc := NewService(nil, nil, nil, nil, logging.Discard())
+
+			r := httptest.NewRequest(http.MethodPost, "/approvals/"+approvalID1+"/reject", strings.NewReader(tt.body))
+			if tt.operator != "" {
+				r = r.WithContext(utils.WithOperator(r.Context(), tt.operator))
+			}
+			r = mux.SetURLVars(r, map[string]string{"id": approvalID1})
+			rec := httptest.NewRecorder()
+			svc.RejectApproval(rec, r)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestFailureNote(t *testing.T) {
+	assert.Equal(t, "wallet not found", failureNote(utils.NotFound("wallet not found")))
+	assert.Equal(t, "internal error", failureNote(errors.New("connection refused")))
+}
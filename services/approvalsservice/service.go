@@ -0,0 +1,50 @@
+package approvalsservice
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"p-system/repositories/approval"
+	"p-system/repositories/transaction"
+	"p-system/services/adminservice"
+	"p-system/services/transactionsservice"
+	"time"
+)
+
+type service struct {
+	approvalRepo approval.Repository
+	// transactionRepo tells which approved requests were made already.
+	transactionRepo transaction.Repository
+	// transactions makes approved transactions.
+	transactions transactionsservice.Service
+	// admin makes approved adjustments.
+	admin  adminservice.Service
+	logger *slog.Logger
+}
+
+// Service lets a checker approve or reject the requests a maker has had
+// held, and runs the approved ones.
+type Service interface {
+	// Approve approves a pending approval on behalf of checker and runs it,
+	// returning the approval with its outcome.
+	Approve(ctx context.Context, id, checker string) (*approval.Approval, error)
+	// Reject rejects a pending approval on behalf of checker.
+	Reject(ctx context.Context, id, checker, reason string) (*approval.Approval, error)
+	// Resume runs the approvals approved before the given time that never
+	// got to their outcome, returning them with it.
+	Resume(ctx context.Context, before time.Time) ([]approval.Approval, error)
+	ListApprovals(w http.ResponseWriter, r *http.Request)
+	GetApproval(w http.ResponseWriter, r *http.Request)
+	ApproveApproval(w http.ResponseWriter, r *http.Request)
+	RejectApproval(w http.ResponseWriter, r *http.Request)
+}
+
+func NewService(approvalRepo approval.Repository, transactionRepo transaction.Repository, transactions transactionsservice.Service, admin adminservice.Service, logger *slog.Logger) Service {
+	return &service{
+		approvalRepo:    approvalRepo,
+		transactionRepo: transactionRepo,
+		transactions:    transactions,
+		admin:           admin,
+		logger:          logger,
+	}
+}
@@ -0,0 +1,265 @@
+package batchesservice
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"p-system/repositories/batch"
+	"p-system/utils"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxUploadSize is the largest batch file accepted, in bytes.
+const maxUploadSize = 10 << 20
+
+// ValidationResponse lists every row that was rejected when a batch fails
+// validation. No part of a rejected batch is processed.
+type ValidationResponse struct {
+	Error string     `json:"error"`
+	Code  string     `json:"code"`
+	Rows  []RowError `json:"rows"`
+}
+
+func (s service) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	source, rows, rowErrors, err := readBatch(r)
+	if err != nil {
+		utils.RespondError(w, utils.NewRequestError(err, http.StatusBadRequest))
+		return
+	}
+
+	rowErrors, err = s.validateRows(r.Context(), rows, rowErrors)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+	if len(rowErrors) > 0 {
+		utils.SendJSONResponse(w, http.StatusBadRequest, ValidationResponse{Error: "batch validation failed", Code: utils.CodeInvalidRequest, Rows: rowErrors})
+		return
+	}
+
+	items := make([]batch.Item, len(rows))
+	for i, row := range rows {
+		// Convert amount to int64 by multiplying by 100
+		items[i] = batch.NewItem(i+1, row.UserID, row.Reference, row.Type, utils.Cents(row.Amount))
+	}
+
+	b := batch.NewBatch(source)
+	if operator := utils.OperatorFrom(r.Context()); operator != "" {
+		b.Operator = &operator
+	}
+
+	b, err = s.batchRepo.Create(b, items)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusAccepted, b)
+}
+
+func (s service) GetBatch(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.PathID(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	b, err := s.batchRepo.GetBatchByID(id)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, b)
+}
+
+func (s service) GetBatchItems(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.PathID(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	items, err := s.batchRepo.GetItemsByBatchID(id)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, items)
+}
+
+// GetBatchReport downloads the per-row result of a batch as CSV.
+func (s service) GetBatchReport(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.PathID(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	if _, err := s.batchRepo.GetBatchByID(id); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	items, err := s.batchRepo.GetItemsByBatchID(id)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"batch-%s.csv\"", id))
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeReport(w, items); err != nil {
+		s.logger.ErrorContext(r.Context(), "writing batch report", "batch_id", id, "error", err)
+	}
+}
+
+// writeReport writes one CSV line per batch item with its outcome.
+func writeReport(out io.Writer, items []batch.Item) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write([]string{"row", "user_id", "amount", "type", "reference", "status", "error"}); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		var itemErr string
+		if item.Error != nil {
+			itemErr = *item.Error
+		}
+
+		record := []string{
+			strconv.Itoa(item.RowNumber),
+			item.UserID,
+			strconv.FormatFloat(float64(item.Amount)/100, 'f', 2, 64),
+			item.Type,
+			item.Reference,
+			item.Status,
+			itemErr,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// readBatch reads the rows of a batch from a multipart upload in the "file"
+// field or from the raw request body. CSV is detected from the file extension
+// or content type; anything else is read as JSON.
+func readBatch(r *http.Request) (string, []Row, []RowError, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	body := io.Reader(r.Body)
+	isCSV := mediaType == "text/csv"
+
+	if mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("file is required: %w", err)
+		}
+		defer file.Close()
+
+		body = file
+		isCSV = strings.EqualFold(filepath.Ext(header.Filename), ".csv") || header.Header.Get("Content-Type") == "text/csv"
+	}
+
+	if isCSV {
+		rows, rowErrors, err := parseCSV(body)
+		return "csv", rows, rowErrors, err
+	}
+
+	rows, err := parseJSON(body)
+	return "json", rows, nil, err
+}
+
+// validateRows checks every row up front, including that each user exists and
+// that no reference is repeated in the batch or already used by a transaction.
+// Users and references are looked up together rather than row by row. Errors
+// already found while parsing are kept and not reported twice.
+func (s service) validateRows(ctx context.Context, rows []Row, rowErrors []RowError) ([]RowError, error) {
+	reported := map[string]bool{}
+	for _, rowErr := range rowErrors {
+		reported[fmt.Sprintf("%d/%s", rowErr.Row, rowErr.Field)] = true
+	}
+
+	// rows of each user and reference left to look up, and references
+	// repeated in the batch
+	userRows := map[string][]int{}
+	referenceRows := map[string]int{}
+	var duplicates []RowError
+
+	for i, row := range rows {
+		n := i + 1
+
+		invalid := map[string]bool{}
+		for _, rowErr := range validateRow(n, row) {
+			invalid[rowErr.Field] = true
+			if !reported[fmt.Sprintf("%d/%s", n, rowErr.Field)] {
+				rowErrors = append(rowErrors, rowErr)
+			}
+		}
+
+		if !invalid["user_id"] {
+			userRows[row.UserID] = append(userRows[row.UserID], n)
+		}
+
+		if !invalid["reference"] {
+			if first, ok := referenceRows[row.Reference]; ok {
+				duplicates = append(duplicates, RowError{Row: n, Field: "reference", Error: fmt.Sprintf("reference duplicates row %d", first)})
+				continue
+			}
+			referenceRows[row.Reference] = n
+		}
+	}
+
+	ids := make([]string, 0, len(userRows))
+	for id := range userRows {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	users, err := s.userRepo.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		delete(userRows, u.ID)
+	}
+	for _, missing := range userRows {
+		for _, n := range missing {
+			rowErrors = append(rowErrors, RowError{Row: n, Field: "user_id", Error: "user not found"})
+		}
+	}
+	rowErrors = append(rowErrors, duplicates...)
+
+	used := make([]string, 0, len(referenceRows))
+	for reference := range referenceRows {
+		used = append(used, reference)
+	}
+	sort.Strings(used)
+	transactions, err := s.transactionRepo.GetTransactionsByReferences(ctx, used)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range transactions {
+		rowErrors = append(rowErrors, RowError{Row: referenceRows[t.Reference], Field: "reference", Error: "reference already used"})
+	}
+
+	sort.SliceStable(rowErrors, func(i, j int) bool {
+		return rowErrors[i].Row < rowErrors[j].Row
+	})
+
+	return rowErrors, nil
+}
@@ -0,0 +1,212 @@
+package batchesservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"p-system/logging"
+	"p-system/repositories/approval"
+	"p-system/repositories/batch"
+	"p-system/repositories/transaction"
+	"p-system/repositories/user"
+	"p-system/services/transactionsservice"
+	"p-system/utils"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCSV(t *testing.T) {
+	file := "reference,user_id,type,amount\nref1,user123,credit,10.50\nref2,user456,debit,abc\n"
+
+	rows, rowErrors, err := parseCSV(strings.NewReader(file))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Row{
+		{UserID: "user123", Amount: 10.50, Type: "credit", Reference: "ref1"},
+		{UserID: "user456", Type: "debit", Reference: "ref2"},
+	}, rows)
+	assert.Equal(t, []RowError{{Row: 2, Field: "amount", Error: "amount must be a number"}}, rowErrors)
+}
+
+func TestParseCSV_MissingColumn(t *testing.T) {
+	_, _, err := parseCSV(strings.NewReader("user_id,amount,type\nuser123,10,credit\n"))
+
+	assert.EqualError(t, err, "invalid CSV batch: missing reference column")
+}
+
+func TestCreateBatch_CSVUpload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockBatchRepo := batch.NewMockRepository(ctrl)
+	mockUserRepo := user.NewMockRepository(ctrl)
+	mockTransactionRepo := transaction.NewMockRepository(ctrl)
+
+	// Both rows belong to the same user, which is only looked up once
+	mockUserRepo.EXPECT().GetUsersByIDs(gomock.Any(), []string{"5c0e1b7a-2d3f-4a6b-9c8d-7e6f5a4b3c2d"}).Return([]user.User{{ID: "5c0e1b7a-2d3f-4a6b-9c8d-7e6f5a4b3c2d"}}, nil)
+	mockTransactionRepo.EXPECT().GetTransactionsByReferences(gomock.Any(), []string{"pay-1", "pay-2"}).Return([]transaction.Transaction{}, nil)
+	mockBatchRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(b *batch.Batch, items []batch.Item) (*batch.Batch, error) {
+		assert.Equal(t, "csv", b.Source)
+		assert.Len(t, items, 2)
+		assert.Equal(t, int64(1050), items[0].Amount)
+		assert.Equal(t, "pay-2", items[1].Reference)
+		return &batch.Batch{ID: "batch123", Status: batch.StatusProcessing, Total: 2, Pending: 2}, nil
+	})
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "payroll.csv")
+	part.Write([]byte("user_id,amount,type,reference\n5c0e1b7a-2d3f-4a6b-9c8d-7e6f5a4b3c2d,10.50,credit,pay-1\n5c0e1b7a-2d3f-4a6b-9c8d-7e6f5a4b3c2d,200,credit,pay-2\n"))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/batches", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+
+	svc := service{batchRepo: mockBatchRepo, userRepo: mockUserRepo, transactionRepo: mockTransactionRepo}
+	svc.CreateBatch(rec, req)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"id":"batch123"`)
+}
+
+func TestCreateBatch_RejectsInvalidRows(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockBatchRepo := batch.NewMockRepository(ctrl)
+	mockUserRepo := user.NewMockRepository(ctrl)
+	mockTransactionRepo := transaction.NewMockRepository(ctrl)
+
+	mockUserRepo.EXPECT().GetUsersByIDs(gomock.Any(), []string{"0f9e8d7c-6b5a-4c3d-8e2f-1a0b9c8d7e6f", "5c0e1b7a-2d3f-4a6b-9c8d-7e6f5a4b3c2d"}).
+		Return([]user.User{{ID: "5c0e1b7a-2d3f-4a6b-9c8d-7e6f5a4b3c2d"}}, nil)
+	mockTransactionRepo.EXPECT().GetTransactionsByReferences(gomock.Any(), []string{"pay-1", "used"}).
+		Return([]transaction.Transaction{{Reference: "used"}}, nil)
+	mockBatchRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+
+	payload := `{"transactions": [
+		{"user_id": "5c0e1b7a-2d3f-4a6b-9c8d-7e6f5a4b3c2d", "amount": 10, "type": "credit", "reference": "pay-1"},
+		{"user_id": "5c0e1b7a-2d3f-4a6b-9c8d-7e6f5a4b3c2d", "amount": 0, "type": "foo", "reference": "pay-1"},
+		{"user_id": "0f9e8d7c-6b5a-4c3d-8e2f-1a0b9c8d7e6f", "amount": 5, "type": "debit", "reference": "used"}
+	]}`
+
+	req := httptest.NewRequest(http.MethodPost, "/batches", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	svc := service{batchRepo: mockBatchRepo, userRepo: mockUserRepo, transactionRepo: mockTransactionRepo}
+	svc.CreateBatch(rec, req)
+
+	var resp ValidationResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, []RowError{
+		{Row: 2, Field: "amount", Error: "amount must be greater than zero"},
+		{Row: 2, Field: "type", Error: "type must be one of credit or debit"},
+		{Row: 2, Field: "reference", Error: "reference duplicates row 1"},
+		{Row: 3, Field: "user_id", Error: "user not found"},
+		{Row: 3, Field: "reference", Error: "reference already used"},
+	}, resp.Rows)
+}
+
+func TestCreateBatch_LookupFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockBatchRepo := batch.NewMockRepository(ctrl)
+	mockUserRepo := user.NewMockRepository(ctrl)
+
+	// A user that cannot be looked up is not reported as missing
+	mockUserRepo.EXPECT().GetUsersByIDs(gomock.Any(), gomock.Any()).Return(nil, assert.AnError)
+	mockBatchRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(0)
+
+	payload := `{"transactions": [{"user_id": "5c0e1b7a-2d3f-4a6b-9c8d-7e6f5a4b3c2d", "amount": 10, "type": "credit", "reference": "pay-1"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/batches", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	svc := service{batchRepo: mockBatchRepo, userRepo: mockUserRepo}
+	svc.CreateBatch(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
+
+func TestProcessor_RecordsItemOutcome(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockBatchRepo := batch.NewMockRepository(ctrl)
+	mockTransactionRepo := transaction.NewMockRepository(ctrl)
+	mockTransactions := transactionsservice.NewMockService(ctrl)
+
+	items := []batch.Item{
+		batch.NewItem(1, "user123", "pay-1", "credit", 1050),
+		batch.NewItem(2, "user123", "pay-2", "debit", 20000),
+		batch.NewItem(3, "user123", "pay-3", "credit", 5000000),
+		// Made by a worker that stopped before saving the item
+		batch.NewItem(4, "user123", "pay-4", "credit", 1000),
+	}
+	operator := "alice"
+	items[2].Operator = &operator
+
+	mockBatchRepo.EXPECT().ProcessPending(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(limit int, fn func(*batch.Item)) (int, error) {
+			for i := range items {
+				fn(&items[i])
+			}
+			return len(items), nil
+		})
+	mockTransactions.EXPECT().HandleTransactionRequest(gomock.Any(), transactionsservice.Request{
+		Amount: 10.50, UserID: "user123", Type: "credit", Reference: "pay-1",
+	}).Return(transactionsservice.TransactionResponse{Success: true}, nil)
+	mockTransactions.EXPECT().HandleTransactionRequest(gomock.Any(), transactionsservice.Request{
+		Amount: 200, UserID: "user123", Type: "debit", Reference: "pay-2",
+	}).Return(transactionsservice.TransactionResponse{Success: false, Message: "Insufficient balance"}, utils.ErrInsufficientFunds)
+	mockTransactions.EXPECT().HandleTransactionRequest(gomock.Any(), transactionsservice.Request{
+		Amount: 50000, UserID: "user123", Type: "credit", Reference: "pay-3",
+	}).DoAndReturn(func(ctx context.Context, _ transactionsservice.Request) (transactionsservice.TransactionResponse, error) {
+		// Held in the name of the operator who submitted the batch
+		assert.Equal(t, "alice", utils.OperatorFrom(ctx))
+		return transactionsservice.TransactionResponse{Success: false, Message: "Transaction held for approval"}, &transactionsservice.HeldError{Approval: &approval.Approval{ID: "approval1"}}
+	})
+
+	mockTransactions.EXPECT().HandleTransactionRequest(gomock.Any(), transactionsservice.Request{
+		Amount: 10, UserID: "user123", Type: "credit", Reference: "pay-4",
+	}).Return(transactionsservice.TransactionResponse{Success: false, Message: "Failed to create transaction"}, utils.DuplicateReference("transaction already exists"))
+	mockTransactionRepo.EXPECT().GetTransactionByReference(gomock.Any(), "pay-4").
+		Return(&transaction.Transaction{UserID: "user123", Reference: "pay-4", Type: "credit", Amount: 1000, Status: "completed"}, nil)
+
+	n, err := NewProcessor(mockBatchRepo, mockTransactionRepo, mockTransactions, logging.Discard()).ProcessPending()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, batch.ItemStatusSucceeded, items[0].Status)
+	assert.Equal(t, batch.ItemStatusFailed, items[1].Status)
+	assert.Equal(t, "insufficient balance", *items[1].Error)
+	assert.Equal(t, batch.ItemStatusHeld, items[2].Status)
+	assert.Equal(t, "transaction is held for approval approval1", *items[2].Error)
+	assert.Equal(t, batch.ItemStatusSucceeded, items[3].Status)
+}
+
+func TestWriteReport(t *testing.T) {
+	msg := "Insufficient balance"
+	items := []batch.Item{
+		{RowNumber: 1, UserID: "user123", Amount: 1050, Type: "credit", Reference: "pay-1", Status: batch.ItemStatusSucceeded},
+		{RowNumber: 2, UserID: "user123", Amount: 20000, Type: "debit", Reference: "pay-2", Status: batch.ItemStatusFailed, Error: &msg},
+	}
+
+	var out bytes.Buffer
+	err := writeReport(&out, items)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "row,user_id,amount,type,reference,status,error\n"+
+		"1,user123,10.50,credit,pay-1,succeeded,\n"+
+		"2,user123,200.00,debit,pay-2,failed,Insufficient balance\n", out.String())
+}
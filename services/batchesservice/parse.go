@@ -0,0 +1,128 @@
+package batchesservice
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"p-system/utils"
+	"strconv"
+	"strings"
+)
+
+// maxRows is the largest number of transactions accepted in one batch.
+const maxRows = 10000
+
+// Row is a single transaction in a submitted batch file.
+type Row struct {
+	UserID    string  `json:"user_id" validate:"required,uuid"`
+	Amount    float64 `json:"amount" validate:"positive,cents"`
+	Type      string  `json:"type" validate:"required,oneof=credit debit"`
+	Reference string  `json:"reference" validate:"required,max=50,reference"`
+}
+
+// RowError describes why a row of a batch file was rejected. Rows are
+// numbered from one, excluding the CSV header.
+type RowError struct {
+	Row   int    `json:"row"`
+	Field string `json:"field,omitempty"`
+	Error string `json:"error"`
+}
+
+// csvColumns are the columns every CSV batch file must have, in any order.
+var csvColumns = []string{"user_id", "amount", "type", "reference"}
+
+// parseJSON reads a batch from a JSON document of the form
+// {"transactions": [{"user_id": ..., "amount": ..., "type": ..., "reference": ...}]}.
+func parseJSON(r io.Reader) ([]Row, error) {
+	var body struct {
+		Transactions []Row `json:"transactions"`
+	}
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid JSON batch: %w", err)
+	}
+
+	return checkSize(body.Transactions)
+}
+
+// parseCSV reads a batch from a CSV file with a header row naming the
+// user_id, amount, type and reference columns. Rows whose amount cannot be
+// parsed are reported as row errors rather than failing the whole file.
+func parseCSV(r io.Reader) ([]Row, []RowError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CSV batch: %w", err)
+	}
+
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, column := range csvColumns {
+		if _, ok := index[column]; !ok {
+			return nil, nil, fmt.Errorf("invalid CSV batch: missing %s column", column)
+		}
+	}
+
+	var rows []Row
+	var rowErrors []RowError
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid CSV batch: %w", err)
+		}
+
+		row := Row{
+			UserID:    strings.TrimSpace(record[index["user_id"]]),
+			Type:      strings.TrimSpace(record[index["type"]]),
+			Reference: strings.TrimSpace(record[index["reference"]]),
+		}
+
+		amount, err := strconv.ParseFloat(strings.TrimSpace(record[index["amount"]]), 64)
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Row: n, Field: "amount", Error: "amount must be a number"})
+		}
+		row.Amount = amount
+
+		rows = append(rows, row)
+	}
+
+	rows, err = checkSize(rows)
+	return rows, rowErrors, err
+}
+
+func checkSize(rows []Row) ([]Row, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("batch has no transactions")
+	}
+	if len(rows) > maxRows {
+		return nil, fmt.Errorf("batch has more than %d transactions", maxRows)
+	}
+
+	return rows, nil
+}
+
+// validateRow checks the fields of a single row.
+func validateRow(n int, row Row) []RowError {
+	var requestErr *utils.Error
+	if err := utils.Validate(row); !errors.As(err, &requestErr) {
+		return nil
+	}
+
+	rowErrors := make([]RowError, len(requestErr.Fields))
+	for i, field := range requestErr.Fields {
+		rowErrors[i] = RowError{Row: n, Field: field.Field, Error: field.Error}
+	}
+
+	return rowErrors
+}
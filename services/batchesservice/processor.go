@@ -0,0 +1,132 @@
+package batchesservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"p-system/repositories/batch"
+	"p-system/repositories/transaction"
+	"p-system/services/transactionsservice"
+	"p-system/utils"
+	"time"
+)
+
+// Processor runs pending batch items through the transaction service.
+type Processor struct {
+	batchRepo       batch.Repository
+	transactionRepo transaction.Repository
+	transactions    transactionsservice.Service
+	// Interval is how often the processor polls for pending items when idle.
+	Interval time.Duration
+	// BatchSize is the maximum number of items claimed per poll.
+	BatchSize int
+
+	logger *slog.Logger
+}
+
+func NewProcessor(batchRepo batch.Repository, transactionRepo transaction.Repository, transactions transactionsservice.Service, logger *slog.Logger) *Processor {
+	return &Processor{
+		batchRepo:       batchRepo,
+		transactionRepo: transactionRepo,
+		transactions:    transactions,
+		Interval:        5 * time.Second,
+		BatchSize:       100,
+		logger:          logger,
+	}
+}
+
+// Start processes pending items until ctx is cancelled. It keeps claiming
+// items without waiting while there is work left.
+func (p *Processor) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := p.ProcessPending()
+		if err != nil {
+			p.logger.ErrorContext(ctx, "processing batch items", "error", err)
+		}
+
+		if n > 0 && err == nil && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessPending processes one round of pending items and returns how many
+// were processed.
+func (p *Processor) ProcessPending() (int, error) {
+	return p.batchRepo.ProcessPending(p.BatchSize, p.process)
+}
+
+// process submits a single item, in the name of the operator of its batch,
+// and records its outcome on the item. Claimed items are processed to
+// completion even on shutdown, so they do not run under the context of Start.
+func (p *Processor) process(item *batch.Item) {
+	ctx := context.Background()
+	if item.Operator != nil {
+		ctx = utils.WithOperator(ctx, *item.Operator)
+	}
+
+	// Convert amount to float64 by dividing by 100
+	resp, err := p.transactions.HandleTransactionRequest(ctx, transactionsservice.Request{
+		Amount:    float64(item.Amount) / 100,
+		UserID:    item.UserID,
+		Type:      item.Type,
+		Reference: item.Reference,
+	})
+	// An item claimed again after its worker stopped may have been made
+	// already, under its reference
+	if errors.Is(err, utils.ErrDuplicateReference) && p.madeEarlier(item) {
+		item.Status = batch.ItemStatusSucceeded
+		item.Error = nil
+		return
+	}
+
+	// Items above the approval threshold wait for their approval, which
+	// the error names
+	var held *transactionsservice.HeldError
+	if errors.As(err, &held) {
+		msg := err.Error()
+		item.Status = batch.ItemStatusHeld
+		item.Error = &msg
+		return
+	}
+
+	// Domain errors already describe the failure; anything else is given
+	// the context of the step that failed
+	var domainErr *utils.DomainError
+	if err != nil && resp.Message != "" && !errors.As(err, &domainErr) {
+		err = fmt.Errorf("%s: %w", resp.Message, err)
+	} else if err == nil && !resp.Success {
+		err = errors.New(resp.Message)
+	}
+
+	if err != nil {
+		msg := err.Error()
+		item.Status = batch.ItemStatusFailed
+		item.Error = &msg
+		return
+	}
+
+	item.Status = batch.ItemStatusSucceeded
+	item.Error = nil
+}
+
+// madeEarlier reports whether the transaction under the reference of item was
+// completed for it, by an earlier attempt at the item.
+func (p *Processor) madeEarlier(item *batch.Item) bool {
+	t, err := p.transactionRepo.GetTransactionByReference(context.Background(), item.Reference)
+	if err != nil {
+		return false
+	}
+
+	return t.Status == "completed" && t.UserID == item.UserID && t.Type == item.Type && t.Amount == item.Amount
+}
@@ -0,0 +1,32 @@
+package batchesservice
+
+import (
+	"log/slog"
+	"net/http"
+	"p-system/repositories/batch"
+	"p-system/repositories/transaction"
+	"p-system/repositories/user"
+)
+
+type service struct {
+	batchRepo       batch.Repository
+	userRepo        user.Repository
+	transactionRepo transaction.Repository
+	logger          *slog.Logger
+}
+
+type Service interface {
+	CreateBatch(w http.ResponseWriter, r *http.Request)
+	GetBatch(w http.ResponseWriter, r *http.Request)
+	GetBatchItems(w http.ResponseWriter, r *http.Request)
+	GetBatchReport(w http.ResponseWriter, r *http.Request)
+}
+
+func NewService(batchRepo batch.Repository, userRepo user.Repository, transactionRepo transaction.Repository, logger *slog.Logger) Service {
+	return &service{
+		batchRepo:       batchRepo,
+		userRepo:        userRepo,
+		transactionRepo: transactionRepo,
+		logger:          logger,
+	}
+}
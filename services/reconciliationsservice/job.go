@@ -0,0 +1,89 @@
+package reconciliationsservice
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"p-system/utils"
+	"path/filepath"
+	"time"
+)
+
+// Job reconciles the daily settlement files the provider drops into a
+// directory. Files are named settlement-YYYY-MM-DD.csv after the day they
+// settle; each file is reconciled once.
+type Job struct {
+	reconciler Service
+	// Dir is the directory settlement files are read from.
+	Dir string
+	// Interval is how often the directory is checked for new files.
+	Interval time.Duration
+	// Lookback is how many days back files are looked for, so a file that
+	// arrives late is still picked up.
+	Lookback int
+	now      func() time.Time
+	logger   *slog.Logger
+}
+
+func NewJob(reconciler Service, dir string, logger *slog.Logger) *Job {
+	return &Job{
+		reconciler: reconciler,
+		Dir:        dir,
+		Interval:   time.Hour,
+		Lookback:   7,
+		now:        time.Now,
+		logger:     logger,
+	}
+}
+
+// Start reconciles new settlement files until ctx is cancelled.
+func (j *Job) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		j.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce reconciles every settlement file for the last Lookback days that
+// has not been reconciled yet, and returns how many it reconciled.
+func (j *Job) RunOnce(ctx context.Context) int {
+	now := j.now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	reconciled := 0
+	for days := 1; days <= j.Lookback; days++ {
+		day := today.AddDate(0, 0, -days)
+		name := "settlement-" + day.Format("2006-01-02") + ".csv"
+
+		file, err := os.Open(filepath.Join(j.Dir, name))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				j.logger.ErrorContext(ctx, "opening settlement file", "file", name, "error", err)
+			}
+			continue
+		}
+
+		_, err = j.reconciler.Reconcile(ctx, name, file, day, day.AddDate(0, 0, 1))
+		file.Close()
+		if err != nil {
+			if !errors.Is(err, utils.ErrConflict) {
+				j.logger.ErrorContext(ctx, "reconciling settlement file", "file", name, "error", err)
+			}
+			continue
+		}
+
+		j.logger.InfoContext(ctx, "reconciled settlement file", "file", name)
+		reconciled++
+	}
+
+	return reconciled
+}
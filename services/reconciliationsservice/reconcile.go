@@ -0,0 +1,139 @@
+package reconciliationsservice
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"p-system/repositories/reconciliation"
+	"p-system/repositories/transaction"
+	"p-system/utils"
+	"strconv"
+	"strings"
+)
+
+// SettlementLine is a single settled payment reported by the provider. Amount
+// is in minor units.
+type SettlementLine struct {
+	Reference string
+	Amount    int64
+}
+
+// parseSettlement reads a provider settlement file. It must be CSV with a
+// header naming at least the reference and amount columns; amounts are in
+// major units and any other columns are ignored.
+func parseSettlement(r io.Reader) ([]SettlementLine, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid settlement file: %w", err)
+	}
+
+	referenceColumn, amountColumn := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "reference":
+			referenceColumn = i
+		case "amount":
+			amountColumn = i
+		}
+	}
+	if referenceColumn < 0 || amountColumn < 0 {
+		return nil, fmt.Errorf("invalid settlement file: reference and amount columns are required")
+	}
+
+	var lines []SettlementLine
+	for n := 2; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid settlement file: %w", err)
+		}
+		if len(record) <= referenceColumn || len(record) <= amountColumn {
+			return nil, fmt.Errorf("invalid settlement file: line %d is missing columns", n)
+		}
+
+		amount, err := strconv.ParseFloat(strings.TrimSpace(record[amountColumn]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid settlement file: line %d has an invalid amount", n)
+		}
+
+		// Convert amount to int64 by multiplying by 100
+		lines = append(lines, SettlementLine{
+			Reference: strings.TrimSpace(record[referenceColumn]),
+			Amount:    utils.Cents(amount),
+		})
+	}
+
+	return lines, nil
+}
+
+// match compares settlement lines with our transactions. known holds every
+// transaction whose reference appears in the file, whatever its status;
+// completed holds the transactions completed over the settlement period.
+func match(lines []SettlementLine, known []transaction.Transaction, completed []transaction.Transaction) []reconciliation.Item {
+	byReference := make(map[string]transaction.Transaction, len(known))
+	for _, t := range known {
+		byReference[t.Reference] = t
+	}
+
+	items := make([]reconciliation.Item, 0, len(lines))
+	settled := make(map[string]bool, len(lines))
+
+	for _, line := range lines {
+		providerAmount := line.Amount
+		item := reconciliation.Item{
+			Reference:      line.Reference,
+			ProviderAmount: &providerAmount,
+		}
+
+		t, found := byReference[line.Reference]
+		switch {
+		case settled[line.Reference]:
+			item.Category = reconciliation.CategoryMissingOnOurSide
+			item.Note = note("reference appears more than once in the settlement file")
+		case !found:
+			item.Category = reconciliation.CategoryMissingOnOurSide
+		case t.Status != "completed":
+			item.Category = reconciliation.CategoryMissingOnOurSide
+			item.Note = note("transaction is " + t.Status)
+		case t.Amount != line.Amount:
+			item.Category = reconciliation.CategoryAmountMismatch
+		default:
+			item.Category = reconciliation.CategoryMatched
+		}
+
+		if found {
+			id, amount := t.ID, t.Amount
+			item.TransactionID = &id
+			item.OurAmount = &amount
+		}
+
+		settled[line.Reference] = true
+		items = append(items, item)
+	}
+
+	for _, t := range completed {
+		if settled[t.Reference] {
+			continue
+		}
+
+		id, amount := t.ID, t.Amount
+		items = append(items, reconciliation.Item{
+			Category:      reconciliation.CategoryMissingOnProviderSide,
+			Reference:     t.Reference,
+			TransactionID: &id,
+			OurAmount:     &amount,
+		})
+	}
+
+	return items
+}
+
+func note(s string) *string {
+	return &s
+}
@@ -0,0 +1,152 @@
+package reconciliationsservice
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"p-system/repositories/reconciliation"
+	"p-system/utils"
+	"time"
+)
+
+// maxUploadSize is the largest settlement file accepted, in bytes.
+const maxUploadSize = 32 << 20
+
+// Reconcile compares a settlement file covering [from, to) with our
+// transactions and saves the result for review.
+func (s service) Reconcile(ctx context.Context, fileName string, file io.Reader, from, to time.Time) (*reconciliation.Reconciliation, error) {
+	lines, err := parseSettlement(file)
+	if err != nil {
+		return nil, utils.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	references := make([]string, len(lines))
+	for i, line := range lines {
+		references[i] = line.Reference
+	}
+
+	known, err := s.transactionRepo.GetTransactionsByReferences(ctx, references)
+	if err != nil {
+		return nil, err
+	}
+
+	completed, err := s.transactionRepo.GetCompletedTransactionsBetween(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	items := match(lines, known, completed)
+
+	return s.reconciliationRepo.Create(reconciliation.NewReconciliation(fileName, from, to, items), items)
+}
+
+// CreateReconciliation reconciles a settlement file uploaded in the "file"
+// field. The settlement period is the day given in the date field, or the
+// from and to fields as RFC 3339 timestamps.
+func (s service) CreateReconciliation(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		utils.RespondError(w, utils.NewRequestError(errors.New("file is required"), http.StatusBadRequest))
+		return
+	}
+	defer file.Close()
+
+	from, to, err := parsePeriod(r.FormValue("date"), r.FormValue("from"), r.FormValue("to"))
+	if err != nil {
+		utils.RespondError(w, utils.NewRequestError(err, http.StatusBadRequest))
+		return
+	}
+
+	rec, err := s.Reconcile(r.Context(), header.Filename, file, from, to)
+	if err != nil {
+		if errors.Is(err, utils.ErrConflict) {
+			err = utils.Conflict("file has already been reconciled")
+		}
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusCreated, rec)
+}
+
+func (s service) ListReconciliations(w http.ResponseWriter, r *http.Request) {
+	reconciliations, err := s.reconciliationRepo.GetReconciliations(100)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, reconciliations)
+}
+
+func (s service) GetReconciliation(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.PathID(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	rec, err := s.reconciliationRepo.GetReconciliationByID(id)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, rec)
+}
+
+// GetReconciliationItems lists the items of a reconciliation, filtered by the
+// optional category query parameter.
+func (s service) GetReconciliationItems(w http.ResponseWriter, r *http.Request) {
+	category := r.URL.Query().Get("category")
+	switch category {
+	case "", reconciliation.CategoryMatched, reconciliation.CategoryMissingOnOurSide,
+		reconciliation.CategoryMissingOnProviderSide, reconciliation.CategoryAmountMismatch:
+	default:
+		utils.RespondError(w, utils.NewRequestError(errors.New("unknown category"), http.StatusBadRequest))
+		return
+	}
+
+	id, err := utils.PathID(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	items, err := s.reconciliationRepo.GetItems(id, category)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, items)
+}
+
+// parsePeriod returns the settlement period for a date (the whole day) or for
+// explicit RFC 3339 bounds.
+func parsePeriod(date, fromValue, toValue string) (time.Time, time.Time, error) {
+	if date != "" {
+		day, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("date must be in the form YYYY-MM-DD")
+		}
+		return day, day.AddDate(0, 0, 1), nil
+	}
+
+	from, err := time.Parse(time.RFC3339, fromValue)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("date, or from and to as RFC 3339 timestamps, are required")
+	}
+	to, err := time.Parse(time.RFC3339, toValue)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("date, or from and to as RFC 3339 timestamps, are required")
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, errors.New("to must be after from")
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,119 @@
+package reconciliationsservice
+
+import (
+	"context"
+	"os"
+	"p-system/logging"
+	"p-system/repositories/reconciliation"
+	"p-system/repositories/transaction"
+	"p-system/utils"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSettlement(t *testing.T) {
+	file := "settled_at,reference,amount,currency\n2024-05-01,ref1,10.50,NGN\n2024-05-01,ref2,200,NGN\n"
+
+	lines, err := parseSettlement(strings.NewReader(file))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []SettlementLine{{Reference: "ref1", Amount: 1050}, {Reference: "ref2", Amount: 20000}}, lines)
+}
+
+func TestParseSettlement_InvalidAmount(t *testing.T) {
+	_, err := parseSettlement(strings.NewReader("reference,amount\nref1,ten\n"))
+
+	assert.EqualError(t, err, "invalid settlement file: line 2 has an invalid amount")
+}
+
+func TestMatch(t *testing.T) {
+	lines := []SettlementLine{
+		{Reference: "matched", Amount: 1000},
+		{Reference: "mismatch", Amount: 1500},
+		{Reference: "failed", Amount: 700},
+		{Reference: "unknown", Amount: 300},
+		{Reference: "matched", Amount: 1000},
+	}
+	known := []transaction.Transaction{
+		{ID: "t1", Reference: "matched", Amount: 1000, Status: "completed"},
+		{ID: "t2", Reference: "mismatch", Amount: 1000, Status: "completed"},
+		{ID: "t3", Reference: "failed", Amount: 700, Status: "failed"},
+	}
+	completed := []transaction.Transaction{
+		known[0],
+		known[1],
+		{ID: "t4", Reference: "unsettled", Amount: 400, Status: "completed"},
+	}
+
+	items := match(lines, known, completed)
+
+	categories := map[string][]string{}
+	for _, item := range items {
+		categories[item.Category] = append(categories[item.Category], item.Reference)
+	}
+
+	assert.Equal(t, map[string][]string{
+		reconciliation.CategoryMatched:               {"matched"},
+		reconciliation.CategoryAmountMismatch:        {"mismatch"},
+		reconciliation.CategoryMissingOnOurSide:      {"failed", "unknown", "matched"},
+		reconciliation.CategoryMissingOnProviderSide: {"unsettled"},
+	}, categories)
+	assert.Equal(t, "transaction is failed", *items[2].Note)
+	assert.Equal(t, int64(1000), *items[1].OurAmount)
+	assert.Equal(t, int64(1500), *items[1].ProviderAmount)
+}
+
+func TestReconcile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockReconciliationRepo := reconciliation.NewMockRepository(ctrl)
+	mockTransactionRepo := transaction.NewMockRepository(ctrl)
+
+	from := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+
+	mockTransactionRepo.EXPECT().GetTransactionsByReferences(gomock.Any(), []string{"ref1"}).
+		Return([]transaction.Transaction{{ID: "t1", Reference: "ref1", Amount: 1050, Status: "completed"}}, nil)
+	mockTransactionRepo.EXPECT().GetCompletedTransactionsBetween(gomock.Any(), from, to).
+		Return([]transaction.Transaction{{ID: "t1", Reference: "ref1", Amount: 1050, Status: "completed"}}, nil)
+	mockReconciliationRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(r *reconciliation.Reconciliation, items []reconciliation.Item) (*reconciliation.Reconciliation, error) {
+			assert.Equal(t, "settlement.csv", r.FileName)
+			assert.Equal(t, 1, r.Matched)
+			assert.Len(t, items, 1)
+			return r, nil
+		})
+
+	svc := service{reconciliationRepo: mockReconciliationRepo, transactionRepo: mockTransactionRepo}
+	rec, err := svc.Reconcile(context.Background(), "settlement.csv", strings.NewReader("reference,amount\nref1,10.50\n"), from, to)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, rec.Matched)
+}
+
+func TestJob_RunOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "settlement-2024-05-01.csv"), []byte("reference,amount\n"), 0o600)
+	os.WriteFile(filepath.Join(dir, "settlement-2024-04-30.csv"), []byte("reference,amount\n"), 0o600)
+
+	mockReconciler := NewMockService(ctrl)
+	day := time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	mockReconciler.EXPECT().Reconcile(gomock.Any(), "settlement-2024-05-01.csv", gomock.Any(), day, day.AddDate(0, 0, 1)).
+		Return(&reconciliation.Reconciliation{}, nil)
+	mockReconciler.EXPECT().Reconcile(gomock.Any(), "settlement-2024-04-30.csv", gomock.Any(), day.AddDate(0, 0, -1), day).
+		Return(nil, utils.Conflict("reconciliation already exists"))
+
+	job := NewJob(mockReconciler, dir, logging.Discard())
+	job.now = func() time.Time { return time.Date(2024, time.May, 2, 6, 0, 0, 0, time.UTC) }
+
+	assert.Equal(t, 1, job.RunOnce(context.Background()))
+}
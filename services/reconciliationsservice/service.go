@@ -0,0 +1,31 @@
+package reconciliationsservice
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"p-system/repositories/reconciliation"
+	"p-system/repositories/transaction"
+	"time"
+)
+
+type service struct {
+	reconciliationRepo reconciliation.Repository
+	transactionRepo    transaction.Repository
+}
+
+//go:generate mockgen --source=service.go -destination=service_mock.go -package=reconciliationsservice Service
+type Service interface {
+	CreateReconciliation(w http.ResponseWriter, r *http.Request)
+	ListReconciliations(w http.ResponseWriter, r *http.Request)
+	GetReconciliation(w http.ResponseWriter, r *http.Request)
+	GetReconciliationItems(w http.ResponseWriter, r *http.Request)
+	Reconcile(ctx context.Context, fileName string, file io.Reader, from, to time.Time) (*reconciliation.Reconciliation, error)
+}
+
+func NewService(reconciliationRepo reconciliation.Repository, transactionRepo transaction.Repository) Service {
+	return &service{
+		reconciliationRepo: reconciliationRepo,
+		transactionRepo:    transactionRepo,
+	}
+}
@@ -0,0 +1,102 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: service.go
+
+// Package reconciliationsservice is a generated GoMock package.
+package reconciliationsservice
+
+import (
+	context "context"
+	io "io"
+	http "net/http"
+	reconciliation "p-system/repositories/reconciliation"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockService is a mock of Service interface.
+type MockService struct {
+	ctrl     *gomock.Controller
+	recorder *MockServiceMockRecorder
+}
+
+// MockServiceMockRecorder is the mock recorder for MockService.
+type MockServiceMockRecorder struct {
+	mock *MockService
+}
+
+// NewMockService creates a new mock instance.
+func NewMockService(ctrl *gomock.Controller) *MockService {
+	mock := &MockService{ctrl: ctrl}
+	mock.recorder = &MockServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockService) EXPECT() *MockServiceMockRecorder {
+	return m.recorder
+}
+
+// CreateReconciliation mocks base method.
+func (m *MockService) CreateReconciliation(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "CreateReconciliation", w, r)
+}
+
+// CreateReconciliation indicates an expected call of CreateReconciliation.
+func (mr *MockServiceMockRecorder) CreateReconciliation(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateReconciliation", reflect.TypeOf((*MockService)(nil).CreateReconciliation), w, r)
+}
+
+// GetReconciliation mocks base method.
+func (m *MockService) GetReconciliation(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "GetReconciliation", w, r)
+}
+
+// GetReconciliation indicates an expected call of GetReconciliation.
+func (mr *MockServiceMockRecorder) GetReconciliation(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReconciliation", reflect.TypeOf((*MockService)(nil).GetReconciliation), w, r)
+}
+
+// GetReconciliationItems mocks base method.
+func (m *MockService) GetReconciliationItems(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "GetReconciliationItems", w, r)
+}
+
+// GetReconciliationItems indicates an expected call of GetReconciliationItems.
+func (mr *MockServiceMockRecorder) GetReconciliationItems(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReconciliationItems", reflect.TypeOf((*MockService)(nil).GetReconciliationItems), w, r)
+}
+
+// ListReconciliations mocks base method.
+func (m *MockService) ListReconciliations(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ListReconciliations", w, r)
+}
+
+// ListReconciliations indicates an expected call of ListReconciliations.
+func (mr *MockServiceMockRecorder) ListReconciliations(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListReconciliations", reflect.TypeOf((*MockService)(nil).ListReconciliations), w, r)
+}
+
+// Reconcile mocks base method.
+func (m *MockService) Reconcile(ctx context.Context, fileName string, file io.Reader, from, to time.Time) (*reconciliation.Reconciliation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reconcile", ctx, fileName, file, from, to)
+	ret0, _ := ret[0].(*reconciliation.Reconciliation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reconcile indicates an expected call of Reconcile.
+func (mr *MockServiceMockRecorder) Reconcile(ctx, fileName, file, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reconcile", reflect.TypeOf((*MockService)(nil).Reconcile), ctx, fileName, file, from, to)
+}
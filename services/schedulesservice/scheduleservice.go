@@ -0,0 +1,197 @@
+package schedulesservice
+
+import (
+	"errors"
+	"net/http"
+	"p-system/repositories/schedule"
+	"p-system/utils"
+	"time"
+)
+
+type ScheduleRequest struct {
+	UserID string `json:"user_id" validate:"required,uuid"`
+	//counterparty is required for transfers and receives the credit leg
+	CounterpartyUserID string  `json:"counterparty_user_id,omitempty" validate:"omitempty,uuid"`
+	Amount             float64 `json:"amount" validate:"positive,cents"`
+	//type required with one of credit, debit or transfer
+	Type string `json:"type" validate:"required,oneof=credit debit transfer"`
+	//reference prefixes the reference of every transaction the schedule creates
+	Reference   string     `json:"reference" validate:"required,max=32,reference"`
+	Frequency   string     `json:"frequency" validate:"required,oneof=once daily weekly monthly"`
+	StartAt     time.Time  `json:"start_at" validate:"required"`
+	EndAt       *time.Time `json:"end_at,omitempty"`
+	MaxAttempts int        `json:"max_attempts,omitempty" validate:"min=0"`
+}
+
+type UpdateScheduleRequest struct {
+	Amount *float64   `json:"amount,omitempty" validate:"omitempty,positive,cents"`
+	EndAt  *time.Time `json:"end_at,omitempty"`
+	//status can only move between active and paused
+	Status *string `json:"status,omitempty" validate:"omitempty,oneof=active paused"`
+}
+
+// validate checks the rules that span several fields of a request whose
+// fields have already been validated on their own.
+func (req ScheduleRequest) validate() error {
+	switch {
+	case req.Type == "transfer" && req.CounterpartyUserID == "":
+		return errors.New("counterparty_user_id is required for transfers")
+	case req.Type == "transfer" && req.CounterpartyUserID == req.UserID:
+		return errors.New("counterparty_user_id must differ from user_id")
+	case req.EndAt != nil && req.EndAt.Before(req.StartAt):
+		return errors.New("end_at must be after start_at")
+	}
+
+	return nil
+}
+
+func (s service) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+
+	var req ScheduleRequest
+
+	//decode and validate request body
+	if err := utils.Decode(r, &req); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		utils.RespondError(w, utils.NewRequestError(err, http.StatusBadRequest))
+		return
+	}
+
+	// Validate if users exist
+	if _, err := s.userRepo.GetUserByID(r.Context(), req.UserID); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+	if req.CounterpartyUserID != "" {
+		if _, err := s.userRepo.GetUserByID(r.Context(), req.CounterpartyUserID); err != nil {
+			if errors.Is(err, utils.ErrNotFound) {
+				err = utils.NotFound("counterparty not found")
+			}
+			utils.RespondError(w, err)
+			return
+		}
+	}
+
+	// Convert amount to int64 by multiplying by 100
+	sc := schedule.NewSchedule(req.UserID, req.Reference, req.Type, req.Frequency, utils.Cents(req.Amount), req.StartAt)
+	sc.EndAt = req.EndAt
+	if req.CounterpartyUserID != "" {
+		sc.CounterpartyUserID = &req.CounterpartyUserID
+	}
+	if req.MaxAttempts > 0 {
+		sc.MaxAttempts = req.MaxAttempts
+	}
+	if operator := utils.OperatorFrom(r.Context()); operator != "" {
+		sc.Operator = &operator
+	}
+
+	sc, err := s.scheduleRepo.Create(r.Context(), sc)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusCreated, sc)
+}
+
+func (s service) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.PathID(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	sc, err := s.scheduleRepo.GetScheduleByID(r.Context(), id)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, sc)
+}
+
+func (s service) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	query := struct {
+		UserID string `json:"user_id" validate:"required,uuid"`
+	}{UserID: r.URL.Query().Get("user_id")}
+	if err := utils.Validate(query); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	schedules, err := s.scheduleRepo.GetSchedulesByUserID(r.Context(), query.UserID)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, schedules)
+}
+
+func (s service) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+
+	var req UpdateScheduleRequest
+
+	//decode and validate request body
+	if err := utils.Decode(r, &req); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	id, err := utils.PathID(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	// Only what the client sent is changed, so runs made in the meantime are
+	// kept; finished schedules can no longer be changed
+	changes := schedule.Changes{EndAt: req.EndAt, Status: req.Status}
+	if req.Amount != nil {
+		amount := utils.Cents(*req.Amount)
+		changes.Amount = &amount
+	}
+
+	sc, err := s.scheduleRepo.Update(r.Context(), id, changes)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, sc)
+}
+
+func (s service) CancelSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.PathID(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	sc, err := s.scheduleRepo.Cancel(r.Context(), id)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, sc)
+}
+
+func (s service) GetScheduleRuns(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.PathID(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	runs, err := s.scheduleRepo.GetRunsByScheduleID(r.Context(), id)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, runs)
+}
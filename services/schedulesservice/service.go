@@ -0,0 +1,28 @@
+package schedulesservice
+
+import (
+	"net/http"
+	"p-system/repositories/schedule"
+	"p-system/repositories/user"
+)
+
+type service struct {
+	scheduleRepo schedule.Repository
+	userRepo     user.Repository
+}
+
+type Service interface {
+	CreateSchedule(w http.ResponseWriter, r *http.Request)
+	GetSchedule(w http.ResponseWriter, r *http.Request)
+	ListSchedules(w http.ResponseWriter, r *http.Request)
+	UpdateSchedule(w http.ResponseWriter, r *http.Request)
+	CancelSchedule(w http.ResponseWriter, r *http.Request)
+	GetScheduleRuns(w http.ResponseWriter, r *http.Request)
+}
+
+func NewService(scheduleRepo schedule.Repository, userRepo user.Repository) Service {
+	return &service{
+		scheduleRepo: scheduleRepo,
+		userRepo:     userRepo,
+	}
+}
@@ -0,0 +1,236 @@
+package schedulesservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"p-system/repositories/schedule"
+	"p-system/repositories/transaction"
+	"p-system/services/transactionsservice"
+	"p-system/utils"
+	"time"
+)
+
+// Worker runs due schedules through the transaction service.
+type Worker struct {
+	scheduleRepo    schedule.Repository
+	transactionRepo transaction.Repository
+	transactions    transactionsservice.Service
+	// Interval is how often the worker polls for due schedules.
+	Interval time.Duration
+	// BatchSize is the maximum number of schedules claimed per poll.
+	BatchSize int
+	// RetryDelay is the delay before the first retry of a failed run. It
+	// doubles for every further attempt.
+	RetryDelay time.Duration
+	now        func() time.Time
+	logger     *slog.Logger
+}
+
+// errUnreversed is returned for a transfer run left with a debit that could
+// not be reversed. Such a run is retried until the debit is reversed, however
+// many attempts that takes.
+var errUnreversed = errors.New("transfer debit not reversed")
+
+func NewWorker(scheduleRepo schedule.Repository, transactionRepo transaction.Repository, transactions transactionsservice.Service, logger *slog.Logger) *Worker {
+	return &Worker{
+		scheduleRepo:    scheduleRepo,
+		transactionRepo: transactionRepo,
+		transactions:    transactions,
+		Interval:        time.Minute,
+		BatchSize:       50,
+		RetryDelay:      time.Minute,
+		now:             time.Now,
+		logger:          logger,
+	}
+}
+
+// Start polls for due schedules until ctx is cancelled.
+func (w *Worker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.RunDue(ctx); err != nil {
+			w.logger.ErrorContext(ctx, "running due schedules", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue runs every schedule that is due and returns how many were run.
+func (w *Worker) RunDue(ctx context.Context) (int, error) {
+	return w.scheduleRepo.ProcessDue(ctx, w.now(), w.BatchSize, w.run)
+}
+
+// run makes a single attempt at the current occurrence of sc and moves the
+// schedule on to its retry or next occurrence.
+func (w *Worker) run(ctx context.Context, sc *schedule.Schedule) schedule.Run {
+	now := w.now()
+	attempt := sc.Attempts + 1
+
+	run := schedule.Run{
+		ScheduleID: sc.ID,
+		Occurrence: sc.Occurrence + 1,
+		Attempt:    attempt,
+		Reference:  sc.RunReference(attempt, ""),
+		RanAt:      now,
+	}
+
+	// A claimed schedule is run to completion even on shutdown, in the name
+	// of the operator who made it
+	ctx = context.WithoutCancel(ctx)
+	if sc.Operator != nil {
+		ctx = utils.WithOperator(ctx, *sc.Operator)
+	}
+	err := w.execute(ctx, sc, attempt)
+	if err == nil {
+		run.Status = schedule.RunStatusSucceeded
+		sc.LastError = nil
+		sc.Advance(now)
+		return run
+	}
+
+	msg := err.Error()
+	run.Error = &msg
+
+	// A run waiting for approval is not retried; the approval names it
+	var held *transactionsservice.HeldError
+	if errors.As(err, &held) {
+		run.Status = schedule.RunStatusHeld
+		sc.LastError = nil
+		sc.Advance(now)
+		return run
+	}
+
+	sc.LastError = &msg
+
+	if attempt >= sc.MaxAttempts && !errors.Is(err, errUnreversed) {
+		run.Status = schedule.RunStatusFailed
+		sc.Advance(now)
+		return run
+	}
+
+	run.Status = schedule.RunStatusRetrying
+	sc.Attempts = attempt
+	next := now.Add(w.RetryDelay << (attempt - 1))
+	sc.NextRunAt = &next
+
+	return run
+}
+
+// execute makes the transaction of the schedule unless an earlier attempt at
+// the same occurrence already completed it.
+func (w *Worker) execute(ctx context.Context, sc *schedule.Schedule, attempt int) error {
+	if sc.Type == "transfer" {
+		return w.transfer(ctx, sc, attempt)
+	}
+	if w.completedEarlier(ctx, sc, attempt) {
+		return nil
+	}
+
+	// Convert amount to float64 by dividing by 100
+	reference := sc.RunReference(attempt, "")
+	resp, err := w.transactions.HandleTransactionRequest(ctx, transactionsservice.Request{
+		Amount:    float64(sc.Amount) / 100,
+		UserID:    sc.UserID,
+		Type:      sc.Type,
+		Reference: reference,
+	})
+	// A schedule claimed again after its worker stopped may have made the
+	// transaction already, under its reference
+	if errors.Is(err, utils.ErrDuplicateReference) && w.completed(ctx, reference) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return errors.New(resp.Message)
+	}
+
+	return nil
+}
+
+// transfer makes a transfer from the owner of the schedule to its
+// counterparty, debit and credit together. Attempts that made only the debit,
+// as when reversing it failed or the worker stopped in between, have it
+// reversed before the transfer is made again, and again before the attempt is
+// given up, so the owner is never debited for a credit that was not made.
+func (w *Worker) transfer(ctx context.Context, sc *schedule.Schedule, attempt int) error {
+	if sc.CounterpartyUserID == nil {
+		return errors.New("transfer has no counterparty")
+	}
+
+	made, err := w.unwind(ctx, sc, attempt)
+	if err != nil || made {
+		return err
+	}
+
+	// Convert amount to float64 by dividing by 100
+	amount := float64(sc.Amount) / 100
+	resp, err := w.transactions.HandleTransfer(ctx, transactionsservice.Transfer{
+		Debit:  transactionsservice.Request{Amount: amount, UserID: sc.UserID, Type: "debit", Reference: sc.RunReference(attempt, "D")},
+		Credit: transactionsservice.Request{Amount: amount, UserID: *sc.CounterpartyUserID, Type: "credit", Reference: sc.RunReference(attempt, "C")},
+	})
+	var held *transactionsservice.HeldError
+	if err == nil && !resp.Success {
+		err = errors.New(resp.Message)
+	}
+	if err == nil || errors.As(err, &held) {
+		return err
+	}
+
+	// HandleTransfer reverses the debit itself, unless that failed too
+	if _, unwindErr := w.unwind(ctx, sc, attempt); unwindErr != nil {
+		return errors.Join(err, unwindErr)
+	}
+	return err
+}
+
+// unwind reverses the debit of every attempt at the current occurrence up to
+// attempt that did not make its credit, and reports whether one of them made
+// the whole transfer.
+func (w *Worker) unwind(ctx context.Context, sc *schedule.Schedule, attempt int) (bool, error) {
+	for i := 1; i <= attempt; i++ {
+		debit, err := w.transactionRepo.GetTransactionByReference(ctx, sc.RunReference(i, "D"))
+		if err != nil || debit.Status != "completed" {
+			continue
+		}
+		if w.completed(ctx, sc.RunReference(i, "C")) {
+			return true, nil
+		}
+
+		// Reversing a debit reversed already only returns its reversal
+		if _, err := w.transactions.ReverseTransaction(ctx, debit.ID); err != nil {
+			return false, fmt.Errorf("%w: %v", errUnreversed, err)
+		}
+	}
+
+	return false, nil
+}
+
+// completedEarlier reports whether a previous attempt at the current
+// occurrence already completed its transaction, so retries never repeat it.
+func (w *Worker) completedEarlier(ctx context.Context, sc *schedule.Schedule, attempt int) bool {
+	for i := 1; i < attempt; i++ {
+		if w.completed(ctx, sc.RunReference(i, "")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// completed reports whether the transaction with the given reference has
+// completed.
+func (w *Worker) completed(ctx context.Context, reference string) bool {
+	t, err := w.transactionRepo.GetTransactionByReference(ctx, reference)
+	return err == nil && t.Status == "completed"
+}
@@ -0,0 +1,289 @@
+package schedulesservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"p-system/fixtures"
+	"p-system/logging"
+	"p-system/repositories/approval"
+	"p-system/repositories/memory"
+	"p-system/repositories/schedule"
+	"p-system/repositories/transaction"
+	"p-system/repositories/unitofwork"
+	"p-system/repositories/unitofwork/unitofworktest"
+	"p-system/services/thirdparty"
+	"p-system/services/transactionsservice"
+	"p-system/utils"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fixedNow = time.Date(2024, time.May, 31, 9, 0, 0, 0, time.UTC)
+
+// newTestWorker returns a worker whose ProcessDue hands sc to the run function
+// and captures the recorded run.
+func newTestWorker(ctrl *gomock.Controller, sc *schedule.Schedule, run *schedule.Run) (*Worker, *transactionsservice.MockService, *transaction.MockRepository) {
+	mockScheduleRepo := schedule.NewMockRepository(ctrl)
+	mockTransactionRepo := transaction.NewMockRepository(ctrl)
+	mockTransactions := transactionsservice.NewMockService(ctrl)
+
+	mockScheduleRepo.EXPECT().ProcessDue(gomock.Any(), fixedNow, gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, now time.Time, limit int, fn func(context.Context, *schedule.Schedule) schedule.Run) (int, error) {
+			*run = fn(ctx, sc)
+			return 1, nil
+		})
+
+	w := NewWorker(mockScheduleRepo, mockTransactionRepo, mockTransactions, logging.Discard())
+	w.now = func() time.Time { return fixedNow }
+
+	return w, mockTransactions, mockTransactionRepo
+}
+
+func TestWorker_RunDue_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sc := schedule.NewSchedule("user123", "rent", "debit", schedule.FrequencyMonthly, 10000, fixedNow)
+	var run schedule.Run
+	w, mockTransactions, _ := newTestWorker(ctrl, sc, &run)
+
+	mockTransactions.EXPECT().HandleTransactionRequest(gomock.Any(), transactionsservice.Request{
+		Amount:    100.0,
+		UserID:    "user123",
+		Type:      "debit",
+		Reference: "rent-1-1",
+	}).Return(transactionsservice.TransactionResponse{Success: true}, nil)
+
+	n, err := w.RunDue(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, schedule.RunStatusSucceeded, run.Status)
+	assert.Equal(t, 1, sc.Occurrence)
+	assert.Equal(t, time.Date(2024, time.June, 30, 9, 0, 0, 0, time.UTC), *sc.NextRunAt)
+}
+
+func TestWorker_RunDue_RetriesFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sc := schedule.NewSchedule("user123", "rent", "debit", schedule.FrequencyMonthly, 10000, fixedNow)
+	var run schedule.Run
+	w, mockTransactions, _ := newTestWorker(ctrl, sc, &run)
+
+	mockTransactions.EXPECT().HandleTransactionRequest(gomock.Any(), gomock.Any()).
+		Return(transactionsservice.TransactionResponse{Success: false, Message: "Insufficient balance"}, utils.ErrInsufficientFunds)
+
+	_, err := w.RunDue(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, schedule.RunStatusRetrying, run.Status)
+	assert.Equal(t, "insufficient balance", *run.Error)
+	assert.Equal(t, 1, sc.Attempts)
+	assert.Equal(t, 0, sc.Occurrence)
+	assert.Equal(t, fixedNow.Add(w.RetryDelay), *sc.NextRunAt)
+}
+
+func TestWorker_RunDue_HeldForApproval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sc := schedule.NewSchedule("user123", "rent", "debit", schedule.FrequencyMonthly, 10000, fixedNow)
+	operator := "alice"
+	sc.Operator = &operator
+	var run schedule.Run
+	w, mockTransactions, _ := newTestWorker(ctrl, sc, &run)
+
+	// The run is held in the name of the operator who made the schedule
+	mockTransactions.EXPECT().HandleTransactionRequest(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ transactionsservice.Request) (transactionsservice.TransactionResponse, error) {
+			assert.Equal(t, "alice", utils.OperatorFrom(ctx))
+			return transactionsservice.TransactionResponse{Success: false, Message: "Transaction held for approval"}, &transactionsservice.HeldError{Approval: &approval.Approval{ID: "approval1"}}
+		})
+
+	_, err := w.RunDue(context.Background())
+
+	// The approval makes the run, so it is not retried
+	assert.NoError(t, err)
+	assert.Equal(t, schedule.RunStatusHeld, run.Status)
+	assert.Equal(t, "transaction is held for approval approval1", *run.Error)
+	assert.Nil(t, sc.LastError)
+	assert.Equal(t, 1, sc.Occurrence)
+	assert.Equal(t, time.Date(2024, time.June, 30, 9, 0, 0, 0, time.UTC), *sc.NextRunAt)
+}
+
+func TestWorker_RunDue_GivesUpAfterMaxAttempts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sc := schedule.NewSchedule("user123", "rent", "credit", schedule.FrequencyOnce, 10000, fixedNow)
+	sc.Attempts = sc.MaxAttempts - 1
+	var run schedule.Run
+	w, mockTransactions, mockTransactionRepo := newTestWorker(ctrl, sc, &run)
+
+	mockTransactionRepo.EXPECT().GetTransactionByReference(gomock.Any(), gomock.Any()).
+		Return(&transaction.Transaction{Status: "failed"}, nil).Times(sc.MaxAttempts - 1)
+	mockTransactions.EXPECT().HandleTransactionRequest(gomock.Any(), gomock.Any()).
+		Return(transactionsservice.TransactionResponse{Success: false, Message: "Failed to make payment"}, assert.AnError)
+
+	_, err := w.RunDue(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, schedule.RunStatusFailed, run.Status)
+	assert.Equal(t, schedule.StatusCompleted, sc.Status)
+	assert.Nil(t, sc.NextRunAt)
+}
+
+func TestWorker_RunDue_ReclaimedRunAlreadyMade(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sc := schedule.NewSchedule("user123", "rent", "debit", schedule.FrequencyMonthly, 10000, fixedNow)
+	var run schedule.Run
+	w, mockTransactions, mockTransactionRepo := newTestWorker(ctrl, sc, &run)
+
+	// A worker made the run and stopped before saving it
+	mockTransactions.EXPECT().HandleTransactionRequest(gomock.Any(), gomock.Any()).
+		Return(transactionsservice.TransactionResponse{Success: false, Message: "Failed to create transaction"}, utils.DuplicateReference("transaction already exists"))
+	mockTransactionRepo.EXPECT().GetTransactionByReference(gomock.Any(), "rent-1-1").
+		Return(&transaction.Transaction{Status: "completed"}, nil)
+
+	_, err := w.RunDue(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, schedule.RunStatusSucceeded, run.Status)
+	assert.Equal(t, 1, sc.Occurrence)
+}
+
+func TestWorker_RunDue_TransferReversesStrandedDebit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	counterparty := "user456"
+	sc := schedule.NewSchedule("user123", "allowance", "transfer", schedule.FrequencyWeekly, 2500, fixedNow)
+	sc.CounterpartyUserID = &counterparty
+	sc.Attempts = 1
+	var run schedule.Run
+	w, mockTransactions, mockTransactionRepo := newTestWorker(ctrl, sc, &run)
+
+	// The first attempt made the debit but not the credit, and the worker
+	// stopped before reversing it
+	mockTransactionRepo.EXPECT().GetTransactionByReference(gomock.Any(), "allowance-1D-1").
+		Return(&transaction.Transaction{ID: "debit1", Status: "completed"}, nil)
+	mockTransactionRepo.EXPECT().GetTransactionByReference(gomock.Any(), "allowance-1C-1").
+		Return(&transaction.Transaction{Status: "failed"}, nil)
+	mockTransactionRepo.EXPECT().GetTransactionByReference(gomock.Any(), "allowance-1D-2").
+		Return(nil, utils.NotFound("transaction not found"))
+	reverse := mockTransactions.EXPECT().ReverseTransaction(gomock.Any(), "debit1").Return(&transaction.Transaction{}, nil)
+	mockTransactions.EXPECT().HandleTransfer(gomock.Any(), transactionsservice.Transfer{
+		Debit:  transactionsservice.Request{Amount: 25.0, UserID: "user123", Type: "debit", Reference: "allowance-1D-2"},
+		Credit: transactionsservice.Request{Amount: 25.0, UserID: "user456", Type: "credit", Reference: "allowance-1C-2"},
+	}).After(reverse).Return(transactionsservice.TransactionResponse{Success: true}, nil)
+
+	_, err := w.RunDue(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, schedule.RunStatusSucceeded, run.Status)
+	assert.Equal(t, 2, run.Attempt)
+	assert.Equal(t, fixedNow.AddDate(0, 0, 7), *sc.NextRunAt)
+}
+
+func TestWorker_RunDue_RetriesTransferUntilDebitReversed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	counterparty := "user456"
+	sc := schedule.NewSchedule("user123", "allowance", "transfer", schedule.FrequencyWeekly, 2500, fixedNow)
+	sc.CounterpartyUserID = &counterparty
+	sc.Attempts = sc.MaxAttempts - 1
+	var run schedule.Run
+	w, mockTransactions, mockTransactionRepo := newTestWorker(ctrl, sc, &run)
+
+	// The last attempt makes the debit but neither the credit nor its reversal
+	last := fmt.Sprintf("allowance-1D-%d", sc.MaxAttempts)
+	made := false
+	mockTransactionRepo.EXPECT().GetTransactionByReference(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, reference string) (*transaction.Transaction, error) {
+			if made && reference == last {
+				return &transaction.Transaction{ID: "debit", Status: "completed"}, nil
+			}
+			return nil, utils.NotFound("transaction not found")
+		})
+	mockTransactions.EXPECT().HandleTransfer(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, transactionsservice.Transfer) (transactionsservice.TransactionResponse, error) {
+			made = true
+			return transactionsservice.TransactionResponse{Success: false, Message: "Failed to make payment"}, assert.AnError
+		})
+	mockTransactions.EXPECT().ReverseTransaction(gomock.Any(), "debit").Return(nil, assert.AnError)
+
+	_, err := w.RunDue(context.Background())
+
+	// The schedule does not move on while the owner is out of pocket
+	assert.NoError(t, err)
+	assert.Equal(t, schedule.RunStatusRetrying, run.Status)
+	assert.Equal(t, schedule.StatusActive, sc.Status)
+	assert.Equal(t, sc.MaxAttempts, sc.Attempts)
+}
+
+func TestWorker_RunDue_TransferCreditNeverMade(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Real repositories and transaction service backed by memory, so only the
+	// provider is mocked
+	store := memory.NewStore()
+	store.PutUser(fixtures.User().WithID("user123").Build())
+	store.PutUser(fixtures.User().WithID("user456").Build())
+	store.PutWallet(fixtures.Wallet("user123").WithID("wallet123").WithBalance(10000).Build())
+	store.PutWallet(fixtures.Wallet("user456").WithID("wallet456").WithBalance(500).Build())
+	repos := unitofwork.Repositories{Users: store.Users(), Wallets: store.Wallets(), Transactions: store.Transactions()}
+
+	// The provider takes every debit and turns down every credit
+	mockThirdParty := thirdparty.NewMockService(ctrl)
+	mockThirdParty.EXPECT().MakePayment(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(req thirdparty.Transaction, _ context.Context) (*thirdparty.Transaction, error) {
+			if req.AccountID == "user456" {
+				return nil, errors.New("account closed")
+			}
+			return &req, nil
+		})
+	mockThirdParty.EXPECT().RefundPayment(gomock.Any(), gomock.Any()).AnyTimes().Return(&thirdparty.Transaction{}, nil)
+	transactions := transactionsservice.NewService(repos.Users, repos.Transactions, repos.Wallets, unitofworktest.Direct(repos), mockThirdParty, nil, nil, 0, logging.Discard())
+
+	counterparty := "user456"
+	sc := schedule.NewSchedule("user123", "allowance", "transfer", schedule.FrequencyOnce, 2500, fixedNow)
+	sc.CounterpartyUserID = &counterparty
+
+	mockScheduleRepo := schedule.NewMockRepository(ctrl)
+	var runs []schedule.Run
+	mockScheduleRepo.EXPECT().ProcessDue(gomock.Any(), fixedNow, gomock.Any(), gomock.Any()).Times(sc.MaxAttempts).
+		DoAndReturn(func(ctx context.Context, now time.Time, limit int, fn func(context.Context, *schedule.Schedule) schedule.Run) (int, error) {
+			runs = append(runs, fn(ctx, sc))
+			return 1, nil
+		})
+	w := NewWorker(mockScheduleRepo, repos.Transactions, transactions, logging.Discard())
+	w.now = func() time.Time { return fixedNow }
+
+	for i := 0; i < sc.MaxAttempts; i++ {
+		_, err := w.RunDue(context.Background())
+		require.NoError(t, err)
+	}
+
+	// Every attempt failed and the schedule gave up, with both wallets as
+	// they started
+	require.Len(t, runs, sc.MaxAttempts)
+	assert.Equal(t, schedule.RunStatusFailed, runs[len(runs)-1].Status)
+	assert.Equal(t, schedule.StatusCompleted, sc.Status)
+
+	payer, err := repos.Wallets.GetWalletByID(context.Background(), "wallet123")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10000), payer.Balance)
+	payee, err := repos.Wallets.GetWalletByID(context.Background(), "wallet456")
+	require.NoError(t, err)
+	assert.Equal(t, int64(500), payee.Balance)
+}
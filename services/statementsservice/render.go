@@ -0,0 +1,123 @@
+package statementsservice
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/go-pdf/fpdf"
+)
+
+const dateLayout = "2006-01-02 15:04"
+
+// writeCSV writes the statement as CSV with the opening and closing balances
+// as the first and last lines around one line per transaction.
+func writeCSV(out io.Writer, st *Statement) error {
+	writer := csv.NewWriter(out)
+
+	records := [][]string{
+		{"date", "transaction_id", "reference", "type", "amount", "balance"},
+		{st.From.Format(dateLayout), "", "", "opening balance", "", formatAmount(st.OpeningBalance)},
+	}
+	for _, line := range st.Lines {
+		records = append(records, []string{
+			line.Date.Format(dateLayout),
+			line.TransactionID,
+			line.Reference,
+			line.Type,
+			formatAmount(line.Amount),
+			formatAmount(line.Balance),
+		})
+	}
+	records = append(records, []string{st.To.Format(dateLayout), "", "", "closing balance", "", formatAmount(st.ClosingBalance)})
+
+	if err := writer.WriteAll(records); err != nil {
+		return err
+	}
+
+	return writer.Error()
+}
+
+// pdfColumns are the headings and widths in millimetres of the transaction
+// table on a PDF statement.
+var pdfColumns = []struct {
+	heading string
+	width   float64
+	align   string
+}{
+	{"Date", 32, "L"},
+	{"Reference", 66, "L"},
+	{"Type", 24, "L"},
+	{"Amount", 34, "R"},
+	{"Balance", 34, "R"},
+}
+
+// writePDF renders the statement as an A4 PDF document. The table heading is
+// repeated on every page.
+func writePDF(out io.Writer, st *Statement) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Wallet statement", false)
+	pdf.SetAutoPageBreak(true, 15)
+
+	tableHeading := func() {
+		pdf.SetFont("Helvetica", "B", 9)
+		pdf.SetFillColor(230, 230, 230)
+		for _, column := range pdfColumns {
+			pdf.CellFormat(column.width, 7, column.heading, "1", 0, column.align, true, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Helvetica", "", 9)
+	}
+
+	pdf.SetHeaderFunc(func() {
+		if pdf.PageNo() > 1 {
+			tableHeading()
+		}
+	})
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.CellFormat(0, 6, "Generated "+st.GeneratedAt.Format(dateLayout)+" - Page "+strconv.Itoa(pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, "Wallet statement", "", 1, "L", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 10)
+	summary := [][2]string{
+		{"Wallet", st.WalletID},
+		{"Period", st.From.Format(dateLayout) + " to " + st.To.Format(dateLayout)},
+		{"Opening balance", formatAmount(st.OpeningBalance)},
+		{"Total credits", formatAmount(st.TotalCredits)},
+		{"Total debits", formatAmount(st.TotalDebits)},
+		{"Closing balance", formatAmount(st.ClosingBalance)},
+	}
+	for _, row := range summary {
+		pdf.CellFormat(40, 6, row[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 6, row[1], "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	tableHeading()
+	for _, line := range st.Lines {
+		values := []string{
+			line.Date.Format(dateLayout),
+			line.Reference,
+			line.Type,
+			formatAmount(line.Amount),
+			formatAmount(line.Balance),
+		}
+		for i, column := range pdfColumns {
+			pdf.CellFormat(column.width, 6, values[i], "1", 0, column.align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	if len(st.Lines) == 0 {
+		pdf.CellFormat(0, 6, "No transactions in this period", "1", 1, "C", false, 0, "")
+	}
+
+	return pdf.Output(out)
+}
@@ -0,0 +1,20 @@
+package statementsservice
+
+import (
+	"net/http"
+	"p-system/repositories/unitofwork"
+)
+
+type service struct {
+	uow unitofwork.UnitOfWork
+}
+
+type Service interface {
+	GetStatement(w http.ResponseWriter, r *http.Request)
+}
+
+func NewService(uow unitofwork.UnitOfWork) Service {
+	return &service{
+		uow: uow,
+	}
+}
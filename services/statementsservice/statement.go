@@ -0,0 +1,106 @@
+package statementsservice
+
+import (
+	"context"
+	"fmt"
+	"p-system/repositories/transaction"
+	"p-system/repositories/unitofwork"
+	"p-system/repositories/wallet"
+	"time"
+)
+
+// Statement is the activity of a wallet over a period. Amounts are in minor
+// units, debits are negative.
+type Statement struct {
+	WalletID       string    `json:"wallet_id"`
+	UserID         string    `json:"user_id"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	OpeningBalance int64     `json:"opening_balance"`
+	ClosingBalance int64     `json:"closing_balance"`
+	TotalCredits   int64     `json:"total_credits"`
+	TotalDebits    int64     `json:"total_debits"`
+	Lines          []Line    `json:"lines"`
+	GeneratedAt    time.Time `json:"generated_at"`
+}
+
+// Line is a single transaction on a statement with the balance after it.
+type Line struct {
+	Date          time.Time `json:"date"`
+	TransactionID string    `json:"transaction_id"`
+	Reference     string    `json:"reference"`
+	Type          string    `json:"type"`
+	Amount        int64     `json:"amount"`
+	Balance       int64     `json:"balance"`
+}
+
+// buildStatement works out the statement of a wallet for [from, to). The
+// opening balance is derived backwards from the current balance so money
+// credited when the wallet was created is accounted for. Everything is read
+// from one snapshot, so a transaction settling meanwhile cannot skew it.
+func (s service) buildStatement(ctx context.Context, walletID string, from, to time.Time) (*Statement, error) {
+	var (
+		w            *wallet.Wallet
+		sinceFrom    int64
+		transactions []transaction.Transaction
+	)
+	err := s.uow.Read(ctx, func(ctx context.Context, repos unitofwork.Repositories) error {
+		var err error
+		if w, err = repos.Wallets.GetWalletByID(ctx, walletID); err != nil {
+			return err
+		}
+		if sinceFrom, err = repos.Transactions.GetNetAmountSince(ctx, w.UserID, from); err != nil {
+			return err
+		}
+		transactions, err = repos.Transactions.GetCompletedTransactions(ctx, w.UserID, from, to)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	st := &Statement{
+		WalletID:       w.ID,
+		UserID:         w.UserID,
+		From:           from,
+		To:             to,
+		OpeningBalance: w.Balance - sinceFrom,
+		Lines:          []Line{},
+		GeneratedAt:    time.Now(),
+	}
+
+	balance := st.OpeningBalance
+	for _, t := range transactions {
+		amount := t.Amount
+		if t.Type == "debit" {
+			amount = -amount
+			st.TotalDebits += t.Amount
+		} else {
+			st.TotalCredits += t.Amount
+		}
+		balance += amount
+
+		st.Lines = append(st.Lines, Line{
+			Date:          *t.CompletedAt,
+			TransactionID: t.ID,
+			Reference:     t.Reference,
+			Type:          t.Type,
+			Amount:        amount,
+			Balance:       balance,
+		})
+	}
+	st.ClosingBalance = balance
+
+	return st, nil
+}
+
+// formatAmount renders minor units as a decimal amount, e.g. -1050 as -10.50.
+func formatAmount(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
@@ -0,0 +1,114 @@
+package statementsservice
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"p-system/utils"
+	"time"
+)
+
+// maxPeriod is the longest period a single statement can cover.
+const maxPeriod = 366 * 24 * time.Hour
+
+// GetStatement returns the statement of a wallet for the period given by the
+// from and to query parameters, as JSON, CSV or PDF depending on format.
+func (s service) GetStatement(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.PathID(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+	query := r.URL.Query()
+
+	from, to, err := parsePeriod(query.Get("from"), query.Get("to"), time.Now())
+	if err != nil {
+		utils.RespondError(w, utils.NewRequestError(err, http.StatusBadRequest))
+		return
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" && format != "pdf" {
+		utils.RespondError(w, utils.NewRequestError(errors.New("format must be one of json, csv or pdf"), http.StatusBadRequest))
+		return
+	}
+
+	st, err := s.buildStatement(r.Context(), id, from, to)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	if format == "json" {
+		utils.SendJSONResponse(w, http.StatusOK, st)
+		return
+	}
+
+	// Render into a buffer so a failure can still be reported as an error
+	var buf bytes.Buffer
+	contentType := "text/csv"
+	if format == "pdf" {
+		contentType = "application/pdf"
+		err = writePDF(&buf, st)
+	} else {
+		err = writeCSV(&buf, st)
+	}
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	filename := fmt.Sprintf("statement-%s-%s-%s.%s", id, from.Format("20060102"), to.Format("20060102"), format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// parsePeriod parses the bounds of a statement period. Both accept RFC 3339
+// timestamps or plain dates; a plain to date includes the whole of that day.
+// to defaults to now.
+func parsePeriod(fromValue, toValue string, now time.Time) (time.Time, time.Time, error) {
+	if fromValue == "" {
+		return time.Time{}, time.Time{}, errors.New("from is required")
+	}
+
+	from, _, err := parseTime(fromValue)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("from must be a date or RFC 3339 timestamp")
+	}
+
+	to := now
+	if toValue != "" {
+		var dateOnly bool
+		to, dateOnly, err = parseTime(toValue)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("to must be a date or RFC 3339 timestamp")
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+	}
+
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, errors.New("to must be after from")
+	}
+	if to.Sub(from) > maxPeriod {
+		return time.Time{}, time.Time{}, errors.New("statement period must not exceed a year")
+	}
+
+	return from, to, nil
+}
+
+func parseTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	return t, true, err
+}
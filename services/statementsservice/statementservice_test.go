@@ -0,0 +1,118 @@
+package statementsservice
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"p-system/repositories/transaction"
+	"p-system/repositories/unitofwork"
+	"p-system/repositories/unitofwork/unitofworktest"
+	"p-system/repositories/wallet"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	from = time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)
+	to   = time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// newTestService returns a service whose wallet has a balance of 150.00 now,
+// 20.00 of which was credited after the statement period.
+// walletID is the id of the wallet in the path of requests.
+const walletID = "d164e69d-26f5-448d-a18c-baeae517d991"
+
+// completedAt returns the time d into the statement period.
+func completedAt(d time.Duration) *time.Time {
+	at := from.Add(d)
+	return &at
+}
+
+func newTestService(ctrl *gomock.Controller) service {
+	mockWalletRepo := wallet.NewMockRepository(ctrl)
+	mockTransactionRepo := transaction.NewMockRepository(ctrl)
+
+	mockWalletRepo.EXPECT().GetWalletByID(gomock.Any(), walletID).Return(&wallet.Wallet{ID: walletID, UserID: "user123", Balance: 15000}, nil)
+	mockTransactionRepo.EXPECT().GetNetAmountSince(gomock.Any(), "user123", from).Return(int64(7000), nil)
+	mockTransactionRepo.EXPECT().GetCompletedTransactions(gomock.Any(), "user123", from, to).Return([]transaction.Transaction{
+		{ID: "t1", Reference: "ref1", Type: "credit", Amount: 10000, CreatedAt: from.Add(-time.Hour), CompletedAt: completedAt(time.Hour)},
+		{ID: "t2", Reference: "ref2", Type: "debit", Amount: 5000, CreatedAt: from.Add(47 * time.Hour), CompletedAt: completedAt(48 * time.Hour)},
+	}, nil)
+
+	uow := unitofworktest.Direct(unitofwork.Repositories{Wallets: mockWalletRepo, Transactions: mockTransactionRepo})
+	return service{uow: uow}
+}
+
+func TestBuildStatement(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	st, err := newTestService(ctrl).buildStatement(context.Background(), walletID, from, to)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8000), st.OpeningBalance)
+	assert.Equal(t, int64(13000), st.ClosingBalance)
+	assert.Equal(t, int64(10000), st.TotalCredits)
+	assert.Equal(t, int64(5000), st.TotalDebits)
+	assert.Equal(t, []int64{18000, 13000}, []int64{st.Lines[0].Balance, st.Lines[1].Balance})
+	assert.Equal(t, int64(-5000), st.Lines[1].Amount)
+	assert.Equal(t, from.Add(time.Hour), st.Lines[0].Date)
+}
+
+func TestWriteCSV(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	st, _ := newTestService(ctrl).buildStatement(context.Background(), walletID, from, to)
+
+	var out bytes.Buffer
+	err := writeCSV(&out, st)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "date,transaction_id,reference,type,amount,balance\n"+
+		"2024-05-01 00:00,,,opening balance,,80.00\n"+
+		"2024-05-01 01:00,t1,ref1,credit,100.00,180.00\n"+
+		"2024-05-03 00:00,t2,ref2,debit,-50.00,130.00\n"+
+		"2024-06-01 00:00,,,closing balance,,130.00\n", out.String())
+}
+
+func TestGetStatement_PDF(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	svc := newTestService(ctrl)
+
+	req := httptest.NewRequest(http.MethodGet, "/wallets/"+walletID+"/statement?from=2024-05-01&to=2024-05-31&format=pdf", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": walletID})
+	rec := httptest.NewRecorder()
+
+	svc.GetStatement(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+	assert.True(t, bytes.HasPrefix(rec.Body.Bytes(), []byte("%PDF-")))
+}
+
+func TestParsePeriod(t *testing.T) {
+	now := time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+
+	f, tt, err := parsePeriod("2024-05-01", "2024-05-31", now)
+	assert.NoError(t, err)
+	assert.Equal(t, from, f)
+	assert.Equal(t, to, tt)
+
+	_, tt, err = parsePeriod("2024-05-01T10:00:00Z", "", now)
+	assert.NoError(t, err)
+	assert.Equal(t, now, tt)
+
+	_, _, err = parsePeriod("", "", now)
+	assert.EqualError(t, err, "from is required")
+
+	_, _, err = parsePeriod("2024-05-01", "2024-04-01", now)
+	assert.EqualError(t, err, "to must be after from")
+}
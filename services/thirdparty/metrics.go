@@ -0,0 +1,105 @@
+package thirdparty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"p-system/metrics"
+	"p-system/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StatusError is returned when the provider answers with an unexpected HTTP
+// status.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.Code)
+}
+
+// instrumented records the latency and errors of every provider call, and
+// traces it in a client span.
+type instrumented struct {
+	next Service
+}
+
+func instrument(next Service) Service {
+	return instrumented{next: next}
+}
+
+func (s instrumented) GetTransaction(reference, accountID string) (*Transaction, error) {
+	_, span := startSpan(context.Background(), "thirdparty.GetTransaction", reference)
+	start := time.Now()
+	t, err := s.next.GetTransaction(reference, accountID)
+	observe("get_transaction", start, err)
+	tracing.End(span, err)
+	return t, err
+}
+
+func (s instrumented) MakePayment(req Transaction, ctx context.Context) (*Transaction, error) {
+	ctx, span := startSpan(ctx, "thirdparty.MakePayment", req.Reference)
+	start := time.Now()
+	t, err := s.next.MakePayment(req, ctx)
+	observe("make_payment", start, err)
+	tracing.End(span, err)
+	return t, err
+}
+
+func (s instrumented) RefundPayment(req Transaction, ctx context.Context) (*Transaction, error) {
+	ctx, span := startSpan(ctx, "thirdparty.RefundPayment", req.Reference)
+	start := time.Now()
+	t, err := s.next.RefundPayment(req, ctx)
+	observe("refund_payment", start, err)
+	tracing.End(span, err)
+	return t, err
+}
+
+func startSpan(ctx context.Context, name, reference string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("payment.reference", reference)),
+	)
+}
+
+func observe(operation string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		metrics.ProviderErrors.WithLabelValues(operation, errorClass(err)).Inc()
+	}
+	metrics.ProviderCallDuration.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
+
+// errorClass groups provider errors into a small fixed set of labels.
+func errorClass(err error) string {
+	var statusErr *StatusError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.As(err, &statusErr) && statusErr.Code >= 500:
+		return "server_error"
+	case errors.As(err, &statusErr):
+		return "client_error"
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return "invalid_response"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "network"
+	default:
+		return "other"
+	}
+}
@@ -4,9 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"p-system/tracing"
 )
 
 
@@ -18,11 +19,12 @@ type service struct {
 type Service interface {
 	GetTransaction(reference, accountID string) (*Transaction, error)
 	MakePayment(req Transaction, ctx context.Context) (*Transaction, error)
+	RefundPayment(req Transaction, ctx context.Context) (*Transaction, error)
 
 }
 
 func NewService() Service {
-	return &service{}
+	return instrument(&service{})
 }
 
 type Transaction struct {
@@ -47,14 +49,18 @@ func(s *service) GetTransaction(reference, accountID string) (*Transaction, erro
 	defer server.Close()
 
 	// Send a GET request to the mock server
-	resp, err := http.Get(server.URL + "/third-party/payments/" + reference)
+	httpReq, err := newRequest(context.Background(), http.MethodGet, server.URL+"/third-party/payments/"+reference, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(httpReq)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, &StatusError{Code: resp.StatusCode}
 	}
 
 	// Decode the response body into a Transaction object
@@ -90,14 +96,19 @@ func(s *service) MakePayment(req Transaction, ctx context.Context) (*Transaction
 	}
 
 	// Send a POST request to the mock server
-	resp, err := http.Post(server.URL+"/third-party/payments", "application/json", bytes.NewBuffer(reqBody))
+	httpReq, err := newRequest(ctx, http.MethodPost, server.URL+"/third-party/payments", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(httpReq)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, &StatusError{Code: resp.StatusCode}
 	}
 
 	// Decode the response body into a Transaction object
@@ -109,3 +120,63 @@ func(s *service) MakePayment(req Transaction, ctx context.Context) (*Transaction
 
 	return &transaction, nil
 }
+
+// RefundPayment reverses a payment made with MakePayment, identified by its
+// reference.
+func(s *service) RefundPayment(req Transaction, ctx context.Context) (*Transaction, error) {
+	// Create a mock HTTP server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Simulating response for testing
+		mockTransaction := Transaction{
+			AccountID: req.AccountID,
+			Reference: req.Reference,
+			Amount:    req.Amount,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(mockTransaction)
+	}))
+	defer server.Close()
+
+	// Create a new request
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send a POST request to the mock server
+	httpReq, err := newRequest(ctx, http.MethodPost, server.URL+"/third-party/payments/"+req.Reference+"/refunds", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Code: resp.StatusCode}
+	}
+
+	// Decode the response body into a Transaction object
+	var transaction Transaction
+	err = json.NewDecoder(resp.Body).Decode(&transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+// newRequest creates a request to the provider carrying the W3C trace context
+// of ctx, so the provider can join the trace.
+func newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	tracing.Inject(ctx, req.Header)
+	return req, nil
+}
@@ -0,0 +1,101 @@
+package transactionsservice
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+var (
+	// ErrQueueFull is returned by Enqueue when every queue slot is taken.
+	ErrQueueFull = errors.New("transaction queue is full")
+	// ErrDraining is returned by Enqueue once the pool has started draining.
+	ErrDraining = errors.New("transaction queue is shutting down")
+)
+
+// Pool completes transactions submitted in async mode on a fixed number of
+// workers.
+type Pool struct {
+	// Concurrency is the number of transactions processed at once.
+	Concurrency int
+
+	queue    chan string
+	mu       sync.RWMutex
+	draining bool
+	wg       sync.WaitGroup
+	logger   *slog.Logger
+}
+
+// NewPool creates a pool that queues up to queueSize transactions.
+func NewPool(concurrency, queueSize int, logger *slog.Logger) *Pool {
+	return &Pool{
+		Concurrency: concurrency,
+		queue:       make(chan string, queueSize),
+		logger:      logger,
+	}
+}
+
+// Start starts the workers, which complete queued transactions with
+// svc.ProcessTransaction until the pool is drained.
+func (p *Pool) Start(svc Service) {
+	for i := 0; i < p.Concurrency; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for id := range p.queue {
+				if _, err := svc.ProcessTransaction(context.Background(), id); err != nil {
+					p.logger.Error("processing transaction", "transaction_id", id, "error", err)
+				}
+			}
+		}()
+	}
+}
+
+// Len returns how many transactions are waiting for a worker.
+func (p *Pool) Len() int {
+	return len(p.queue)
+}
+
+// Enqueue queues the pending transaction with the given id without blocking.
+func (p *Pool) Enqueue(id string) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.draining {
+		return ErrDraining
+	}
+
+	select {
+	case p.queue <- id:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Drain stops accepting transactions and waits for the queued ones to be
+// processed, or for ctx to be done. Transactions still queued when ctx is done
+// are left pending.
+func (p *Pool) Drain(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.draining {
+		p.draining = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.logger.Warn("transaction queue drain interrupted", "queued", len(p.queue))
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,37 @@
+package transactionsservice
+
+import (
+	"context"
+	"p-system/logging"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool_DrainProcessesQueuedTransactions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := NewMockService(ctrl)
+	for _, id := range []string{"tx1", "tx2", "tx3"} {
+		mockService.EXPECT().ProcessTransaction(gomock.Any(), id).Return(TransactionResponse{Success: true}, nil)
+	}
+
+	pool := NewPool(2, 10, logging.Discard())
+	assert.NoError(t, pool.Enqueue("tx1"))
+	assert.NoError(t, pool.Enqueue("tx2"))
+	assert.NoError(t, pool.Enqueue("tx3"))
+
+	pool.Start(mockService)
+	assert.NoError(t, pool.Drain(context.Background()))
+
+	assert.ErrorIs(t, pool.Enqueue("tx4"), ErrDraining)
+}
+
+func TestPool_EnqueueRejectsWhenFull(t *testing.T) {
+	pool := NewPool(1, 1, logging.Discard())
+
+	assert.NoError(t, pool.Enqueue("tx1"))
+	assert.ErrorIs(t, pool.Enqueue("tx2"), ErrQueueFull)
+}
@@ -1,8 +1,12 @@
 package transactionsservice
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
+	"p-system/repositories/approval"
 	"p-system/repositories/transaction"
+	"p-system/repositories/unitofwork"
 	"p-system/repositories/user"
 	"p-system/repositories/wallet"
 	"p-system/services/thirdparty"
@@ -13,17 +17,41 @@ type service struct {
 	transactionRepo   transaction.Repository
 	walletRepo        wallet.Repository
 	thirdPartyService thirdparty.Service
+	// uow completes transactions together with the wallet update.
+	uow unitofwork.UnitOfWork
+	// pool completes transactions submitted in async mode; async mode is
+	// unavailable without one.
+	pool *Pool
+	// approvalRepo holds requests for transactions above approvalThreshold
+	// cents until they are approved; 0 holds none.
+	approvalRepo      approval.Repository
+	approvalThreshold int64
+	logger            *slog.Logger
 }
 
+//go:generate mockgen --source=service.go -destination=service_mock.go -package=transactionsservice Service
 type Service interface {
 	HandleTransaction(w http.ResponseWriter, r *http.Request)
+	HandleTransactionRequest(ctx context.Context, req Request) (TransactionResponse, error)
+	HandleTransfer(ctx context.Context, t Transfer) (TransactionResponse, error)
+	ReverseTransaction(ctx context.Context, id string) (*transaction.Transaction, error)
+	SubmitTransactionRequest(ctx context.Context, req Request) (*transaction.Transaction, error)
+	ProcessTransaction(ctx context.Context, id string) (TransactionResponse, error)
+	RetryTransaction(ctx context.Context, id string) (TransactionResponse, error)
+	GetTransaction(w http.ResponseWriter, r *http.Request)
+	FindTransaction(w http.ResponseWriter, r *http.Request)
 }
 
-func NewService(userRepo user.Repository, transactionRepo transaction.Repository, walletRepo wallet.Repository, thirdpartyService thirdparty.Service) Service {
+func NewService(userRepo user.Repository, transactionRepo transaction.Repository, walletRepo wallet.Repository, uow unitofwork.UnitOfWork, thirdpartyService thirdparty.Service, pool *Pool, approvalRepo approval.Repository, approvalThreshold int64, logger *slog.Logger) Service {
 	return &service{
 		userRepo:          userRepo,
 		transactionRepo:   transactionRepo,
 		walletRepo:        walletRepo,
+		uow:               uow,
 		thirdPartyService: thirdpartyService,
+		pool:              pool,
+		approvalRepo:      approvalRepo,
+		approvalThreshold: approvalThreshold,
+		logger:            logger,
 	}
 }
@@ -0,0 +1,163 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: service.go
+
+// Package transactionsservice is a generated GoMock package.
+package transactionsservice
+
+import (
+	context "context"
+	http "net/http"
+	transaction "p-system/repositories/transaction"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockService is a mock of Service interface.
+type MockService struct {
+	ctrl     *gomock.Controller
+	recorder *MockServiceMockRecorder
+}
+
+// MockServiceMockRecorder is the mock recorder for MockService.
+type MockServiceMockRecorder struct {
+	mock *MockService
+}
+
+// NewMockService creates a new mock instance.
+func NewMockService(ctrl *gomock.Controller) *MockService {
+	mock := &MockService{ctrl: ctrl}
+	mock.recorder = &MockServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockService) EXPECT() *MockServiceMockRecorder {
+	return m.recorder
+}
+
+// FindTransaction mocks base method.
+func (m *MockService) FindTransaction(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "FindTransaction", w, r)
+}
+
+// FindTransaction indicates an expected call of FindTransaction.
+func (mr *MockServiceMockRecorder) FindTransaction(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindTransaction", reflect.TypeOf((*MockService)(nil).FindTransaction), w, r)
+}
+
+// GetTransaction mocks base method.
+func (m *MockService) GetTransaction(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "GetTransaction", w, r)
+}
+
+// GetTransaction indicates an expected call of GetTransaction.
+func (mr *MockServiceMockRecorder) GetTransaction(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransaction", reflect.TypeOf((*MockService)(nil).GetTransaction), w, r)
+}
+
+// HandleTransaction mocks base method.
+func (m *MockService) HandleTransaction(w http.ResponseWriter, r *http.Request) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "HandleTransaction", w, r)
+}
+
+// HandleTransaction indicates an expected call of HandleTransaction.
+func (mr *MockServiceMockRecorder) HandleTransaction(w, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleTransaction", reflect.TypeOf((*MockService)(nil).HandleTransaction), w, r)
+}
+
+// HandleTransactionRequest mocks base method.
+func (m *MockService) HandleTransactionRequest(ctx context.Context, req Request) (TransactionResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HandleTransactionRequest", ctx, req)
+	ret0, _ := ret[0].(TransactionResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HandleTransactionRequest indicates an expected call of HandleTransactionRequest.
+func (mr *MockServiceMockRecorder) HandleTransactionRequest(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleTransactionRequest", reflect.TypeOf((*MockService)(nil).HandleTransactionRequest), ctx, req)
+}
+
+// HandleTransfer mocks base method.
+func (m *MockService) HandleTransfer(ctx context.Context, t Transfer) (TransactionResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HandleTransfer", ctx, t)
+	ret0, _ := ret[0].(TransactionResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HandleTransfer indicates an expected call of HandleTransfer.
+func (mr *MockServiceMockRecorder) HandleTransfer(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleTransfer", reflect.TypeOf((*MockService)(nil).HandleTransfer), ctx, t)
+}
+
+// ProcessTransaction mocks base method.
+func (m *MockService) ProcessTransaction(ctx context.Context, id string) (TransactionResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProcessTransaction", ctx, id)
+	ret0, _ := ret[0].(TransactionResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProcessTransaction indicates an expected call of ProcessTransaction.
+func (mr *MockServiceMockRecorder) ProcessTransaction(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessTransaction", reflect.TypeOf((*MockService)(nil).ProcessTransaction), ctx, id)
+}
+
+// RetryTransaction mocks base method.
+func (m *MockService) RetryTransaction(ctx context.Context, id string) (TransactionResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryTransaction", ctx, id)
+	ret0, _ := ret[0].(TransactionResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RetryTransaction indicates an expected call of RetryTransaction.
+func (mr *MockServiceMockRecorder) RetryTransaction(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryTransaction", reflect.TypeOf((*MockService)(nil).RetryTransaction), ctx, id)
+}
+
+// ReverseTransaction mocks base method.
+func (m *MockService) ReverseTransaction(ctx context.Context, id string) (*transaction.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReverseTransaction", ctx, id)
+	ret0, _ := ret[0].(*transaction.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReverseTransaction indicates an expected call of ReverseTransaction.
+func (mr *MockServiceMockRecorder) ReverseTransaction(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReverseTransaction", reflect.TypeOf((*MockService)(nil).ReverseTransaction), ctx, id)
+}
+
+// SubmitTransactionRequest mocks base method.
+func (m *MockService) SubmitTransactionRequest(ctx context.Context, req Request) (*transaction.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubmitTransactionRequest", ctx, req)
+	ret0, _ := ret[0].(*transaction.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubmitTransactionRequest indicates an expected call of SubmitTransactionRequest.
+func (mr *MockServiceMockRecorder) SubmitTransactionRequest(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubmitTransactionRequest", reflect.TypeOf((*MockService)(nil).SubmitTransactionRequest), ctx, req)
+}
@@ -2,11 +2,18 @@ package transactionsservice
 
 import (
 	"context"
-	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
+	"p-system/logging"
+	"p-system/metrics"
+	"p-system/repositories/approval"
 	"p-system/repositories/transaction"
+	"p-system/repositories/unitofwork"
+	"p-system/repositories/wallet"
 	"p-system/services/thirdparty"
+	"p-system/utils"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,126 +25,579 @@ type TransactionResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
+// TransactionDetail is a transaction together with every status it has been
+// in, so clients can follow the outcome of a request.
+type TransactionDetail struct {
+	transaction.Transaction
+	StatusHistory []transaction.StatusChange `json:"status_history"`
+}
+
+// AcceptedResponse is returned for a transaction submitted in async mode.
+// The outcome can be polled at StatusURL.
+type AcceptedResponse struct {
+	TransactionID string `json:"transaction_id"`
+	Status        string `json:"status"`
+	StatusURL     string `json:"status_url"`
+}
+
 type Request struct {
-	Amount float64 `json:"amount" validate:"required"`
-	UserID string  `json:"user_id" validate:"required"`
+	Amount float64 `json:"amount" validate:"positive,cents"`
+	UserID string  `json:"user_id" validate:"required,uuid"`
 	//type required with one of credit or debit
 	Type      string `json:"type" validate:"required,oneof=credit debit"`
-	Reference string `json:"reference" validate:"required"`
+	Reference string `json:"reference" validate:"required,max=50,reference"`
+	// Version is the wallet version from If-Match, if any. The transaction is
+	// only made while the wallet is still at it; in async mode that is checked
+	// once, when the transaction is submitted.
+	Version int64 `json:"-"`
+	// ApprovalID is the approval the request was held for, set once it is
+	// approved so the request is made instead of being held again.
+	ApprovalID string `json:"-"`
 }
 
-func (s service) HandleTransactionRequest(req Request) (TransactionResponse, error) {
+// Transfer is a debit of one user and a credit of another of the same amount,
+// made together.
+type Transfer struct {
+	Debit  Request `json:"debit"`
+	Credit Request `json:"credit"`
+}
 
-	// Validate if user exists
-	user, err := s.userRepo.GetUserByID(req.UserID)
+// HeldError is returned for a request above the approval threshold, which is
+// held until someone other than its maker approves it instead of being made.
+type HeldError struct {
+	Approval *approval.Approval
+}
+
+func (e *HeldError) Error() string {
+	return "transaction is held for approval " + e.Approval.ID
+}
+
+func (s service) HandleTransactionRequest(ctx context.Context, req Request) (TransactionResponse, error) {
+	if resp, err := s.hold(ctx, approval.KindTransaction, req, req); err != nil {
+		return resp, err
+	}
+
+	_, resp, err := s.makeTransaction(ctx, req)
+	return resp, err
+}
+
+// HandleTransfer makes the debit of a transfer and then its credit. When the
+// credit cannot be made the debit is reversed, so the payer is never out of
+// pocket for a transfer the payee did not get. A transfer above the approval
+// threshold is held as a whole, so both legs are approved or rejected as one.
+func (s service) HandleTransfer(ctx context.Context, t Transfer) (TransactionResponse, error) {
+	if resp, err := s.hold(ctx, approval.KindTransfer, t.Debit, t); err != nil {
+		return resp, err
+	}
+
+	debit, resp, err := s.makeTransaction(ctx, t.Debit)
 	if err != nil {
+		return resp, err
+	}
+
+	if _, resp, err := s.makeTransaction(ctx, t.Credit); err != nil {
+		// Whatever the outcome, the debit is reversed even if the client has
+		// gone away in the meantime
+		if _, reverseErr := s.ReverseTransaction(context.WithoutCancel(ctx), debit.ID); reverseErr != nil {
+			s.logger.ErrorContext(ctx, "reversing transfer debit", "transaction_id", debit.ID, "error", reverseErr)
+		}
+		return resp, err
+	}
+
+	return TransactionResponse{Success: true, Message: "Transfer successful"}, nil
+}
+
+// makeTransaction records the transaction of a request and completes it, and
+// returns it if it was recorded.
+func (s service) makeTransaction(ctx context.Context, req Request) (*transaction.Transaction, TransactionResponse, error) {
+	wallet, transaction, resp, err := s.recordTransaction(ctx, req)
+	if err != nil {
+		metrics.Transactions.WithLabelValues(req.Type, "rejected").Inc()
+		return nil, resp, err
+	}
+
+	resp, err = s.completeTransaction(ctx, wallet, req.Version, transaction)
+	return transaction, resp, err
+}
+
+// SubmitTransactionRequest records the transaction as pending and returns it
+// without contacting the provider, for ProcessTransaction to complete later.
+func (s service) SubmitTransactionRequest(ctx context.Context, req Request) (*transaction.Transaction, error) {
+	if _, err := s.hold(ctx, approval.KindTransaction, req, req); err != nil {
+		return nil, err
+	}
+
+	_, transaction, _, err := s.recordTransaction(ctx, req)
+	if err != nil {
+		metrics.Transactions.WithLabelValues(req.Type, "rejected").Inc()
+		return nil, err
+	}
+
+	metrics.Transactions.WithLabelValues(req.Type, "pending").Inc()
+	return transaction, nil
+}
+
+// ProcessTransaction completes a pending transaction recorded by
+// SubmitTransactionRequest. The wallet is checked again since its balance or
+// status may have changed while the transaction was queued.
+func (s service) ProcessTransaction(ctx context.Context, id string) (TransactionResponse, error) {
+	transaction, err := s.transactionRepo.GetTransactionByID(ctx, id)
+	if err != nil {
+		return TransactionResponse{Success: false, Message: "Transaction not found"}, err
+	}
+	if logging.RequestID(ctx) == "" {
+		// Log under the ID of the request that submitted the transaction
+		ctx = logging.WithRequestID(ctx, transaction.RequestID)
+	}
+	if transaction.Status != "pending" {
+		return TransactionResponse{Success: false, Message: "Transaction is " + transaction.Status}, utils.Conflict("transaction is " + transaction.Status)
+	}
+
+	wallet, err := s.walletRepo.GetWalletByUserID(ctx, transaction.UserID)
+	if err != nil {
+		return TransactionResponse{Success: false, Message: "Wallet not found"}, err
+	}
+
+	if resp, err := checkWallet(wallet, transaction.Type, transaction.Amount); err != nil {
+		metrics.Transactions.WithLabelValues(transaction.Type, "failed").Inc()
+		if _, newErr := s.transactionRepo.UpdateTransactionToFailed(ctx, transaction.ID); newErr != nil {
+			s.logger.ErrorContext(ctx, "marking transaction failed", "transaction_id", transaction.ID, "error", newErr)
+			return TransactionResponse{Success: false, Message: "Failed to update transaction"}, newErr
+		}
+		return resp, err
+	}
+
+	return s.completeTransaction(ctx, wallet, 0, transaction)
+}
+
+// RetryTransaction completes a transaction left pending, as ProcessTransaction
+// does, unless the provider has its payment already. A payment the provider
+// has is settled without being made again, so a transaction whose processor
+// stopped after paying is never paid twice. When the provider cannot say,
+// the transaction is left pending.
+func (s service) RetryTransaction(ctx context.Context, id string) (TransactionResponse, error) {
+	transaction, err := s.transactionRepo.GetTransactionByID(ctx, id)
+	if err != nil {
+		return TransactionResponse{Success: false, Message: "Transaction not found"}, err
+	}
+	if logging.RequestID(ctx) == "" {
+		ctx = logging.WithRequestID(ctx, transaction.RequestID)
+	}
+	if transaction.Status != "pending" {
+		return TransactionResponse{Success: false, Message: "Transaction is " + transaction.Status}, utils.Conflict("transaction is " + transaction.Status)
+	}
+
+	_, err = s.thirdPartyService.GetTransaction(transaction.Reference, transaction.UserID)
+	var statusErr *thirdparty.StatusError
+	if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound {
+		return s.ProcessTransaction(ctx, id)
+	}
+	if err != nil {
+		s.logger.WarnContext(ctx, "looking up payment", "transaction_id", transaction.ID, "error", err)
+		return TransactionResponse{Success: false, Message: "Failed to look up payment"}, fmt.Errorf("%w: %v", utils.ErrProviderFailure, err)
+	}
+
+	s.logger.InfoContext(ctx, "payment made already", "transaction_id", transaction.ID)
+	ctx = context.WithoutCancel(ctx)
+
+	wallet, err := s.walletRepo.GetWalletByUserID(ctx, transaction.UserID)
+	if err != nil {
+		return TransactionResponse{Success: false, Message: "Wallet not found"}, err
+	}
+	if resp, err := checkWallet(wallet, transaction.Type, transaction.Amount); err != nil {
+		s.reversePayment(ctx, transaction)
+		return resp, err
+	}
+
+	return s.settleTransaction(ctx, wallet, 0, transaction)
+}
+
+// hold holds payload, made with req, for approval as kind and returns a
+// *HeldError if req is above the approval threshold, unless it has been
+// approved already. Every way of making a transaction goes through it. The
+// maker is the operator carried by ctx; without one the request is refused,
+// since no one could be kept from approving it themselves.
+func (s service) hold(ctx context.Context, kind string, req Request, payload interface{}) (TransactionResponse, error) {
+	amount := utils.Cents(req.Amount)
+	if s.approvalThreshold <= 0 || amount <= s.approvalThreshold || req.ApprovalID != "" {
+		return TransactionResponse{}, nil
+	}
+
+	maker := utils.OperatorFrom(ctx)
+	if maker == "" {
+		return TransactionResponse{Success: false, Message: "Operator required"}, utils.Unauthorized("an operator must make requests above the approval threshold")
+	}
+
+	if _, err := s.userRepo.GetUserByID(ctx, req.UserID); err != nil {
 		return TransactionResponse{Success: false, Message: "User not found"}, err
 	}
 
+	a, err := approval.NewApproval(kind, maker, amount, payload)
+	if err == nil {
+		a, err = s.approvalRepo.Create(ctx, a)
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "holding transaction", "reference", req.Reference, "error", err)
+		return TransactionResponse{Success: false, Message: "Failed to hold transaction"}, err
+	}
+
+	metrics.Transactions.WithLabelValues(req.Type, "held").Inc()
+	s.logger.InfoContext(ctx, "held transaction", "approval_id", a.ID, "reference", req.Reference, "maker", maker)
+	return TransactionResponse{Success: false, Message: "Transaction held for approval"}, &HeldError{Approval: a}
+}
+
+// recordTransaction checks the user and wallet of a request and records its
+// transaction as pending, under the request ID of ctx if it has one.
+func (s service) recordTransaction(ctx context.Context, req Request) (*wallet.Wallet, *transaction.Transaction, TransactionResponse, error) {
+
+	// Validate if user exists
+	if _, err := s.userRepo.GetUserByID(ctx, req.UserID); err != nil {
+		return nil, nil, TransactionResponse{Success: false, Message: "User not found"}, err
+	}
+
 	// Validate if user has a wallet
-	wallet, err := s.walletRepo.GetWalletByUserID(req.UserID)
+	wallet, err := s.walletRepo.GetWalletByUserID(ctx, req.UserID)
 	if err != nil {
-		return TransactionResponse{Success: false, Message: "Wallet not found"}, err
+		return nil, nil, TransactionResponse{Success: false, Message: "Wallet not found"}, err
 	}
 
-	// Convert balance to float64 by dividing by 100
-	balance := float64(wallet.Balance) / 100
+	// Check the wallet is still at the version the client expects
+	if req.Version != 0 && wallet.Version != req.Version {
+		return nil, nil, TransactionResponse{Success: false, Message: "Wallet has been modified"}, utils.PreconditionFailed("wallet has been modified")
+	}
 
-	// Convert amount to int64 by multiplying by 100
-	amount := req.Amount * 100
+	amount := utils.Cents(req.Amount)
 
-	// If type is debit, check if user has enough balance
-	if req.Type == "debit" {
-		if balance < req.Amount {
-			return TransactionResponse{Success: false, Message: "Insufficient balance"}, nil
-		}
+	if resp, err := checkWallet(wallet, req.Type, amount); err != nil {
+		return nil, nil, resp, err
 	}
 
-	requestID := uuid.NewString()
+	requestID := logging.RequestID(ctx)
+	if requestID == "" {
+		requestID = uuid.NewString()
+	}
 
 	// Create transaction
-	transaction := transaction.NewTransaction(req.UserID, requestID, req.Reference, req.Type, int64(amount))
+	transaction := transaction.NewTransaction(req.UserID, requestID, req.Reference, req.Type, amount)
 
 	// Create transaction
-	transaction, err = s.transactionRepo.Create(transaction)
+	transaction, err = s.transactionRepo.Create(ctx, transaction)
 	if err != nil {
-		log.Println("error", err)
-		return TransactionResponse{Success: false, Message: "Failed to create transaction"}, err
+		s.logger.ErrorContext(ctx, "creating transaction", "reference", req.Reference, "error", err)
+		return nil, nil, TransactionResponse{Success: false, Message: "Failed to create transaction"}, err
+	}
+
+	return wallet, transaction, TransactionResponse{}, nil
+}
+
+// checkWallet reports whether a wallet can take a transaction of the given
+// type and amount in minor units.
+func checkWallet(wallet *wallet.Wallet, transactionType string, amount int64) (TransactionResponse, error) {
+	// Frozen wallets cannot be credited or debited
+	if wallet.Status == "frozen" {
+		return TransactionResponse{Success: false, Message: "Wallet is frozen"}, utils.ErrWalletFrozen
+	}
+
+	// If type is debit, check if user has enough balance
+	if transactionType == "debit" && wallet.Balance < amount {
+		return TransactionResponse{Success: false, Message: "Insufficient balance"}, utils.ErrInsufficientFunds
 	}
 
+	return TransactionResponse{}, nil
+}
+
+// completeTransaction makes the payment of a pending transaction with the
+// provider and applies it to the wallet, provided the wallet is still at
+// version if that is not 0. The wallet is not locked during the payment, so a
+// payment the wallet can no longer take is reversed.
+func (s service) completeTransaction(ctx context.Context, wallet *wallet.Wallet, version int64, transaction *transaction.Transaction) (TransactionResponse, error) {
+
 	// Send request to third party to make payment with context timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	paymentCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	// Make payment
-	_, err = s.thirdPartyService.MakePayment(thirdparty.Transaction{
-		AccountID: user.ID,
-		Reference: req.Reference,
-		Amount:    req.Amount,
-	}, ctx)
+	// Convert amount to float64 by dividing by 100
+	_, err := s.thirdPartyService.MakePayment(thirdparty.Transaction{
+		AccountID: transaction.UserID,
+		Reference: transaction.Reference,
+		Amount:    float64(transaction.Amount) / 100,
+	}, paymentCtx)
+
+	// Whatever the outcome, it is recorded even if the client has gone away
+	// in the meantime
+	ctx = context.WithoutCancel(ctx)
 
 	if err != nil {
-		log.Println("error", err)
-		_, newErr := s.transactionRepo.UpdateTransactionToFailed(transaction.ID)
+		s.logger.WarnContext(ctx, "payment failed", "transaction_id", transaction.ID, "error", err)
+		metrics.Transactions.WithLabelValues(transaction.Type, "failed").Inc()
+		_, newErr := s.transactionRepo.UpdateTransactionToFailed(ctx, transaction.ID)
 		if newErr != nil {
-			log.Println("error", err)
+			s.logger.ErrorContext(ctx, "marking transaction failed", "transaction_id", transaction.ID, "error", newErr)
 			return TransactionResponse{Success: false, Message: "Failed to update transaction"}, newErr
 		}
 
-		return TransactionResponse{Success: false, Message: "Failed to make payment"}, err
+		return TransactionResponse{Success: false, Message: "Failed to make payment"}, fmt.Errorf("%w: %v", utils.ErrProviderFailure, err)
 	}
 
-	// Update wallet
-	if req.Type == "debit" {
-		// Call debit wallet
-		wallet, err = s.walletRepo.DebitWallet(wallet, *transaction, int64(amount))
+	return s.settleTransaction(ctx, wallet, version, transaction)
+}
 
-		if err != nil {
-			log.Println("error", err)
-			return TransactionResponse{Success: false, Message: "Failed to debit wallet"}, err
+// settleTransaction completes a transaction the provider has made the payment
+// of and updates its wallet together, so neither happens without the other.
+// A payment the wallet cannot take is refunded.
+func (s service) settleTransaction(ctx context.Context, wallet *wallet.Wallet, version int64, transaction *transaction.Transaction) (TransactionResponse, error) {
+	message := "Failed to credit wallet"
+	if transaction.Type == "debit" {
+		message = "Failed to debit wallet"
+	}
+	// The wallet was read before the payment, so only a version the client
+	// asked for is held to
+	wallet.Version = version
+	err := s.uow.Do(ctx, func(ctx context.Context, repos unitofwork.Repositories) error {
+		if _, err := repos.Transactions.UpdateTransactionToCompleted(ctx, transaction.ID); err != nil {
+			return err
 		}
 
-	} else if req.Type == "credit" {
-		// Call credit wallet
-		wallet, err = s.walletRepo.CreditWallet(wallet, *transaction, int64(amount))
+		if transaction.Type == "debit" {
+			_, err := repos.Wallets.DebitWallet(ctx, wallet, *transaction, transaction.Amount)
+			return err
+		}
+		_, err := repos.Wallets.CreditWallet(ctx, wallet, *transaction, transaction.Amount)
+		return err
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "updating wallet", "transaction_id", transaction.ID, "wallet_id", wallet.ID, "error", err)
+		s.reversePayment(ctx, transaction)
+		return TransactionResponse{Success: false, Message: message}, err
+	}
+
+	metrics.Transactions.WithLabelValues(transaction.Type, "completed").Inc()
+	return TransactionResponse{Success: true, Message: "Transaction successful"}, nil
+}
+
+// reversePayment refunds the payment of a transaction the provider has made
+// but its wallet cannot take, as when the wallet has changed or run short of
+// funds since it was checked, and marks the transaction failed. A transaction
+// settled by another processor in the meantime is left as it is, payment and
+// all.
+func (s service) reversePayment(ctx context.Context, transaction *transaction.Transaction) {
+	// Marking it failed first makes sure nothing else settles it
+	if _, err := s.transactionRepo.UpdateTransactionToFailed(ctx, transaction.ID); err != nil {
+		if !errors.Is(err, utils.ErrConflict) {
+			s.logger.ErrorContext(ctx, "marking transaction failed", "transaction_id", transaction.ID, "error", err)
+		}
+		return
+	}
+	metrics.Transactions.WithLabelValues(transaction.Type, "failed").Inc()
+
+	s.refundPayment(ctx, transaction)
+}
 
+// ReverseTransaction reverses a completed transaction. A completed
+// transaction of the opposite type, under the reference returned by
+// ReversalReference, puts the wallet back as it was, and the payment is
+// refunded with the provider. A transaction reversed already is not reversed
+// again; its reversal is returned. Like adjustments, reversals can change a
+// frozen wallet but cannot overdraw it.
+func (s service) ReverseTransaction(ctx context.Context, id string) (*transaction.Transaction, error) {
+	t, err := s.transactionRepo.GetTransactionByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if t.Status != "completed" {
+		return nil, utils.Conflict("transaction is " + t.Status)
+	}
+
+	kind := "credit"
+	if t.Type == "credit" {
+		kind = "debit"
+	}
+	reference := ReversalReference(t.Reference)
+	reason := "reversal of transaction " + t.ID
+
+	var reversal *transaction.Transaction
+	err = s.uow.Do(ctx, func(ctx context.Context, repos unitofwork.Repositories) error {
+		w, err := repos.Wallets.GetWalletByUserID(ctx, t.UserID)
 		if err != nil {
-			log.Println("error", err)
-			return TransactionResponse{Success: false, Message: "Failed to credit wallet"}, err
+			return err
 		}
 
+		r := transaction.NewTransaction(t.UserID, "reversal:"+t.ID, reference, kind, t.Amount)
+		r.Status = "completed"
+		r.Reason = &reason
+		if reversal, err = repos.Transactions.Create(ctx, r); err != nil {
+			return err
+		}
+
+		if kind == "debit" {
+			_, err = repos.Wallets.DebitWallet(ctx, w, *reversal, t.Amount)
+		} else {
+			_, err = repos.Wallets.CreditWallet(ctx, w, *reversal, t.Amount)
+		}
+		return err
+	})
+	if errors.Is(err, utils.ErrDuplicateReference) {
+		return s.transactionRepo.GetTransactionByReference(ctx, reference)
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "reversing transaction", "transaction_id", t.ID, "error", err)
+		return nil, err
 	}
 
-	return TransactionResponse{Success: true, Message: "Transaction successful"}, nil
+	metrics.Transactions.WithLabelValues(t.Type, "reversed").Inc()
+	s.logger.InfoContext(ctx, "reversed transaction", "transaction_id", t.ID, "reversal_id", reversal.ID)
+
+	s.refundPayment(ctx, t)
+	return reversal, nil
+}
+
+// ReversalReference returns the reference a transaction with the given
+// reference is reversed under.
+func ReversalReference(reference string) string {
+	return reference + "-reversal"
+}
+
+// refundPayment refunds the payment of a transaction with the provider. A
+// refund that fails is only logged; reconciliation reports the payment the
+// provider still has.
+func (s service) refundPayment(ctx context.Context, transaction *transaction.Transaction) {
+	refundCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// Convert amount to float64 by dividing by 100
+	_, err := s.thirdPartyService.RefundPayment(thirdparty.Transaction{
+		AccountID: transaction.UserID,
+		Reference: transaction.Reference,
+		Amount:    float64(transaction.Amount) / 100,
+	}, refundCtx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "refunding payment", "transaction_id", transaction.ID, "error", err)
+		return
+	}
+
+	s.logger.InfoContext(ctx, "refunded payment", "transaction_id", transaction.ID)
 }
 
 func (s service) HandleTransaction(w http.ResponseWriter, r *http.Request) {
 
 	var req Request
 
-	//decode request body
-	err := json.NewDecoder(r.Body).Decode(&req)
+	//decode and validate request body
+	if err := utils.Decode(r, &req); err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	// Clients make the transaction conditional on the wallet version with
+	// If-Match
+	version, err := utils.IfMatch(r)
 	if err != nil {
-		sendJSONResponse(w, http.StatusBadRequest, map[string]string{"error": "Invalid request payload"})
+		utils.RespondError(w, err)
 		return
+	}
+	req.Version = version
 
+	// Clients opt in to async mode with the Prefer header from RFC 7240
+	if s.pool != nil && strings.Contains(r.Header.Get("Prefer"), "respond-async") {
+		s.submitTransaction(w, r, req)
+		return
 	}
 
 	//call service method
-	resp, err := s.HandleTransactionRequest(req)
+	resp, err := s.HandleTransactionRequest(r.Context(), req)
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, resp)
+
+}
+
+// submitTransaction records the transaction as pending, queues it on the
+// pool and responds with 202 Accepted.
+func (s service) submitTransaction(w http.ResponseWriter, r *http.Request, req Request) {
+	t, err := s.SubmitTransactionRequest(r.Context(), req)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	if err := s.pool.Enqueue(t.ID); err != nil {
+		// Never leave a transaction pending that nothing will process
+		if _, newErr := s.transactionRepo.UpdateTransactionToFailed(r.Context(), t.ID); newErr != nil {
+			s.logger.ErrorContext(r.Context(), "marking transaction failed", "transaction_id", t.ID, "error", newErr)
+		}
+		utils.RespondError(w, &utils.DomainError{Code: utils.CodeUnavailable, Message: err.Error()})
+		return
+	}
+
+	statusURL := "/transactions/" + t.ID
+	w.Header().Set("Location", statusURL)
+	w.Header().Set("Preference-Applied", "respond-async")
+	utils.SendJSONResponse(w, http.StatusAccepted, AcceptedResponse{TransactionID: t.ID, Status: t.Status, StatusURL: statusURL})
+}
+
+// respondError responds with err, or with 202 Accepted and the approval if
+// the request was held for approval instead. The wallet version of If-Match is
+// not held to once it is approved.
+func respondError(w http.ResponseWriter, err error) {
+	var held *HeldError
+	if errors.As(err, &held) {
+		w.Header().Set("Location", "/approvals/"+held.Approval.ID)
+		utils.SendJSONResponse(w, http.StatusAccepted, held.Approval)
+		return
+	}
+
+	utils.RespondError(w, err)
+}
 
+// GetTransaction returns the transaction with the id in the path.
+func (s service) GetTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.PathID(r)
 	if err != nil {
-		sendJSONResponse(w, http.StatusInternalServerError, resp)
+		utils.RespondError(w, err)
+		return
+	}
+
+	t, err := s.transactionRepo.GetTransactionByID(r.Context(), id)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	s.respondWithDetail(w, r, t)
+}
+
+// FindTransaction returns the transaction with the reference given in the
+// reference query parameter.
+func (s service) FindTransaction(w http.ResponseWriter, r *http.Request) {
+	params := struct {
+		Reference string `json:"reference" validate:"required"`
+	}{Reference: r.URL.Query().Get("reference")}
+	if err := utils.Validate(params); err != nil {
+		utils.RespondError(w, err)
 		return
 	}
 
-	sendJSONResponse(w, http.StatusOK, resp)
+	t, err := s.transactionRepo.GetTransactionByReference(r.Context(), params.Reference)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
 
+	s.respondWithDetail(w, r, t)
 }
 
-// sendJSONResponse sends a JSON response with the specified status code and data
-func sendJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(data)
+// respondWithDetail sends t with its status history.
+func (s service) respondWithDetail(w http.ResponseWriter, r *http.Request, t *transaction.Transaction) {
+	history, err := s.transactionRepo.GetStatusHistory(r.Context(), t.ID)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, TransactionDetail{Transaction: *t, StatusHistory: history})
 }
@@ -1,15 +1,31 @@
 package transactionsservice
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"p-system/fixtures"
+	"p-system/logging"
+	"p-system/repositories/approval"
+	"p-system/repositories/memory"
 	"p-system/repositories/transaction"
+	"p-system/repositories/unitofwork"
+	"p-system/repositories/unitofwork/unitofworktest"
 	"p-system/repositories/user"
 	"p-system/repositories/wallet"
 	"p-system/services/thirdparty"
+	"p-system/utils"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDebitHandleTransactionRequest(t *testing.T) {
@@ -57,10 +73,11 @@ func TestDebitHandleTransactionRequest(t *testing.T) {
 	}
 
 	// Set up expectations
-	mockUserRepo.EXPECT().GetUserByID(req.UserID).Return(&mockUser, nil)
-	mockWalletRepo.EXPECT().GetWalletByUserID(req.UserID).Return(&mockWallet, nil)
-	mockTransactionRepo.EXPECT().Create(gomock.Any()).Return(&mockTransaction, nil)
-	mockWalletRepo.EXPECT().DebitWallet(&mockWallet, gomock.Any(), mockTransaction.Amount).Return(&mockWallet, nil)
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), req.UserID).Return(&mockUser, nil)
+	mockWalletRepo.EXPECT().GetWalletByUserID(gomock.Any(), req.UserID).Return(&mockWallet, nil)
+	mockTransactionRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&mockTransaction, nil)
+	mockTransactionRepo.EXPECT().UpdateTransactionToCompleted(gomock.Any(), mockTransaction.ID).Return(&mockTransaction, nil)
+	mockWalletRepo.EXPECT().DebitWallet(gomock.Any(), &mockWallet, gomock.Any(), mockTransaction.Amount).Return(&mockWallet, nil)
 	mockThirdParty.EXPECT().MakePayment(gomock.Any(), gomock.Any()).Return(&mockthirdPartyTransaction, nil)
 
 	// Create the service with mocked dependencies
@@ -68,11 +85,13 @@ func TestDebitHandleTransactionRequest(t *testing.T) {
 		userRepo:          mockUserRepo,
 		walletRepo:        mockWalletRepo,
 		transactionRepo:   mockTransactionRepo,
+		uow:               unitofworktest.Direct(unitofwork.Repositories{Wallets: mockWalletRepo, Transactions: mockTransactionRepo}),
 		thirdPartyService: mockThirdParty,
+		logger:            logging.Discard(),
 	}
 
 	// Call the method
-	resp, err := svc.HandleTransactionRequest(req)
+	resp, err := svc.HandleTransactionRequest(context.Background(), req)
 
 	// Check the result
 	assert.NoError(t, err)
@@ -125,10 +144,11 @@ func TestCreditHandleTransactionRequest(t *testing.T) {
 	}
 
 	// Set up expectations
-	mockUserRepo.EXPECT().GetUserByID(req.UserID).Return(&mockUser, nil)
-	mockWalletRepo.EXPECT().GetWalletByUserID(req.UserID).Return(&mockWallet, nil)
-	mockTransactionRepo.EXPECT().Create(gomock.Any()).Return(&mockTransaction, nil)
-	mockWalletRepo.EXPECT().CreditWallet(&mockWallet, gomock.Any(), mockTransaction.Amount).Return(&mockWallet, nil)
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), req.UserID).Return(&mockUser, nil)
+	mockWalletRepo.EXPECT().GetWalletByUserID(gomock.Any(), req.UserID).Return(&mockWallet, nil)
+	mockTransactionRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&mockTransaction, nil)
+	mockTransactionRepo.EXPECT().UpdateTransactionToCompleted(gomock.Any(), mockTransaction.ID).Return(&mockTransaction, nil)
+	mockWalletRepo.EXPECT().CreditWallet(gomock.Any(), &mockWallet, gomock.Any(), mockTransaction.Amount).Return(&mockWallet, nil)
 	mockThirdParty.EXPECT().MakePayment(gomock.Any(), gomock.Any()).Return(&mockthirdPartyTransaction, nil)
 
 	// Create the service with mocked dependencies
@@ -136,11 +156,13 @@ func TestCreditHandleTransactionRequest(t *testing.T) {
 		userRepo:          mockUserRepo,
 		walletRepo:        mockWalletRepo,
 		transactionRepo:   mockTransactionRepo,
+		uow:               unitofworktest.Direct(unitofwork.Repositories{Wallets: mockWalletRepo, Transactions: mockTransactionRepo}),
 		thirdPartyService: mockThirdParty,
+		logger:            logging.Discard(),
 	}
 
 	// Call the method
-	resp, err := svc.HandleTransactionRequest(req)
+	resp, err := svc.HandleTransactionRequest(context.Background(), req)
 
 	// Check the result
 	assert.NoError(t, err)
@@ -164,15 +186,16 @@ func TestHandleTransactionRequest_UserNotFound(t *testing.T) {
 	}
 
 	// Set up expectations
-	mockUserRepo.EXPECT().GetUserByID(req.UserID).Return(nil, assert.AnError)
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), req.UserID).Return(nil, assert.AnError)
 
 	// Create the service with mocked dependencies
 	svc := service{
 		userRepo: mockUserRepo,
+		logger:   logging.Discard(),
 	}
 
 	// Call the method
-	resp, err := svc.HandleTransactionRequest(req)
+	resp, err := svc.HandleTransactionRequest(context.Background(), req)
 
 	// Check the result
 	assert.Error(t, err)
@@ -202,17 +225,18 @@ func TestHandleTransactionRequest_WalletNotFound(t *testing.T) {
 	}
 
 	// Set up expectations
-	mockUserRepo.EXPECT().GetUserByID(req.UserID).Return(&mockUser, nil)
-	mockWalletRepo.EXPECT().GetWalletByUserID(req.UserID).Return(nil, assert.AnError)
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), req.UserID).Return(&mockUser, nil)
+	mockWalletRepo.EXPECT().GetWalletByUserID(gomock.Any(), req.UserID).Return(nil, assert.AnError)
 
 	// Create the service with mocked dependencies
 	svc := service{
 		userRepo:   mockUserRepo,
 		walletRepo: mockWalletRepo,
+		logger:     logging.Discard(),
 	}
 
 	// Call the method
-	resp, err := svc.HandleTransactionRequest(req)
+	resp, err := svc.HandleTransactionRequest(context.Background(), req)
 
 	// Check the result
 	assert.Error(t, err)
@@ -248,20 +272,21 @@ func TestHandleTransactionRequest_InsufficientBalance(t *testing.T) {
 	}
 
 	// Set up expectations
-	mockUserRepo.EXPECT().GetUserByID(req.UserID).Return(&mockUser, nil)
-	mockWalletRepo.EXPECT().GetWalletByUserID(req.UserID).Return(&mockWallet, nil)
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), req.UserID).Return(&mockUser, nil)
+	mockWalletRepo.EXPECT().GetWalletByUserID(gomock.Any(), req.UserID).Return(&mockWallet, nil)
 
 	// Create the service with mocked dependencies
 	svc := service{
 		userRepo:   mockUserRepo,
 		walletRepo: mockWalletRepo,
+		logger:     logging.Discard(),
 	}
 
 	// Call the method
-	resp, err := svc.HandleTransactionRequest(req)
+	resp, err := svc.HandleTransactionRequest(context.Background(), req)
 
 	// Check the result
-	assert.NoError(t, err)
+	assert.ErrorIs(t, err, utils.ErrInsufficientFunds)
 	assert.False(t, resp.Success)
 	assert.Equal(t, "Insufficient balance", resp.Message)
 }
@@ -295,19 +320,20 @@ func TestHandleTransactionRequest_FailedToCreateTransaction(t *testing.T) {
 	}
 
 	// Set up expectations
-	mockUserRepo.EXPECT().GetUserByID(req.UserID).Return(&mockUser, nil)
-	mockWalletRepo.EXPECT().GetWalletByUserID(req.UserID).Return(&mockWallet, nil)
-	mockTransactionRepo.EXPECT().Create(gomock.Any()).Return(nil, assert.AnError)
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), req.UserID).Return(&mockUser, nil)
+	mockWalletRepo.EXPECT().GetWalletByUserID(gomock.Any(), req.UserID).Return(&mockWallet, nil)
+	mockTransactionRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, assert.AnError)
 
 	// Create the service with mocked dependencies
 	svc := service{
 		userRepo:        mockUserRepo,
 		walletRepo:      mockWalletRepo,
 		transactionRepo: mockTransactionRepo,
+		logger:          logging.Discard(),
 	}
 
 	// Call the method
-	resp, err := svc.HandleTransactionRequest(req)
+	resp, err := svc.HandleTransactionRequest(context.Background(), req)
 
 	// Check the result
 	assert.Error(t, err)
@@ -354,22 +380,27 @@ func TestHandleTransactionRequest_FailedToDebitWallet(t *testing.T) {
 	}
 
 	// Set up expectations
-	mockUserRepo.EXPECT().GetUserByID(req.UserID).Return(&mockUser, nil)
-	mockWalletRepo.EXPECT().GetWalletByUserID(req.UserID).Return(&mockWallet, nil)
-	mockTransactionRepo.EXPECT().Create(gomock.Any()).Return(&mockTransaction, nil)
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), req.UserID).Return(&mockUser, nil)
+	mockWalletRepo.EXPECT().GetWalletByUserID(gomock.Any(), req.UserID).Return(&mockWallet, nil)
+	mockTransactionRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&mockTransaction, nil)
 	mockThirdPartyRepo.EXPECT().MakePayment(gomock.Any(), gomock.Any()).Return(nil, nil)
-	mockWalletRepo.EXPECT().DebitWallet(&mockWallet, gomock.Any(), mockTransaction.Amount).Return(nil, assert.AnError)
+	mockTransactionRepo.EXPECT().UpdateTransactionToCompleted(gomock.Any(), mockTransaction.ID).Return(&mockTransaction, nil)
+	mockWalletRepo.EXPECT().DebitWallet(gomock.Any(), &mockWallet, gomock.Any(), mockTransaction.Amount).Return(nil, assert.AnError)
+	mockTransactionRepo.EXPECT().UpdateTransactionToFailed(gomock.Any(), mockTransaction.ID).Return(&mockTransaction, nil)
+	mockThirdPartyRepo.EXPECT().RefundPayment(gomock.Any(), gomock.Any()).Return(nil, nil)
 
 	// Create the service with mocked dependencies
 	svc := service{
 		userRepo:          mockUserRepo,
 		walletRepo:        mockWalletRepo,
 		transactionRepo:   mockTransactionRepo,
+		uow:               unitofworktest.Direct(unitofwork.Repositories{Wallets: mockWalletRepo, Transactions: mockTransactionRepo}),
 		thirdPartyService: mockThirdPartyRepo,
+		logger:            logging.Discard(),
 	}
 
 	// Call the method
-	resp, err := svc.HandleTransactionRequest(req)
+	resp, err := svc.HandleTransactionRequest(context.Background(), req)
 
 	// Check the result
 	assert.Error(t, err)
@@ -377,6 +408,62 @@ func TestHandleTransactionRequest_FailedToDebitWallet(t *testing.T) {
 	assert.Equal(t, "Failed to debit wallet", resp.Message)
 }
 
+func TestHandleTransactionRequest_ReversesPaymentTheWalletCannotTake(t *testing.T) {
+	tests := []struct {
+		name       string
+		walletErr  error
+		failedErr  error
+		wantRefund bool
+	}{
+		// The wallet changed or ran short of funds while the provider was
+		// paid
+		{name: "wallet modified", walletErr: utils.PreconditionFailed("wallet has been modified"), wantRefund: true},
+		{name: "insufficient funds", walletErr: utils.ErrInsufficientFunds, wantRefund: true},
+		// Another processor settled it, so the payment is theirs
+		{name: "settled elsewhere", walletErr: utils.Conflict("transaction is completed"), failedErr: utils.Conflict("transaction is completed")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockUserRepo := user.NewMockRepository(ctrl)
+			mockWalletRepo := wallet.NewMockRepository(ctrl)
+			mockTransactionRepo := transaction.NewMockRepository(ctrl)
+			mockThirdParty := thirdparty.NewMockService(ctrl)
+
+			mockWallet := wallet.Wallet{ID: "wallet123", UserID: "user123", Balance: 20000, Version: 3}
+			mockTransaction := transaction.Transaction{ID: "tx1", UserID: "user123", Reference: "ref123", Type: "debit", Amount: 10000, Status: "pending"}
+
+			mockUserRepo.EXPECT().GetUserByID(gomock.Any(), "user123").Return(&user.User{ID: "user123"}, nil)
+			mockWalletRepo.EXPECT().GetWalletByUserID(gomock.Any(), "user123").Return(&mockWallet, nil)
+			mockTransactionRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&mockTransaction, nil)
+			mockThirdParty.EXPECT().MakePayment(gomock.Any(), gomock.Any()).Return(&thirdparty.Transaction{}, nil)
+			mockTransactionRepo.EXPECT().UpdateTransactionToCompleted(gomock.Any(), "tx1").Return(&mockTransaction, nil)
+			mockWalletRepo.EXPECT().DebitWallet(gomock.Any(), gomock.Any(), gomock.Any(), int64(10000)).Return(nil, tt.walletErr)
+			mockTransactionRepo.EXPECT().UpdateTransactionToFailed(gomock.Any(), "tx1").Return(&mockTransaction, tt.failedErr)
+			if tt.wantRefund {
+				mockThirdParty.EXPECT().RefundPayment(thirdparty.Transaction{AccountID: "user123", Reference: "ref123", Amount: 100}, gomock.Any()).Return(&thirdparty.Transaction{}, nil)
+			}
+
+			svc := service{
+				userRepo:          mockUserRepo,
+				walletRepo:        mockWalletRepo,
+				transactionRepo:   mockTransactionRepo,
+				uow:               unitofworktest.Direct(unitofwork.Repositories{Wallets: mockWalletRepo, Transactions: mockTransactionRepo}),
+				thirdPartyService: mockThirdParty,
+				logger:            logging.Discard(),
+			}
+
+			resp, err := svc.HandleTransactionRequest(context.Background(), Request{Amount: 100, UserID: "user123", Type: "debit", Reference: "ref123", Version: 3})
+
+			assert.ErrorIs(t, err, tt.walletErr)
+			assert.False(t, resp.Success)
+		})
+	}
+}
+
 func TestHandleTransactionRequest_FailedToMakePayment(t *testing.T) {
 	// Initialize gomock controller
 	ctrl := gomock.NewController(t)
@@ -416,12 +503,12 @@ func TestHandleTransactionRequest_FailedToMakePayment(t *testing.T) {
 	}
 
 	// Set up expectations
-	mockUserRepo.EXPECT().GetUserByID(req.UserID).Return(&mockUser, nil)
-	mockWalletRepo.EXPECT().GetWalletByUserID(req.UserID).Return(&mockWallet, nil)
-	mockTransactionRepo.EXPECT().Create(gomock.Any()).Return(&mockTransaction, nil)
-	mockWalletRepo.EXPECT().DebitWallet(&mockWallet, gomock.Any(), mockTransaction.Amount).Return(&mockWallet, nil).Times(0)
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), req.UserID).Return(&mockUser, nil)
+	mockWalletRepo.EXPECT().GetWalletByUserID(gomock.Any(), req.UserID).Return(&mockWallet, nil)
+	mockTransactionRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&mockTransaction, nil)
+	mockWalletRepo.EXPECT().DebitWallet(gomock.Any(), &mockWallet, gomock.Any(), mockTransaction.Amount).Return(&mockWallet, nil).Times(0)
 	mockThirdPartyRepo.EXPECT().MakePayment(gomock.Any(), gomock.Any()).Return(nil, assert.AnError)
-	mockTransactionRepo.EXPECT().UpdateTransactionToFailed(mockTransaction.ID).Return(&mockTransaction, nil)
+	mockTransactionRepo.EXPECT().UpdateTransactionToFailed(gomock.Any(), mockTransaction.ID).Return(&mockTransaction, nil)
 
 	// Create the service with mocked dependencies
 	svc := service{
@@ -429,10 +516,11 @@ func TestHandleTransactionRequest_FailedToMakePayment(t *testing.T) {
 		walletRepo:        mockWalletRepo,
 		transactionRepo:   mockTransactionRepo,
 		thirdPartyService: mockThirdPartyRepo,
+		logger:            logging.Discard(),
 	}
 
 	// Call the method
-	resp, err := svc.HandleTransactionRequest(req)
+	resp, err := svc.HandleTransactionRequest(context.Background(), req)
 
 	// Check the result
 
@@ -441,3 +529,825 @@ func TestHandleTransactionRequest_FailedToMakePayment(t *testing.T) {
 	assert.Equal(t, "Failed to make payment", resp.Message)
 
 }
+
+func TestHandleTransactionRequest_FrozenWallet(t *testing.T) {
+	// Initialize gomock controller
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Mock repositories and third party
+	mockUserRepo := user.NewMockRepository(ctrl)
+	mockWalletRepo := wallet.NewMockRepository(ctrl)
+
+	// Create a sample request
+	req := Request{
+		Amount: 100.0,
+		UserID: "user123",
+		Type:   "credit",
+	}
+
+	// Create a sample user
+	mockUser := user.User{
+		ID: "user123",
+	}
+
+	// Create a sample frozen wallet
+	mockWallet := wallet.Wallet{
+		UserID:  "user123",
+		Balance: 20000, // $200.00 in cents
+		Status:  wallet.StatusFrozen,
+	}
+
+	// Set up expectations
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), req.UserID).Return(&mockUser, nil)
+	mockWalletRepo.EXPECT().GetWalletByUserID(gomock.Any(), req.UserID).Return(&mockWallet, nil)
+
+	// Create the service with mocked dependencies
+	svc := service{
+		userRepo:   mockUserRepo,
+		walletRepo: mockWalletRepo,
+		logger:     logging.Discard(),
+	}
+
+	// Call the method
+	resp, err := svc.HandleTransactionRequest(context.Background(), req)
+
+	// Check the result
+	assert.ErrorIs(t, err, utils.ErrWalletFrozen)
+	assert.False(t, resp.Success)
+	assert.Equal(t, "Wallet is frozen", resp.Message)
+}
+
+func TestHandleTransaction_ErrorStatuses(t *testing.T) {
+	userID := "9b2f6d1e-3c4a-4f5b-8e7d-1a2b3c4d5e6f"
+
+	tests := []struct {
+		name   string
+		setup  func(*user.MockRepository, *wallet.MockRepository)
+		status int
+		code   string
+	}{
+		{
+			name: "user not found",
+			setup: func(u *user.MockRepository, _ *wallet.MockRepository) {
+				u.EXPECT().GetUserByID(gomock.Any(), userID).Return(nil, utils.NotFound("user not found"))
+			},
+			status: http.StatusNotFound,
+			code:   utils.CodeNotFound,
+		},
+		{
+			name: "insufficient funds",
+			setup: func(u *user.MockRepository, w *wallet.MockRepository) {
+				u.EXPECT().GetUserByID(gomock.Any(), userID).Return(&user.User{ID: userID}, nil)
+				w.EXPECT().GetWalletByUserID(gomock.Any(), userID).Return(&wallet.Wallet{UserID: userID, Balance: 5000}, nil)
+			},
+			status: http.StatusUnprocessableEntity,
+			code:   utils.CodeInsufficientFunds,
+		},
+		{
+			name: "unexpected error",
+			setup: func(u *user.MockRepository, _ *wallet.MockRepository) {
+				u.EXPECT().GetUserByID(gomock.Any(), userID).Return(nil, assert.AnError)
+			},
+			status: http.StatusInternalServerError,
+			code:   utils.CodeInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockUserRepo := user.NewMockRepository(ctrl)
+			mockWalletRepo := wallet.NewMockRepository(ctrl)
+			tt.setup(mockUserRepo, mockWalletRepo)
+
+			svc := service{
+				userRepo:   mockUserRepo,
+				walletRepo: mockWalletRepo,
+				logger:     logging.Discard(),
+			}
+
+			body := `{"amount": 100, "user_id": "` + userID + `", "type": "debit", "reference": "ref-1"}`
+			rec := httptest.NewRecorder()
+			svc.HandleTransaction(rec, httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(body)))
+
+			var resp utils.ErrorResponse
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.code, resp.Code)
+		})
+	}
+}
+
+func TestHandleTransaction_ValidatesRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Nothing is looked up for an invalid request
+	svc := service{
+		userRepo:   user.NewMockRepository(ctrl),
+		walletRepo: wallet.NewMockRepository(ctrl),
+		logger:     logging.Discard(),
+	}
+
+	body := `{"amount": -5, "user_id": "user123", "type": "foo", "reference": "bad ref"}`
+	rec := httptest.NewRecorder()
+	svc.HandleTransaction(rec, httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(body)))
+
+	var resp utils.ErrorResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, utils.CodeInvalidRequest, resp.Code)
+	assert.Equal(t, []utils.FieldError{
+		{Field: "amount", Error: "amount must be greater than zero"},
+		{Field: "user_id", Error: "user_id must be a valid UUID"},
+		{Field: "type", Error: "type must be one of credit or debit"},
+		{Field: "reference", Error: "reference may only contain letters, digits and . _ : -"},
+	}, resp.Fields)
+}
+
+func TestHandleTransaction_HoldsForApproval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := user.NewMockRepository(ctrl)
+	mockApprovalRepo := approval.NewMockRepository(ctrl)
+
+	userID := "d164e69d-26f5-448d-a18c-baeae517d9f2"
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), userID).Return(&user.User{ID: userID}, nil)
+	mockApprovalRepo.EXPECT().Create(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, a *approval.Approval) (*approval.Approval, error) {
+			assert.Equal(t, approval.KindTransaction, a.Kind)
+			assert.Equal(t, "alice", a.Maker)
+			assert.Equal(t, int64(1500000), a.Amount)
+			held := *a
+			held.ID = "approval1"
+			return &held, nil
+		})
+
+	// No wallet or transaction is touched until it is approved
+	svc := service{
+		userRepo:          mockUserRepo,
+		approvalRepo:      mockApprovalRepo,
+		approvalThreshold: 1000000,
+		logger:            logging.Discard(),
+	}
+
+	body := `{"amount": 15000, "user_id": "` + userID + `", "type": "debit", "reference": "ref-1"}`
+	r := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	svc.HandleTransaction(rec, r.WithContext(utils.WithOperator(r.Context(), "alice")))
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "/approvals/approval1", rec.Header().Get("Location"))
+}
+
+func TestHandleTransaction_HoldRequiresOperator(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Nothing is held, since anyone could approve it
+	svc := service{
+		userRepo:          user.NewMockRepository(ctrl),
+		approvalRepo:      approval.NewMockRepository(ctrl),
+		approvalThreshold: 1000000,
+		logger:            logging.Discard(),
+	}
+
+	body := `{"amount": 15000, "user_id": "d164e69d-26f5-448d-a18c-baeae517d9f2", "type": "debit", "reference": "ref-1"}`
+	rec := httptest.NewRecorder()
+	svc.HandleTransaction(rec, httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(body)))
+
+	var resp utils.ErrorResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, utils.CodeUnauthorized, resp.Code)
+}
+
+func TestFindTransaction_ReturnsStatusHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTransactionRepo := transaction.NewMockRepository(ctrl)
+
+	created := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	mockTransactionRepo.EXPECT().GetTransactionByReference(gomock.Any(), "ref-1").Return(&transaction.Transaction{ID: "tx1", Reference: "ref-1", Status: "completed"}, nil)
+	mockTransactionRepo.EXPECT().GetStatusHistory(gomock.Any(), "tx1").Return([]transaction.StatusChange{
+		{Status: "pending", ChangedAt: created},
+		{Status: "completed", ChangedAt: created.Add(time.Second)},
+	}, nil)
+
+	svc := service{transactionRepo: mockTransactionRepo}
+
+	rec := httptest.NewRecorder()
+	svc.FindTransaction(rec, httptest.NewRequest(http.MethodGet, "/transactions?reference=ref-1", nil))
+
+	var resp TransactionDetail
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ref-1", resp.Reference)
+	assert.Equal(t, []transaction.StatusChange{
+		{Status: "pending", ChangedAt: created},
+		{Status: "completed", ChangedAt: created.Add(time.Second)},
+	}, resp.StatusHistory)
+}
+
+func TestGetTransaction_NotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	id := "9b2f6d1e-3c4a-4f5b-8e7d-1a2b3c4d5e6f"
+
+	mockTransactionRepo := transaction.NewMockRepository(ctrl)
+	mockTransactionRepo.EXPECT().GetTransactionByID(gomock.Any(), id).Return(nil, utils.NotFound("transaction not found"))
+
+	svc := service{transactionRepo: mockTransactionRepo}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/transactions/"+id, nil), map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	svc.GetTransaction(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"not_found"`)
+}
+
+func TestHandleTransaction_AsyncMode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := "9b2f6d1e-3c4a-4f5b-8e7d-1a2b3c4d5e6f"
+
+	mockUserRepo := user.NewMockRepository(ctrl)
+	mockWalletRepo := wallet.NewMockRepository(ctrl)
+	mockTransactionRepo := transaction.NewMockRepository(ctrl)
+
+	// The provider is not called while handling the request
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), userID).Return(&user.User{ID: userID}, nil)
+	mockWalletRepo.EXPECT().GetWalletByUserID(gomock.Any(), userID).Return(&wallet.Wallet{UserID: userID}, nil)
+	mockTransactionRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(&transaction.Transaction{ID: "tx1", Status: "pending"}, nil)
+
+	pool := NewPool(1, 1, logging.Discard())
+	svc := service{
+		userRepo:        mockUserRepo,
+		walletRepo:      mockWalletRepo,
+		transactionRepo: mockTransactionRepo,
+		pool:            pool,
+		logger:          logging.Discard(),
+	}
+
+	body := `{"amount": 100, "user_id": "` + userID + `", "type": "credit", "reference": "ref-1"}`
+	req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(body))
+	req.Header.Set("Prefer", "respond-async")
+	rec := httptest.NewRecorder()
+	svc.HandleTransaction(rec, req)
+
+	var resp AcceptedResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "/transactions/tx1", rec.Header().Get("Location"))
+	assert.Equal(t, AcceptedResponse{TransactionID: "tx1", Status: "pending", StatusURL: "/transactions/tx1"}, resp)
+
+	// The transaction was queued for the workers
+	assert.ErrorIs(t, pool.Enqueue("tx2"), ErrQueueFull)
+}
+
+func TestHandleTransaction_RecordsRequestID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := "9b2f6d1e-3c4a-4f5b-8e7d-1a2b3c4d5e6f"
+
+	mockUserRepo := user.NewMockRepository(ctrl)
+	mockWalletRepo := wallet.NewMockRepository(ctrl)
+	mockTransactionRepo := transaction.NewMockRepository(ctrl)
+
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), userID).Return(&user.User{ID: userID}, nil)
+	mockWalletRepo.EXPECT().GetWalletByUserID(gomock.Any(), userID).Return(&wallet.Wallet{UserID: userID}, nil)
+	var created *transaction.Transaction
+	mockTransactionRepo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, t *transaction.Transaction) (*transaction.Transaction, error) {
+		t.ID = "tx1"
+		created = t
+		return t, nil
+	})
+
+	svc := service{
+		userRepo:        mockUserRepo,
+		walletRepo:      mockWalletRepo,
+		transactionRepo: mockTransactionRepo,
+		pool:            NewPool(1, 1, logging.Discard()),
+		logger:          logging.Discard(),
+	}
+
+	body := `{"amount": 100, "user_id": "` + userID + `", "type": "credit", "reference": "ref-1"}`
+	req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(body))
+	req.Header.Set("Prefer", "respond-async")
+	req.Header.Set(logging.RequestIDHeader, "req-42")
+	rec := httptest.NewRecorder()
+	logging.Middleware(http.HandlerFunc(svc.HandleTransaction)).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "req-42", rec.Header().Get(logging.RequestIDHeader))
+
+	// The transaction is stored under the ID of the request
+	assert.Equal(t, "req-42", created.RequestID)
+}
+
+func TestProcessTransaction_FailsWhenBalanceIsGone(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockWalletRepo := wallet.NewMockRepository(ctrl)
+	mockTransactionRepo := transaction.NewMockRepository(ctrl)
+
+	mockTransactionRepo.EXPECT().GetTransactionByID(gomock.Any(), "tx1").Return(&transaction.Transaction{ID: "tx1", UserID: "user123", Type: "debit", Amount: 10000, Status: "pending"}, nil)
+	mockWalletRepo.EXPECT().GetWalletByUserID(gomock.Any(), "user123").Return(&wallet.Wallet{UserID: "user123", Balance: 5000}, nil)
+	mockTransactionRepo.EXPECT().UpdateTransactionToFailed(gomock.Any(), "tx1").Return(&transaction.Transaction{ID: "tx1", Status: "failed"}, nil)
+
+	svc := service{walletRepo: mockWalletRepo, transactionRepo: mockTransactionRepo}
+
+	resp, err := svc.ProcessTransaction(context.Background(), "tx1")
+
+	assert.ErrorIs(t, err, utils.ErrInsufficientFunds)
+	assert.False(t, resp.Success)
+}
+
+func TestHandleTransactionRequest_RecordsPaymentAfterClientLeaves(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUserRepo := user.NewMockRepository(ctrl)
+	mockWalletRepo := wallet.NewMockRepository(ctrl)
+	mockTransactionRepo := transaction.NewMockRepository(ctrl)
+	mockThirdParty := thirdparty.NewMockService(ctrl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockWallet := wallet.Wallet{UserID: "user123", Balance: 20000}
+	mockTransaction := transaction.Transaction{ID: "tx1", UserID: "user123", Type: "debit", Amount: 10000}
+
+	mockUserRepo.EXPECT().GetUserByID(ctx, "user123").Return(&user.User{ID: "user123"}, nil)
+	mockWalletRepo.EXPECT().GetWalletByUserID(ctx, "user123").Return(&mockWallet, nil)
+	mockTransactionRepo.EXPECT().Create(ctx, gomock.Any()).Return(&mockTransaction, nil)
+
+	// The client disconnects while the provider makes the payment
+	mockThirdParty.EXPECT().MakePayment(gomock.Any(), gomock.Any()).DoAndReturn(func(_ thirdparty.Transaction, _ context.Context) (*thirdparty.Transaction, error) {
+		cancel()
+		return &thirdparty.Transaction{}, nil
+	})
+	mockTransactionRepo.EXPECT().UpdateTransactionToCompleted(gomock.Any(), mockTransaction.ID).Return(&mockTransaction, nil)
+	mockWalletRepo.EXPECT().DebitWallet(gomock.Any(), &mockWallet, gomock.Any(), int64(10000)).DoAndReturn(
+		func(ctx context.Context, w *wallet.Wallet, _ transaction.Transaction, _ int64) (*wallet.Wallet, error) {
+			assert.NoError(t, ctx.Err())
+			return w, nil
+		})
+
+	svc := service{
+		userRepo:          mockUserRepo,
+		walletRepo:        mockWalletRepo,
+		transactionRepo:   mockTransactionRepo,
+		uow:               unitofworktest.Direct(unitofwork.Repositories{Wallets: mockWalletRepo, Transactions: mockTransactionRepo}),
+		thirdPartyService: mockThirdParty,
+		logger:            logging.Discard(),
+	}
+
+	resp, err := svc.HandleTransactionRequest(ctx, Request{Amount: 100, UserID: "user123", Type: "debit"})
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+}
+
+func TestHandleTransactionRequest_InMemory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Real repositories backed by memory, so only the provider is mocked
+	store := memory.NewStore()
+	store.PutUser(fixtures.User().WithID("user123").Build())
+	store.PutWallet(fixtures.Wallet("user123").WithID("wallet123").WithBalance(20000).Build())
+
+	mockThirdParty := thirdparty.NewMockService(ctrl)
+	mockThirdParty.EXPECT().MakePayment(gomock.Any(), gomock.Any()).Return(&thirdparty.Transaction{}, nil)
+
+	repos := unitofwork.Repositories{Users: store.Users(), Wallets: store.Wallets(), Transactions: store.Transactions()}
+	svc := service{
+		userRepo:          repos.Users,
+		walletRepo:        repos.Wallets,
+		transactionRepo:   repos.Transactions,
+		uow:               unitofworktest.Direct(repos),
+		thirdPartyService: mockThirdParty,
+		logger:            logging.Discard(),
+	}
+
+	resp, err := svc.HandleTransactionRequest(context.Background(), Request{Amount: 100.0, UserID: "user123", Type: "debit"})
+
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+
+	w, err := repos.Wallets.GetWalletByID(context.Background(), "wallet123")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10000), w.Balance)
+
+	completed, err := repos.Transactions.CountByStatus(context.Background(), "completed")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), completed)
+}
+
+func TestHandleTransaction_IfMatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := "9b2f6d1e-3c4a-4f5b-8e7d-1a2b3c4d5e6f"
+	mockUserRepo := user.NewMockRepository(ctrl)
+	mockWalletRepo := wallet.NewMockRepository(ctrl)
+	mockUserRepo.EXPECT().GetUserByID(gomock.Any(), userID).Return(&user.User{ID: userID}, nil)
+	mockWalletRepo.EXPECT().GetWalletByUserID(gomock.Any(), userID).Return(&wallet.Wallet{UserID: userID, Balance: 50000, Version: 3}, nil)
+
+	// No transaction is recorded for a wallet that has moved on
+	svc := service{
+		userRepo:   mockUserRepo,
+		walletRepo: mockWalletRepo,
+		logger:     logging.Discard(),
+	}
+
+	body := `{"amount": 100, "user_id": "` + userID + `", "type": "debit", "reference": "ref-1"}`
+	req := httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(body))
+	req.Header.Set("If-Match", `"2"`)
+	rec := httptest.NewRecorder()
+	svc.HandleTransaction(rec, req)
+
+	var resp utils.ErrorResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	assert.Equal(t, utils.CodePreconditionFailed, resp.Code)
+}
+
+func TestHandleTransactionRequest_InMemoryVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := memory.NewStore()
+	store.PutUser(fixtures.User().WithID("user123").Build())
+	store.PutWallet(fixtures.Wallet("user123").WithID("wallet123").WithBalance(20000).Build())
+
+	mockThirdParty := thirdparty.NewMockService(ctrl)
+	mockThirdParty.EXPECT().MakePayment(gomock.Any(), gomock.Any()).Return(&thirdparty.Transaction{}, nil)
+
+	repos := unitofwork.Repositories{Users: store.Users(), Wallets: store.Wallets(), Transactions: store.Transactions()}
+	svc := service{
+		userRepo:          repos.Users,
+		walletRepo:        repos.Wallets,
+		transactionRepo:   repos.Transactions,
+		uow:               unitofworktest.Direct(repos),
+		thirdPartyService: mockThirdParty,
+		logger:            logging.Discard(),
+	}
+
+	resp, err := svc.HandleTransactionRequest(context.Background(), Request{Amount: 100.0, UserID: "user123", Type: "debit", Reference: "ref-1", Version: 1})
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+
+	// The same version no longer matches once the wallet has been debited
+	_, err = svc.HandleTransactionRequest(context.Background(), Request{Amount: 100.0, UserID: "user123", Type: "debit", Reference: "ref-2", Version: 1})
+	assert.ErrorIs(t, err, utils.ErrPreconditionFailed)
+
+	w, err := repos.Wallets.GetWalletByID(context.Background(), "wallet123")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10000), w.Balance)
+	assert.Equal(t, int64(2), w.Version)
+}
+
+func TestHandleTransactionRequest_RoundsToCents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := memory.NewStore()
+	store.PutUser(fixtures.User().WithID("user123").Build())
+	store.PutWallet(fixtures.Wallet("user123").WithID("wallet123").Build())
+
+	mockThirdParty := thirdparty.NewMockService(ctrl)
+	mockThirdParty.EXPECT().MakePayment(gomock.Any(), gomock.Any()).Return(&thirdparty.Transaction{}, nil)
+
+	repos := unitofwork.Repositories{Users: store.Users(), Wallets: store.Wallets(), Transactions: store.Transactions()}
+	svc := service{
+		userRepo:          repos.Users,
+		walletRepo:        repos.Wallets,
+		transactionRepo:   repos.Transactions,
+		uow:               unitofworktest.Direct(repos),
+		thirdPartyService: mockThirdParty,
+		logger:            logging.Discard(),
+	}
+
+	// 0.29 * 100 is 28.999999999999996 as a float
+	_, err := svc.HandleTransactionRequest(context.Background(), Request{Amount: 0.29, UserID: "user123", Type: "credit", Reference: "ref-1"})
+	assert.NoError(t, err)
+
+	w, err := repos.Wallets.GetWalletByID(context.Background(), "wallet123")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(29), w.Balance)
+}
+
+func TestHandleTransactionRequest_HoldsAboveThreshold(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := memory.NewStore()
+	store.PutUser(fixtures.User().WithID("user123").Build())
+	store.PutWallet(fixtures.Wallet("user123").WithID("wallet123").Build())
+
+	mockApprovalRepo := approval.NewMockRepository(ctrl)
+	mockApprovalRepo.EXPECT().Create(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, a *approval.Approval) (*approval.Approval, error) {
+			assert.Equal(t, "alice", a.Maker)
+			assert.Equal(t, int64(1500000), a.Amount)
+			held := *a
+			held.ID = "approval1"
+			return &held, nil
+		})
+	mockThirdParty := thirdparty.NewMockService(ctrl)
+	mockThirdParty.EXPECT().MakePayment(gomock.Any(), gomock.Any()).Return(&thirdparty.Transaction{}, nil)
+
+	repos := unitofwork.Repositories{Users: store.Users(), Wallets: store.Wallets(), Transactions: store.Transactions()}
+	svc := service{
+		userRepo:          repos.Users,
+		walletRepo:        repos.Wallets,
+		transactionRepo:   repos.Transactions,
+		uow:               unitofworktest.Direct(repos),
+		thirdPartyService: mockThirdParty,
+		approvalRepo:      mockApprovalRepo,
+		approvalThreshold: 1000000,
+		logger:            logging.Discard(),
+	}
+
+	// Batches and schedules call it directly, and are held all the same
+	req := Request{Amount: 15000, UserID: "user123", Type: "credit", Reference: "ref-1"}
+	_, err := svc.HandleTransactionRequest(utils.WithOperator(context.Background(), "alice"), req)
+
+	var held *HeldError
+	require.ErrorAs(t, err, &held)
+	assert.Equal(t, "approval1", held.Approval.ID)
+	pending, err := repos.Transactions.CountByStatus(context.Background(), "pending")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), pending)
+
+	// Once approved it is made
+	req.ApprovalID = held.Approval.ID
+	resp, err := svc.HandleTransactionRequest(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.True(t, resp.Success)
+	w, err := repos.Wallets.GetWalletByID(context.Background(), "wallet123")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1500000), w.Balance)
+}
+
+func TestHandleTransaction_RejectsFractionsOfCents(t *testing.T) {
+	// Nothing is looked up for an invalid request
+	svc := service{logger: logging.Discard()}
+
+	body := `{"amount": 0.004, "user_id": "d164e69d-26f5-448d-a18c-baeae517d9f2", "type": "credit", "reference": "ref-1"}`
+	rec := httptest.NewRecorder()
+	svc.HandleTransaction(rec, httptest.NewRequest(http.MethodPost, "/transactions", strings.NewReader(body)))
+
+	var resp utils.ErrorResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, []utils.FieldError{{Field: "amount", Error: "amount must have at most 2 decimal places"}}, resp.Fields)
+}
+
+func TestProcessTransaction_Concurrently(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := memory.NewStore()
+	store.PutUser(fixtures.User().WithID("user123").Build())
+	store.PutWallet(fixtures.Wallet("user123").WithID("wallet123").Build())
+	pending := fixtures.Transaction("user123").Credit(500).Build()
+	store.PutTransaction(pending)
+
+	// Both processors find the transaction pending before either settles it,
+	// as RetryPending and a pool worker can
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	mockThirdParty := thirdparty.NewMockService(ctrl)
+	mockThirdParty.EXPECT().MakePayment(gomock.Any(), gomock.Any()).Times(2).DoAndReturn(func(thirdparty.Transaction, context.Context) (*thirdparty.Transaction, error) {
+		arrived.Done()
+		arrived.Wait()
+		return &thirdparty.Transaction{}, nil
+	})
+
+	repos := unitofwork.Repositories{Users: store.Users(), Wallets: store.Wallets(), Transactions: store.Transactions()}
+	svc := service{
+		userRepo:          repos.Users,
+		walletRepo:        repos.Wallets,
+		transactionRepo:   repos.Transactions,
+		uow:               unitofworktest.Direct(repos),
+		thirdPartyService: mockThirdParty,
+		logger:            logging.Discard(),
+	}
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := svc.ProcessTransaction(context.Background(), pending.ID)
+			errs <- err
+		}()
+	}
+
+	var conflicts int
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			assert.ErrorIs(t, err, utils.ErrConflict)
+			conflicts++
+		}
+	}
+	assert.Equal(t, 1, conflicts)
+
+	// The wallet is credited once
+	w, err := repos.Wallets.GetWalletByID(context.Background(), "wallet123")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(500), w.Balance)
+}
+
+func TestHandleTransfer_ReversesDebitWhenCreditFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := memory.NewStore()
+	store.PutUser(fixtures.User().WithID("user123").Build())
+	store.PutUser(fixtures.User().WithID("user456").Build())
+	store.PutWallet(fixtures.Wallet("user123").WithID("wallet123").WithBalance(10000).Build())
+	store.PutWallet(fixtures.Wallet("user456").WithID("wallet456").WithBalance(500).Build())
+
+	mockThirdParty := thirdparty.NewMockService(ctrl)
+	mockThirdParty.EXPECT().MakePayment(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(req thirdparty.Transaction, _ context.Context) (*thirdparty.Transaction, error) {
+			if req.AccountID == "user456" {
+				return nil, assert.AnError
+			}
+			return &req, nil
+		}).Times(2)
+	mockThirdParty.EXPECT().RefundPayment(thirdparty.Transaction{AccountID: "user123", Reference: "ref-D", Amount: 25}, gomock.Any()).
+		Return(&thirdparty.Transaction{}, nil)
+
+	repos := unitofwork.Repositories{Users: store.Users(), Wallets: store.Wallets(), Transactions: store.Transactions()}
+	svc := NewService(repos.Users, repos.Transactions, repos.Wallets, unitofworktest.Direct(repos), mockThirdParty, nil, nil, 0, logging.Discard())
+
+	_, err := svc.HandleTransfer(context.Background(), Transfer{
+		Debit:  Request{Amount: 25, UserID: "user123", Type: "debit", Reference: "ref-D"},
+		Credit: Request{Amount: 25, UserID: "user456", Type: "credit", Reference: "ref-C"},
+	})
+
+	assert.Error(t, err)
+	payer, err := repos.Wallets.GetWalletByID(context.Background(), "wallet123")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10000), payer.Balance)
+	payee, err := repos.Wallets.GetWalletByID(context.Background(), "wallet456")
+	require.NoError(t, err)
+	assert.Equal(t, int64(500), payee.Balance)
+
+	reversal, err := repos.Transactions.GetTransactionByReference(context.Background(), ReversalReference("ref-D"))
+	require.NoError(t, err)
+	assert.Equal(t, "credit", reversal.Type)
+	assert.Equal(t, "completed", reversal.Status)
+}
+
+func TestHandleTransfer_HeldAsAWhole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := memory.NewStore()
+	store.PutUser(fixtures.User().WithID("user123").Build())
+
+	transfer := Transfer{
+		Debit:  Request{Amount: 15000, UserID: "user123", Type: "debit", Reference: "ref-D"},
+		Credit: Request{Amount: 15000, UserID: "user456", Type: "credit", Reference: "ref-C"},
+	}
+	mockApprovalRepo := approval.NewMockRepository(ctrl)
+	mockApprovalRepo.EXPECT().Create(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, a *approval.Approval) (*approval.Approval, error) {
+			assert.Equal(t, approval.KindTransfer, a.Kind)
+			var payload Transfer
+			require.NoError(t, json.Unmarshal(a.Payload, &payload))
+			assert.Equal(t, transfer, payload)
+			held := *a
+			held.ID = "approval1"
+			return &held, nil
+		})
+
+	repos := unitofwork.Repositories{Users: store.Users(), Wallets: store.Wallets(), Transactions: store.Transactions()}
+	svc := service{
+		userRepo:          repos.Users,
+		walletRepo:        repos.Wallets,
+		transactionRepo:   repos.Transactions,
+		uow:               unitofworktest.Direct(repos),
+		approvalRepo:      mockApprovalRepo,
+		approvalThreshold: 1000000,
+		logger:            logging.Discard(),
+	}
+
+	_, err := svc.HandleTransfer(utils.WithOperator(context.Background(), "alice"), transfer)
+
+	var held *HeldError
+	require.ErrorAs(t, err, &held)
+	assert.Equal(t, "approval1", held.Approval.ID)
+}
+
+func TestReverseTransaction_OnlyOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := memory.NewStore()
+	store.PutUser(fixtures.User().WithID("user123").Build())
+	store.PutWallet(fixtures.Wallet("user123").WithID("wallet123").WithBalance(10000).Build())
+
+	mockThirdParty := thirdparty.NewMockService(ctrl)
+	mockThirdParty.EXPECT().MakePayment(gomock.Any(), gomock.Any()).Return(&thirdparty.Transaction{}, nil)
+	mockThirdParty.EXPECT().RefundPayment(gomock.Any(), gomock.Any()).Return(&thirdparty.Transaction{}, nil)
+
+	repos := unitofwork.Repositories{Users: store.Users(), Wallets: store.Wallets(), Transactions: store.Transactions()}
+	svc := NewService(repos.Users, repos.Transactions, repos.Wallets, unitofworktest.Direct(repos), mockThirdParty, nil, nil, 0, logging.Discard())
+
+	_, err := svc.HandleTransactionRequest(context.Background(), Request{Amount: 25, UserID: "user123", Type: "debit", Reference: "ref-1"})
+	require.NoError(t, err)
+	debit, err := repos.Transactions.GetTransactionByReference(context.Background(), "ref-1")
+	require.NoError(t, err)
+
+	first, err := svc.ReverseTransaction(context.Background(), debit.ID)
+	require.NoError(t, err)
+	second, err := svc.ReverseTransaction(context.Background(), debit.ID)
+	require.NoError(t, err)
+
+	assert.Equal(t, first.ID, second.ID)
+	w, err := repos.Wallets.GetWalletByID(context.Background(), "wallet123")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10000), w.Balance)
+}
+
+func TestRetryTransaction(t *testing.T) {
+	tests := []struct {
+		name string
+		// found is what the provider says about the payment
+		found    error
+		payments int
+	}{
+		// The payment is settled without being made again
+		{name: "payment made already", payments: 0},
+		{name: "payment never made", found: &thirdparty.StatusError{Code: http.StatusNotFound}, payments: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := memory.NewStore()
+			store.PutUser(fixtures.User().WithID("user123").Build())
+			store.PutWallet(fixtures.Wallet("user123").WithID("wallet123").Build())
+			repos := unitofwork.Repositories{Users: store.Users(), Wallets: store.Wallets(), Transactions: store.Transactions()}
+
+			mockThirdParty := thirdparty.NewMockService(ctrl)
+			svc := NewService(repos.Users, repos.Transactions, repos.Wallets, unitofworktest.Direct(repos), mockThirdParty, nil, nil, 0, logging.Discard())
+
+			pending, err := svc.SubmitTransactionRequest(context.Background(), Request{Amount: 25, UserID: "user123", Type: "credit", Reference: "ref-1"})
+			require.NoError(t, err)
+
+			var made *thirdparty.Transaction
+			if tt.found == nil {
+				made = &thirdparty.Transaction{AccountID: "user123", Reference: "ref-1", Amount: 25}
+			}
+			mockThirdParty.EXPECT().GetTransaction("ref-1", "user123").Return(made, tt.found)
+			mockThirdParty.EXPECT().MakePayment(gomock.Any(), gomock.Any()).Return(&thirdparty.Transaction{}, nil).Times(tt.payments)
+
+			resp, err := svc.RetryTransaction(context.Background(), pending.ID)
+
+			require.NoError(t, err)
+			assert.True(t, resp.Success)
+			completed, err := repos.Transactions.GetTransactionByID(context.Background(), pending.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "completed", completed.Status)
+			w, err := repos.Wallets.GetWalletByID(context.Background(), "wallet123")
+			require.NoError(t, err)
+			assert.Equal(t, int64(2500), w.Balance)
+		})
+	}
+}
+
+func TestRetryTransaction_ProviderUnavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTransactionRepo := transaction.NewMockRepository(ctrl)
+	mockThirdParty := thirdparty.NewMockService(ctrl)
+
+	mockTransactionRepo.EXPECT().GetTransactionByID(gomock.Any(), "tx1").Return(&transaction.Transaction{ID: "tx1", UserID: "user123", Reference: "ref-1", Type: "debit", Amount: 10000, Status: "pending"}, nil)
+	mockThirdParty.EXPECT().GetTransaction("ref-1", "user123").Return(nil, &thirdparty.StatusError{Code: http.StatusServiceUnavailable})
+
+	// Nothing is paid or settled while the payment is unknown
+	svc := service{transactionRepo: mockTransactionRepo, thirdPartyService: mockThirdParty, logger: logging.Discard()}
+
+	_, err := svc.RetryTransaction(context.Background(), "tx1")
+
+	assert.ErrorIs(t, err, utils.ErrProviderFailure)
+}
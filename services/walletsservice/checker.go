@@ -0,0 +1,82 @@
+package walletsservice
+
+import (
+	"context"
+	"log/slog"
+	"p-system/repositories/wallet"
+	"time"
+)
+
+// Report is the outcome of a balance integrity check.
+type Report struct {
+	CheckedAt     time.Time            `json:"checked_at"`
+	Discrepancies []wallet.Discrepancy `json:"discrepancies"`
+	Frozen        []string             `json:"frozen,omitempty"`
+}
+
+// Checker recomputes every wallet balance from its completed transactions
+// and reports the wallets that do not add up.
+type Checker struct {
+	walletRepo wallet.Repository
+	// Freeze freezes every wallet found with a discrepancy.
+	Freeze bool
+	// Interval is how often Start runs a check.
+	Interval time.Duration
+
+	logger *slog.Logger
+}
+
+func NewChecker(walletRepo wallet.Repository, freeze bool, logger *slog.Logger) *Checker {
+	return &Checker{
+		walletRepo: walletRepo,
+		Freeze:     freeze,
+		Interval:   24 * time.Hour,
+		logger:     logger,
+	}
+}
+
+// Start runs a check every Interval until ctx is cancelled.
+func (c *Checker) Start(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := c.Check(ctx); err != nil {
+			c.logger.ErrorContext(ctx, "checking balances", "error", err)
+		}
+	}
+}
+
+// Check runs a single integrity check, logging and optionally freezing every
+// wallet whose balance is off.
+func (c *Checker) Check(ctx context.Context) (*Report, error) {
+	discrepancies, err := c.walletRepo.CheckBalances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{CheckedAt: time.Now(), Discrepancies: discrepancies}
+
+	for _, d := range discrepancies {
+		// The amounts are left to the report, they are redacted from logs
+		c.logger.WarnContext(ctx, "balance discrepancy", "wallet_id", d.WalletID, "user_id", d.UserID)
+
+		if !c.Freeze {
+			continue
+		}
+
+		if _, err := c.walletRepo.UpdateWalletStatus(ctx, d.WalletID, wallet.StatusFrozen); err != nil {
+			c.logger.ErrorContext(ctx, "freezing wallet", "wallet_id", d.WalletID, "error", err)
+			continue
+		}
+		report.Frozen = append(report.Frozen, d.WalletID)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,21 @@
+package walletsservice
+
+import (
+	"net/http"
+	"p-system/repositories/wallet"
+)
+
+type service struct {
+	walletRepo wallet.Repository
+}
+
+type Service interface {
+	GetWallet(w http.ResponseWriter, r *http.Request)
+	GetBalance(w http.ResponseWriter, r *http.Request)
+}
+
+func NewService(walletRepo wallet.Repository) Service {
+	return &service{
+		walletRepo: walletRepo,
+	}
+}
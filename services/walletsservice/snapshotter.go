@@ -0,0 +1,59 @@
+package walletsservice
+
+import (
+	"context"
+	"log/slog"
+	"p-system/repositories/wallet"
+	"time"
+)
+
+// Snapshotter periodically records wallet balance snapshots so point in time
+// balances only need to replay a short stretch of history.
+type Snapshotter struct {
+	walletRepo wallet.Repository
+	// Interval is how often the snapshotter checks for wallets due a snapshot.
+	Interval time.Duration
+	// Every is the time between snapshots of the same wallet.
+	Every time.Duration
+	// Lag is how far behind now snapshots are taken. Balances are keyed on
+	// when transactions completed, so it only has to exceed the time a unit
+	// of work completing one can take to commit.
+	Lag    time.Duration
+	now    func() time.Time
+	logger *slog.Logger
+}
+
+func NewSnapshotter(walletRepo wallet.Repository, logger *slog.Logger) *Snapshotter {
+	return &Snapshotter{
+		walletRepo: walletRepo,
+		Interval:   time.Hour,
+		Every:      24 * time.Hour,
+		Lag:        time.Hour,
+		now:        time.Now,
+		logger:     logger,
+	}
+}
+
+// Start takes snapshots until ctx is cancelled.
+func (s *Snapshotter) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.TakeSnapshots(ctx); err != nil {
+			s.logger.ErrorContext(ctx, "taking balance snapshots", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// TakeSnapshots snapshots every wallet that is due and returns how many were
+// taken.
+func (s *Snapshotter) TakeSnapshots(ctx context.Context) (int64, error) {
+	return s.walletRepo.SnapshotBalances(ctx, s.now().Add(-s.Lag), s.Every)
+}
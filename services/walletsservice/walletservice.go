@@ -0,0 +1,63 @@
+package walletsservice
+
+import (
+	"errors"
+	"net/http"
+	"p-system/utils"
+	"time"
+)
+
+// BalanceResponse is the balance of a wallet at a point in time, in minor
+// units.
+type BalanceResponse struct {
+	WalletID string    `json:"wallet_id"`
+	Balance  int64     `json:"balance"`
+	At       time.Time `json:"at"`
+}
+
+// GetWallet returns the wallet with the id in the path, tagged with its
+// version so clients can make transactions on it conditional with If-Match.
+func (s service) GetWallet(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.PathID(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	wallet, err := s.walletRepo.GetWalletByID(r.Context(), id)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", utils.ETag(wallet.Version))
+	utils.SendJSONResponse(w, http.StatusOK, wallet)
+}
+
+// GetBalance returns the balance of a wallet as of the RFC 3339 timestamp in
+// the at query parameter, or now if it is omitted.
+func (s service) GetBalance(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.PathID(r)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	at := time.Now()
+	if value := r.URL.Query().Get("at"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			utils.RespondError(w, utils.NewRequestError(errors.New("at must be an RFC 3339 timestamp"), http.StatusBadRequest))
+			return
+		}
+		at = parsed
+	}
+
+	balance, err := s.walletRepo.GetBalanceAt(r.Context(), id, at)
+	if err != nil {
+		utils.RespondError(w, err)
+		return
+	}
+
+	utils.SendJSONResponse(w, http.StatusOK, BalanceResponse{WalletID: id, Balance: balance, At: at})
+}
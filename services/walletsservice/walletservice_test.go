@@ -0,0 +1,124 @@
+package walletsservice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"p-system/logging"
+	"p-system/repositories/wallet"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// walletID is the id of the wallet in the path of requests.
+const walletID = "d164e69d-26f5-448d-a18c-baeae517d991"
+
+func TestGetWallet_ETag(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockWalletRepo := wallet.NewMockRepository(ctrl)
+	mockWalletRepo.EXPECT().GetWalletByID(gomock.Any(), walletID).Return(&wallet.Wallet{ID: walletID, Balance: 2500, Version: 7}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/wallets/"+walletID, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": walletID})
+	rec := httptest.NewRecorder()
+
+	service{walletRepo: mockWalletRepo}.GetWallet(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"7"`, rec.Header().Get("ETag"))
+}
+
+func TestGetBalance_At(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockWalletRepo := wallet.NewMockRepository(ctrl)
+	at := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+	mockWalletRepo.EXPECT().GetBalanceAt(gomock.Any(), walletID, at).Return(int64(2500), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/wallets/"+walletID+"/balance?at=2024-05-01T12:00:00Z", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": walletID})
+	rec := httptest.NewRecorder()
+
+	service{walletRepo: mockWalletRepo}.GetBalance(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"wallet_id":"`+walletID+`","balance":2500,"at":"2024-05-01T12:00:00Z"}`, rec.Body.String())
+}
+
+func TestGetBalance_InvalidAt(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/wallets/"+walletID+"/balance?at=yesterday", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": walletID})
+	rec := httptest.NewRecorder()
+
+	service{}.GetBalance(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestSnapshotter_TakesSnapshotsBehindNow(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+	mockWalletRepo := wallet.NewMockRepository(ctrl)
+	mockWalletRepo.EXPECT().SnapshotBalances(gomock.Any(), now.Add(-time.Hour), 24*time.Hour).Return(int64(3), nil)
+
+	s := NewSnapshotter(mockWalletRepo, logging.Discard())
+	s.now = func() time.Time { return now }
+
+	taken, err := s.TakeSnapshots(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), taken)
+}
+
+func TestChecker_FreezesMismatchedWallets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockWalletRepo := wallet.NewMockRepository(ctrl)
+	mockWalletRepo.EXPECT().CheckBalances(gomock.Any()).Return([]wallet.Discrepancy{
+		{WalletID: "wallet123", UserID: "user123", Balance: 5000, Expected: 4000},
+		{WalletID: "wallet456", UserID: "user456", Balance: 100, Expected: 0},
+	}, nil)
+	mockWalletRepo.EXPECT().UpdateWalletStatus(gomock.Any(), "wallet123", wallet.StatusFrozen).Return(&wallet.Wallet{}, nil)
+	mockWalletRepo.EXPECT().UpdateWalletStatus(gomock.Any(), "wallet456", wallet.StatusFrozen).Return(nil, assert.AnError)
+
+	report, err := NewChecker(mockWalletRepo, true, logging.Discard()).Check(context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, report.Discrepancies, 2)
+	assert.Equal(t, []string{"wallet123"}, report.Frozen)
+}
+
+func TestChecker_ReportOnly(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockWalletRepo := wallet.NewMockRepository(ctrl)
+	mockWalletRepo.EXPECT().CheckBalances(gomock.Any()).Return([]wallet.Discrepancy{{WalletID: "wallet123"}}, nil)
+	mockWalletRepo.EXPECT().UpdateWalletStatus(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	report, err := NewChecker(mockWalletRepo, false, logging.Discard()).Check(context.Background())
+
+	assert.NoError(t, err)
+	assert.Empty(t, report.Frozen)
+}
+
+func TestGetWallet_InvalidID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/wallets/wallet123", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "wallet123"})
+	rec := httptest.NewRecorder()
+
+	// The id never reaches the repository
+	service{}.GetWallet(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
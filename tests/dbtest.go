@@ -1,103 +1,250 @@
+// Package tests provides a Postgres database for repository tests. Each test
+// gets a database of its own, cloned from a migrated template, on a server
+// that is either given by TEST_DATABASE_URL, run in Docker when TEST_POSTGRES
+// is docker, or otherwise started from embedded Postgres binaries.
 package tests
 
 import (
-	"net"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"net/url"
+	"os"
 	DB "p-system/db"
-	"runtime"
+	"sync"
 	"testing"
-	"time"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
-	"github.com/ory/dockertest/v3"
 )
 
+// templateName is the migrated database every test database is cloned from.
+const templateName = "p_system_template"
+
+// templateLock is the advisory lock taken while the template is migrated and
+// cloned, so test binaries sharing a server do not race on it.
+const templateLock = 7_201_140
+
+// server is a running Postgres server test databases are created on.
+type server struct {
+	// url connects to the server's maintenance database.
+	url *url.URL
+	// stop shuts the server down, if the tests started it.
+	stop func() error
+	// mu serializes creating databases within the process.
+	mu sync.Mutex
+	// migrated is set once the template is up to date.
+	migrated bool
+	// refs counts the tests using the server.
+	refs int
+}
+
+// shared is the server in use, if any. It is stopped once no test uses it.
+var (
+	mu     sync.Mutex
+	shared *server
+)
+
+// StartDB returns a connection to a new, migrated and empty database, which
+// is dropped when the test ends.
 func StartDB(tb testing.TB) *sqlx.DB {
 	tb.Helper()
 
-	pgURL := &url.URL{
-		Scheme: "postgres",
-		User:   url.UserPassword("user", "password"),
-		Path:   "testdb",
-	}
-	q := pgURL.Query()
-	q.Add("sslmode", "disable")
-	pgURL.RawQuery = q.Encode()
+	s := acquire(tb)
 
-	pool, err := dockertest.NewPool("")
+	name, err := s.createDatabase()
 	if err != nil {
-		tb.Fatalf("failed to create Docker pool: %v", err)
-	}
-	if err != nil {
-		tb.Fatalf("failed to create Docker pool: %v", err)
+		tb.Fatalf("failed to create test database: %v", err)
 	}
 
-	pw, _ := pgURL.User.Password()
-	env := []string{
-		"POSTGRES_USER=" + pgURL.User.Username(),
-		"POSTGRES_PASSWORD=" + pw,
-		"POSTGRES_DB=" + pgURL.Path,
+	dbURL := *s.url
+	dbURL.Path = name
+	db, err := sqlx.Open("postgres", dbURL.String())
+	if err != nil {
+		tb.Fatalf("failed to open database: %v", err)
 	}
 
-	// Start the container.
-	container, err := pool.RunWithOptions(&dockertest.RunOptions{
-		Repository: "postgres",
-		Tag:        "alpine",
-		Env:        env,
+	tb.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			tb.Errorf("failed to close database: %v", err)
+		}
+		if err := s.dropDatabase(name); err != nil {
+			tb.Errorf("failed to drop test database: %v", err)
+		}
 	})
+
+	return db
+}
+
+// BeginTx begins a transaction on db that is rolled back when the test ends,
+// so whatever the test writes through it is undone. Repositories built on it
+// join it rather than beginning transactions of their own.
+func BeginTx(tb testing.TB, db *sqlx.DB) *sqlx.Tx {
+	tb.Helper()
+
+	tx, err := db.Beginx()
 	if err != nil {
-		tb.Fatalf("failed to start postgres container: %v", err)
+		tb.Fatalf("failed to begin transaction: %v", err)
 	}
 
 	tb.Cleanup(func() {
-		if err := pool.Purge(container); err != nil {
-			tb.Fatalf("failed to purge container: %v", err)
-		}
+		_ = tx.Rollback()
 	})
 
-	pgURL.Host = container.GetHostPort("5432/tcp")
+	return tx
+}
 
-	// Get the host.
-	pgURL.Host = container.Container.NetworkSettings.IPAddress
+// acquire returns the shared server, starting it if need be, and releases it
+// when the test ends.
+func acquire(tb testing.TB) *server {
+	tb.Helper()
 
-	// On Mac, Docker runs in a VM.
-	if runtime.GOOS == "darwin" {
-		pgURL.Host = net.JoinHostPort(container.GetBoundIP("5432/tcp"), container.GetPort("5432/tcp"))
-	}
+	mu.Lock()
+	defer mu.Unlock()
 
-	// Retry until we can establish a connection to the database.
-	pool.MaxWait = 10 * time.Second
-	err = pool.Retry(func() (err error) {
-		db, err := sqlx.Open("postgres", pgURL.String())
+	if shared == nil {
+		s, err := startServer()
 		if err != nil {
-			return err
+			tb.Fatalf("failed to start postgres: %v", err)
 		}
-		defer func() {
-			cerr := db.Close()
-			if err != nil {
-				err = cerr
-			}
-		}()
+		shared = s
+	}
+
+	s := shared
+	s.refs++
+
+	tb.Cleanup(func() {
+		mu.Lock()
+		defer mu.Unlock()
 
-		return db.Ping()
+		s.refs--
+		if s.refs == 0 && shared == s {
+			if err := s.close(); err != nil {
+				tb.Errorf("failed to stop postgres: %v", err)
+			}
+			shared = nil
+		}
 	})
+
+	return s
+}
+
+// startServer connects to TEST_DATABASE_URL or starts a server of its own.
+func startServer() (*server, error) {
+	if raw := os.Getenv("TEST_DATABASE_URL"); raw != "" {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parsing TEST_DATABASE_URL: %w", err)
+		}
+		return &server{url: u}, nil
+	}
+
+	start := startEmbedded
+	if os.Getenv("TEST_POSTGRES") == "docker" {
+		start = startDocker
+	}
+
+	u, stop, err := start()
 	if err != nil {
-		tb.Fatalf("Failed to start postgres: %v", err)
+		return nil, err
+	}
+	return &server{url: u, stop: stop}, nil
+}
+
+// close stops the server if the tests started it.
+func (s *server) close() error {
+	if s.stop == nil {
+		return nil
 	}
+	return s.stop()
+}
+
+// createDatabase clones the template into a database with a random name,
+// first bringing the template up to date with the migrations.
+func (s *server) createDatabase() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := context.Background()
 
-	db, err := sqlx.Open("postgres", pgURL.String())
+	admin, err := sqlx.Open("postgres", s.url.String())
 	if err != nil {
-		tb.Fatalf("failed to open database: %v", err)
+		return "", err
 	}
+	defer admin.Close()
 
-	time.Sleep(10 * time.Second) // This is a hack. Must find a better way to wait for the db to establish a connection
-	if err := DB.Migrate(db.DB); err != nil {
-		tb.Fatalf("failed to run migrations: %v", err)
+	// The lock is held by the session, so take it on a single connection
+	conn, err := admin.Connx(ctx)
+	if err != nil {
+		return "", err
 	}
+	defer conn.Close()
 
-	return db
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", templateLock); err != nil {
+		return "", err
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", templateLock)
+
+	if !s.migrated {
+		if err := s.migrateTemplate(ctx, conn); err != nil {
+			return "", fmt.Errorf("migrating template: %w", err)
+		}
+		s.migrated = true
+	}
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	name := "test_" + hex.EncodeToString(suffix)
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s", name, templateName)); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// migrateTemplate creates the template if it does not exist yet and runs any
+// pending migrations on it. Migrations are idempotent, so a template left by
+// an earlier run is reused.
+func (s *server) migrateTemplate(ctx context.Context, conn *sqlx.Conn) error {
+	var exists bool
+	if err := conn.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", templateName); err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := conn.ExecContext(ctx, "CREATE DATABASE "+templateName); err != nil {
+			return err
+		}
+	}
+
+	templateURL := *s.url
+	templateURL.Path = templateName
+	template, err := sqlx.Open("postgres", templateURL.String())
+	if err != nil {
+		return err
+	}
+
+	// Nothing may stay connected to the template while it is cloned
+	err = DB.Migrate(template.DB)
+	if closeErr := template.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// dropDatabase drops a test database, disconnecting anything still using it.
+func (s *server) dropDatabase(name string) error {
+	admin, err := sqlx.Open("postgres", s.url.String())
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
 
+	_, err = admin.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s WITH (FORCE)", name))
+	return err
 }
 
 const seeds = `INSERT INTO users (id,username, email, password)
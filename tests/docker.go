@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"net"
+	"net/url"
+	"runtime"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ory/dockertest/v3"
+)
+
+// startDocker runs Postgres in a Docker container and waits until it accepts
+// connections.
+func startDocker() (*url.URL, func() error, error) {
+	pgURL := &url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword("user", "password"),
+		Path:   "testdb",
+	}
+	q := pgURL.Query()
+	q.Add("sslmode", "disable")
+	pgURL.RawQuery = q.Encode()
+
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pw, _ := pgURL.User.Password()
+	env := []string{
+		"POSTGRES_USER=" + pgURL.User.Username(),
+		"POSTGRES_PASSWORD=" + pw,
+		"POSTGRES_DB=" + pgURL.Path,
+	}
+
+	// Start the container.
+	container, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "postgres",
+		Tag:        "alpine",
+		Env:        env,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stop := func() error {
+		return pool.Purge(container)
+	}
+
+	// Get the host.
+	pgURL.Host = container.Container.NetworkSettings.IPAddress
+
+	// On Mac, Docker runs in a VM.
+	if runtime.GOOS == "darwin" {
+		pgURL.Host = net.JoinHostPort(container.GetBoundIP("5432/tcp"), container.GetPort("5432/tcp"))
+	}
+
+	// Retry until we can establish a connection to the database. The image
+	// only listens on TCP once initialisation is over, so a successful query
+	// means it is ready for migrations.
+	pool.MaxWait = 30 * time.Second
+	err = pool.Retry(func() error {
+		db, err := sqlx.Open("postgres", pgURL.String())
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		_, err = db.Exec("SELECT 1")
+		return err
+	})
+	if err != nil {
+		_ = stop()
+		return nil, nil, err
+	}
+
+	return pgURL, stop, nil
+}
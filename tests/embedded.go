@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+)
+
+// startEmbedded runs a local Postgres server from embedded binaries, which
+// are downloaded once and cached. Every server gets a free port and a data
+// directory of its own, so test binaries run in parallel do not clash.
+func startEmbedded() (*url.URL, func() error, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "p-system-postgres-")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pg := embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+		Version(embeddedpostgres.V15).
+		Port(uint32(port)).
+		Username("user").
+		Password("password").
+		Database("testdb").
+		RuntimePath(filepath.Join(dir, "runtime")).
+		DataPath(filepath.Join(dir, "data")).
+		Logger(io.Discard))
+
+	if err := pg.Start(); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, nil, fmt.Errorf("starting embedded postgres: %w", err)
+	}
+
+	stop := func() error {
+		err := pg.Stop()
+		if rmErr := os.RemoveAll(dir); err == nil {
+			err = rmErr
+		}
+		return err
+	}
+
+	pgURL := &url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword("user", "password"),
+		Host:     fmt.Sprintf("localhost:%d", port),
+		Path:     "testdb",
+		RawQuery: "sslmode=disable",
+	}
+	return pgURL, stop, nil
+}
+
+// freePort returns a TCP port nothing is listening on.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
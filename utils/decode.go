@@ -4,15 +4,105 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
 
+	"github.com/go-playground/locales/en"
 	ut "github.com/go-playground/universal-translator"
 	"gopkg.in/go-playground/validator.v9"
+	en_translations "gopkg.in/go-playground/validator.v9/translations/en"
 )
 
 // validate holds the settings and caches for validating request struct values.
 var validate *validator.Validate
 var translator *ut.UniversalTranslator
 
+// trans renders validation errors as messages for API clients.
+var trans ut.Translator
+
+// referenceFormat is the format of a client supplied transaction reference.
+var referenceFormat = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]*$`)
+
+func init() {
+	validate = validator.New()
+
+	// Report fields by the names clients send them with
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	validate.RegisterValidation("positive", isPositive)
+	validate.RegisterValidation("cents", func(fl validator.FieldLevel) bool {
+		return hasCents(fl.Field().Float())
+	})
+	validate.RegisterValidation("reference", func(fl validator.FieldLevel) bool {
+		return referenceFormat.MatchString(fl.Field().String())
+	})
+
+	english := en.New()
+	translator = ut.New(english, english)
+	trans, _ = translator.GetTranslator("en")
+	if err := en_translations.RegisterDefaultTranslations(validate, trans); err != nil {
+		panic(err)
+	}
+
+	registerMessage("required", "{0} is required", nil)
+	registerMessage("positive", "{0} must be greater than zero", nil)
+	registerMessage("cents", "{0} must have at most 2 decimal places", nil)
+	registerMessage("reference", "{0} may only contain letters, digits and . _ : -", nil)
+	registerMessage("oneof", "{0} must be one of {1}", oneOf)
+	registerMessage("max", "{0} must be at most {1} characters", nil)
+	registerMessage("min", "{0} must be at least {1}", nil)
+}
+
+// isPositive reports whether a numeric field is greater than zero.
+func isPositive(fl validator.FieldLevel) bool {
+	field := fl.Field()
+	switch field.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return field.Float() > 0
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return field.Int() > 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return field.Uint() > 0
+	default:
+		return false
+	}
+}
+
+// oneOf lists the values of a oneof tag as "a, b or c".
+func oneOf(param string) string {
+	values := strings.Fields(param)
+	if len(values) < 2 {
+		return param
+	}
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
+
+// registerMessage replaces the message of a validation tag. {0} is the field
+// name and {1} the tag parameter, passed through format if it is given.
+func registerMessage(tag, text string, format func(string) string) {
+	err := validate.RegisterTranslation(tag, trans, func(t ut.Translator) error {
+		return t.Add(tag, text, true)
+	}, func(t ut.Translator, fe validator.FieldError) string {
+		param := fe.Param()
+		if format != nil {
+			param = format(param)
+		}
+		msg, _ := t.T(tag, fe.Field(), param)
+		return msg
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Decode reads the JSON body of r into val and validates it.
 func Decode(r *http.Request, val interface{}) error {
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
@@ -20,6 +110,12 @@ func Decode(r *http.Request, val interface{}) error {
 		return NewRequestError(err, http.StatusBadRequest)
 	}
 
+	return Validate(val)
+}
+
+// Validate checks val against its validate struct tags. A failure is returned
+// as a request error listing every invalid field.
+func Validate(val interface{}) error {
 	if err := validate.Struct(val); err != nil {
 
 		// Use a type assertion to get the real error value.
@@ -35,6 +131,7 @@ func Decode(r *http.Request, val interface{}) error {
 		for _, verror := range verrors {
 			field := FieldError{
 				Field: verror.Field(),
+				Error: verror.Translate(trans),
 			}
 			fields = append(fields, field)
 		}
@@ -47,6 +144,7 @@ func Decode(r *http.Request, val interface{}) error {
 		return &Error{
 			Err:    errors.New(errMessage),
 			Status: http.StatusBadRequest,
+			Code:   CodeInvalidRequest,
 			Fields: fields,
 		}
 	}
@@ -59,6 +157,7 @@ func Decode(r *http.Request, val interface{}) error {
 type Error struct {
 	Err    error
 	Status int
+	Code   string
 	Fields []FieldError
 }
 
@@ -69,7 +168,7 @@ func (e Error) Error() string {
 // NewRequestError wraps a provided error with an HTTP status code. This
 // function should be used when handlers encounter expected errors.
 func NewRequestError(err error, status int) error {
-	return &Error{err, status, nil}
+	return &Error{err, status, CodeInvalidRequest, nil}
 }
 
 // FieldError is used to indicate an error with a specific request field.
@@ -81,5 +180,6 @@ type FieldError struct {
 // ErrorResponse is the form used for API responses from failures in the API.
 type ErrorResponse struct {
 	Error  string       `json:"error"`
+	Code   string       `json:"code"`
 	Fields []FieldError `json:"fields,omitempty"`
 }
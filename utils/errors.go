@@ -0,0 +1,114 @@
+package utils
+
+import "net/http"
+
+// Stable machine-readable error codes returned to API clients in
+// ErrorResponse.Code. Clients may rely on these; never change a value.
+const (
+	CodeInvalidRequest     = "invalid_request"
+	CodeNotFound           = "not_found"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeInsufficientFunds  = "insufficient_funds"
+	CodeDuplicateReference = "duplicate_reference"
+	CodeWalletFrozen       = "wallet_frozen"
+	CodeConflict           = "conflict"
+	CodePreconditionFailed = "precondition_failed"
+	CodeProviderFailure    = "provider_failure"
+	CodeUnavailable        = "unavailable"
+	CodeInternal           = "internal_error"
+)
+
+// DomainError is an expected failure of a domain operation. It is matched by
+// code, so errors.Is(err, ErrNotFound) holds for any not found error whatever
+// its message.
+type DomainError struct {
+	Code    string
+	Message string
+}
+
+func (e *DomainError) Error() string {
+	return e.Message
+}
+
+// Is reports whether target is a DomainError with the same code.
+func (e *DomainError) Is(target error) bool {
+	t, ok := target.(*DomainError)
+	return ok && t.Code == e.Code
+}
+
+// Sentinels to match domain errors against with errors.Is.
+var (
+	ErrInvalidRequest     = &DomainError{Code: CodeInvalidRequest, Message: "invalid request"}
+	ErrNotFound           = &DomainError{Code: CodeNotFound, Message: "not found"}
+	ErrUnauthorized       = &DomainError{Code: CodeUnauthorized, Message: "unauthorized"}
+	ErrForbidden          = &DomainError{Code: CodeForbidden, Message: "forbidden"}
+	ErrInsufficientFunds  = &DomainError{Code: CodeInsufficientFunds, Message: "insufficient balance"}
+	ErrDuplicateReference = &DomainError{Code: CodeDuplicateReference, Message: "duplicate reference"}
+	ErrWalletFrozen       = &DomainError{Code: CodeWalletFrozen, Message: "wallet is frozen"}
+	ErrConflict           = &DomainError{Code: CodeConflict, Message: "conflict"}
+	ErrPreconditionFailed = &DomainError{Code: CodePreconditionFailed, Message: "precondition failed"}
+	ErrProviderFailure    = &DomainError{Code: CodeProviderFailure, Message: "payment provider failed"}
+)
+
+// InvalidRequest returns an invalid request error with the given message.
+func InvalidRequest(message string) error {
+	return &DomainError{Code: CodeInvalidRequest, Message: message}
+}
+
+// NotFound returns a not found error with the given message.
+func NotFound(message string) error {
+	return &DomainError{Code: CodeNotFound, Message: message}
+}
+
+// Unauthorized returns an unauthorized error with the given message.
+func Unauthorized(message string) error {
+	return &DomainError{Code: CodeUnauthorized, Message: message}
+}
+
+// Forbidden returns a forbidden error with the given message.
+func Forbidden(message string) error {
+	return &DomainError{Code: CodeForbidden, Message: message}
+}
+
+// DuplicateReference returns a duplicate reference error with the given message.
+func DuplicateReference(message string) error {
+	return &DomainError{Code: CodeDuplicateReference, Message: message}
+}
+
+// Conflict returns a conflict error with the given message.
+func Conflict(message string) error {
+	return &DomainError{Code: CodeConflict, Message: message}
+}
+
+// PreconditionFailed returns a precondition failed error with the given
+// message.
+func PreconditionFailed(message string) error {
+	return &DomainError{Code: CodePreconditionFailed, Message: message}
+}
+
+// statusForCode maps an error code to the HTTP status it is reported with.
+func statusForCode(code string) int {
+	switch code {
+	case CodeInvalidRequest:
+		return http.StatusBadRequest
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeForbidden:
+		return http.StatusForbidden
+	case CodeInsufficientFunds:
+		return http.StatusUnprocessableEntity
+	case CodeDuplicateReference, CodeWalletFrozen, CodeConflict:
+		return http.StatusConflict
+	case CodePreconditionFailed:
+		return http.StatusPreconditionFailed
+	case CodeProviderFailure:
+		return http.StatusBadGateway
+	case CodeUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
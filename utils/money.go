@@ -0,0 +1,16 @@
+package utils
+
+import "math"
+
+// Cents converts an amount in currency units, as clients send it, to cents,
+// rounding away the error of its float representation.
+func Cents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// hasCents reports whether amount has at most two decimal places, so Cents
+// converts it exactly.
+func hasCents(amount float64) bool {
+	cents := amount * 100
+	return math.Abs(cents-math.Round(cents)) < 1e-6
+}
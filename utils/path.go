@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// PathID returns the id in the path of r, or a request error if it is not a
+// UUID, which every id is.
+func PathID(r *http.Request) (string, error) {
+	params := struct {
+		ID string `json:"id" validate:"uuid"`
+	}{ID: mux.Vars(r)["id"]}
+	if err := Validate(params); err != nil {
+		return "", err
+	}
+	return params.ID, nil
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"p-system/logging"
+)
+
+// SendJSONResponse sends a JSON response with the specified status code and data
+func SendJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+// RespondError sends err as an ErrorResponse. Request and domain errors are
+// reported with their own status and code; any other error is logged and
+// reported as an internal error without its details.
+func RespondError(w http.ResponseWriter, err error) {
+	var requestErr *Error
+	if errors.As(err, &requestErr) {
+		code := requestErr.Code
+		if code == "" {
+			code = CodeInvalidRequest
+		}
+		SendJSONResponse(w, requestErr.Status, ErrorResponse{Error: requestErr.Error(), Code: code, Fields: requestErr.Fields})
+		return
+	}
+
+	var domainErr *DomainError
+	if errors.As(err, &domainErr) {
+		SendJSONResponse(w, statusForCode(domainErr.Code), ErrorResponse{Error: domainErr.Message, Code: domainErr.Code})
+		return
+	}
+
+	// The request ID middleware has set the response header by now
+	slog.Error("internal error", "request_id", w.Header().Get(logging.RequestIDHeader), "error", err)
+	SendJSONResponse(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error", Code: CodeInternal})
+}